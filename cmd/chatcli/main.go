@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"distributed-chat-system/pkg/chatclient"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+const helpText = `Commands:
+  /chat <chat_id>     switch the active chat
+  /to <user_id>       switch the receiver, and the chat to your direct chat with them
+                      unless /chat picked another one
+  /read               mark the last received message as read
+  /who                show the active chat, receiver and their presence
+  /help               show this help
+  /quit               exit
+Anything else is sent as a message to the active receiver.`
+
+// session is the mutable state of the terminal client
+type session struct {
+	mutex       sync.Mutex
+	userID      string
+	chatID      string
+	receiverID  string
+	lastMessage *chatclient.Message
+}
+
+func (s *session) target() (string, string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.chatID, s.receiverID
+}
+
+func main() {
+	serverURL := flag.String("server", "ws://localhost:8080", "chat server WebSocket base URL")
+	userID := flag.String("user", "", "user ID to connect as (required)")
+	chatID := flag.String("chat", "", "initial chat ID")
+	receiverID := flag.String("to", "", "initial receiver user ID")
+	subprotocol := flag.String("subprotocol", "chat.v1.json", "frame encoding: chat.v1.json, chat.v1.msgpack or chat.v1.proto")
+	resumeFrom := flag.Int64("resume-from", -1, "inbox sequence to resume from, -1 for live messages only")
+	token := flag.String("token", os.Getenv("CHAT_TOKEN"), "signed token of the user, defaults to $CHAT_TOKEN")
+	flag.Parse()
+
+	if *userID == "" {
+		fmt.Fprintln(os.Stderr, "-user is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Keep library logs away from the chat output
+	log.SetOutput(os.Stderr)
+	log.SetPrefix("[chatcli] ")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	state := &session{userID: *userID, chatID: *chatID, receiverID: *receiverID}
+	if state.chatID == "" && state.receiverID != "" {
+		state.chatID = chatclient.DirectChatID(state.userID, state.receiverID)
+	}
+
+	config := chatclient.DefaultConfig(*serverURL, *userID)
+	config.Subprotocol = *subprotocol
+	config.ResumeFrom = *resumeFrom
+	config.Token = *token
+	client := chatclient.New(config)
+
+	client.OnMessage(func(message chatclient.Message) {
+		state.mutex.Lock()
+		state.lastMessage = &message
+		state.mutex.Unlock()
+		printLine("[%s] %s: %s", message.ChatID, message.Sender, message.Message)
+	})
+	client.OnReceipt(func(receipt chatclient.Receipt) {
+		printLine("  ✓ %s by %s (%s)", receipt.Status, receipt.UserID, shortID(receipt.EventID))
+	})
+	client.OnError(func(message string) {
+		printLine("! server error: %s", message)
+	})
+	client.OnModeration(func(notice chatclient.ModerationNotice) {
+		switch notice.Action {
+		case "delete_message":
+			printLine("! moderators removed message %s: %s", shortID(notice.EventID), notice.Reason)
+		case "mute":
+			until := "further notice"
+			if notice.Until != "" {
+				until = notice.Until
+			}
+			printLine("! muted by moderators until %s: %s", until, notice.Reason)
+		default:
+			printLine("! %s from moderators: %s", notice.Action, notice.Reason)
+		}
+	})
+	client.OnConnect(func() {
+		printLine("* connected as %s (resume from %d)", *userID, client.LastSequence())
+	})
+	client.OnDisconnect(func(err error) {
+		printLine("* disconnected: %v, reconnecting...", err)
+	})
+
+	if err := client.Connect(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect: %v\n", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	go watchPresence(ctx, client, state)
+
+	fmt.Println(helpText)
+	readInput(ctx, client, state)
+}
+
+// readInput runs the command loop until /quit, EOF or Ctrl-C
+func readInput(ctx context.Context, client *chatclient.Client, state *session) {
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			if !handleLine(ctx, client, state, strings.TrimSpace(line)) {
+				return
+			}
+		}
+	}
+}
+
+// handleLine executes one input line, returning false when the user wants to quit
+func handleLine(ctx context.Context, client *chatclient.Client, state *session, line string) bool {
+	if line == "" {
+		return true
+	}
+
+	command, argument, _ := strings.Cut(line, " ")
+	argument = strings.TrimSpace(argument)
+	switch command {
+	case "/quit", "/exit":
+		return false
+	case "/help":
+		fmt.Println(helpText)
+	case "/chat":
+		state.mutex.Lock()
+		state.chatID = argument
+		state.mutex.Unlock()
+		printLine("* active chat: %s", argument)
+	case "/to":
+		state.mutex.Lock()
+		// Follow the receiver while the chat is the direct chat with the previous one
+		if state.chatID == "" || state.chatID == chatclient.DirectChatID(state.userID, state.receiverID) {
+			state.chatID = chatclient.DirectChatID(state.userID, argument)
+		}
+		state.receiverID = argument
+		chatID := state.chatID
+		state.mutex.Unlock()
+		printLine("* sending to: %s in chat %s", argument, chatID)
+	case "/read":
+		state.mutex.Lock()
+		lastMessage := state.lastMessage
+		state.mutex.Unlock()
+		if lastMessage == nil {
+			printLine("! nothing to mark as read")
+			return true
+		}
+		if err := client.MarkRead(*lastMessage); err != nil {
+			printLine("! %v", err)
+		}
+	case "/who":
+		chatID, receiverID := state.target()
+		online, err := client.Presence(ctx, receiverID)
+		if err != nil {
+			printLine("* chat %q, receiver %q (presence unknown: %v)", chatID, receiverID, err)
+			return true
+		}
+		printLine("* chat %q, receiver %q (%s)", chatID, receiverID, presenceLabel(online))
+	default:
+		// Slash commands unknown to the CLI are bot commands and go to the server as text
+		sendMessage(ctx, client, state, line)
+	}
+	return true
+}
+
+func sendMessage(ctx context.Context, client *chatclient.Client, state *session, text string) {
+	chatID, receiverID := state.target()
+	if chatID == "" || receiverID == "" {
+		printLine("! pick a chat and receiver first with /chat and /to")
+		return
+	}
+
+	ack, err := client.Send(ctx, chatID, receiverID, text)
+	if err != nil {
+		printLine("! not sent: %v", err)
+		return
+	}
+	printLine("  ✓ sent (%s)", shortID(ack.EventID))
+}
+
+// watchPresence polls the receiver's presence and prints changes
+func watchPresence(ctx context.Context, client *chatclient.Client, state *session) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var lastReceiver string
+	var lastOnline bool
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, receiverID := state.target()
+		if receiverID == "" {
+			continue
+		}
+		online, err := client.Presence(ctx, receiverID)
+		if err != nil {
+			continue
+		}
+		if receiverID != lastReceiver || online != lastOnline {
+			printLine("* %s is %s", receiverID, presenceLabel(online))
+		}
+		lastReceiver, lastOnline = receiverID, online
+	}
+}
+
+func presenceLabel(online bool) string {
+	if online {
+		return "online"
+	}
+	return "offline"
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+var outputMutex sync.Mutex
+
+// printLine serialises output from the read loop, callbacks and presence watcher
+func printLine(format string, args ...interface{}) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	fmt.Printf(format+"\n", args...)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"distributed-chat-system/pkg/jwt"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// chattoken mints HS256 tokens for development, signed with the servers' JWT_SECRET
+func main() {
+	userID := flag.String("user", "", "user ID to issue the token to (required)")
+	ttl := flag.Duration("ttl", time.Hour, "token lifetime, 0 for a token that never expires")
+	secret := flag.String("secret", os.Getenv("JWT_SECRET"), "HS256 secret, defaults to $JWT_SECRET")
+	issuer := flag.String("issuer", os.Getenv("JWT_ISSUER"), "iss claim, defaults to $JWT_ISSUER")
+	audience := flag.String("audience", os.Getenv("JWT_AUDIENCE"), "aud claim, defaults to $JWT_AUDIENCE")
+	roles := flag.String("roles", "", "comma-separated roles claim, e.g. admin")
+	tenant := flag.String("tenant", "", "tenant claim")
+	flag.Parse()
+
+	if *userID == "" || *secret == "" {
+		fmt.Fprintln(os.Stderr, "-user and -secret (or $JWT_SECRET) are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	now := time.Now()
+	claims := jwt.Claims{Subject: *userID, Issuer: *issuer, IssuedAt: now.Unix(), Tenant: *tenant}
+	if *audience != "" {
+		claims.Audience = jwt.Audience{*audience}
+	}
+	if *roles != "" {
+		claims.Roles = strings.Split(*roles, ",")
+	}
+	if *ttl > 0 {
+		claims.ExpiresAt = now.Add(*ttl).Unix()
+	}
+
+	token, err := jwt.SignHS256(claims, []byte(*secret))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(token)
+}
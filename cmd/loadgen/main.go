@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"distributed-chat-system/pkg/chatclient"
+	"distributed-chat-system/pkg/jwt"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const messagePrefix = "loadgen "
+
+// stats collects results from every virtual user
+type stats struct {
+	mutex      sync.Mutex
+	sent       map[string]time.Time // Acked event id -> send time
+	received   map[string]int       // Event id -> times received
+	latencies  []time.Duration
+	sendErrors map[string]int
+
+	attempted     atomic.Int64
+	connectErrors atomic.Int64
+	disconnects   atomic.Int64
+}
+
+func newStats() *stats {
+	return &stats{
+		sent:       make(map[string]time.Time),
+		received:   make(map[string]int),
+		sendErrors: make(map[string]int),
+	}
+}
+
+func (s *stats) recordSend(eventID string, sentAt time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sent[eventID] = sentAt
+}
+
+func (s *stats) recordSendError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sendErrors[err.Error()]++
+}
+
+// recordReceive measures latency from the send timestamp embedded in the message body
+func (s *stats) recordReceive(message chatclient.Message, receivedAt time.Time) {
+	if !strings.HasPrefix(message.Message, messagePrefix) {
+		return
+	}
+	sentAtNanos, err := strconv.ParseInt(strings.TrimPrefix(message.Message, messagePrefix), 10, 64)
+	if err != nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.received[message.EventID]++
+	if s.received[message.EventID] == 1 {
+		s.latencies = append(s.latencies, receivedAt.Sub(time.Unix(0, sentAtNanos)))
+	}
+}
+
+func main() {
+	servers := flag.String("servers", "ws://localhost:8080,ws://localhost:8081,ws://localhost:8082", "comma-separated chat server WebSocket base URLs")
+	users := flag.Int("users", 100, "number of virtual users, spread round-robin across servers")
+	rate := flag.Float64("rate", 50, "target messages per second across all users")
+	duration := flag.Duration("duration", 30*time.Second, "how long to send messages")
+	drain := flag.Duration("drain", 5*time.Second, "how long to wait for in-flight deliveries after sending stops")
+	userPrefix := flag.String("user-prefix", "loadgen-", "prefix of virtual user IDs")
+	subprotocol := flag.String("subprotocol", "chat.v1.json", "frame encoding: chat.v1.json, chat.v1.msgpack or chat.v1.proto")
+	jwtSecret := flag.String("jwt-secret", os.Getenv("JWT_SECRET"), "HS256 secret to mint a token per virtual user, defaults to $JWT_SECRET")
+	flag.Parse()
+
+	if *users < 2 || *rate <= 0 {
+		fmt.Fprintln(os.Stderr, "-users must be at least 2 and -rate positive")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	serverURLs := strings.Split(*servers, ",")
+	results := newStats()
+
+	clients, userIDs := connectUsers(ctx, serverURLs, *users, *userPrefix, *subprotocol, []byte(*jwtSecret), results)
+	defer func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}()
+	if len(clients) < 2 {
+		log.Fatalf("Only %d virtual users connected, need at least 2", len(clients))
+	}
+	log.Printf("Connected %d/%d virtual users across %d servers", len(clients), *users, len(serverURLs))
+
+	started := time.Now()
+	generateLoad(ctx, clients, userIDs, *rate, *duration, results)
+	sendingTime := time.Since(started)
+
+	log.Printf("Sending finished, draining for %s...", *drain)
+	select {
+	case <-time.After(*drain):
+	case <-ctx.Done():
+	}
+
+	report(results, sendingTime)
+}
+
+// connectUsers opens one client per virtual user, assigning servers round-robin
+func connectUsers(ctx context.Context, serverURLs []string, users int, userPrefix, subprotocol string, jwtSecret []byte, results *stats) ([]*chatclient.Client, []string) {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	clients := make([]*chatclient.Client, 0, users)
+	userIDs := make([]string, 0, users)
+	// Limit concurrent handshakes so the servers are not hit by a thundering herd
+	slots := make(chan struct{}, 50)
+
+	for i := 0; i < users; i++ {
+		userID := fmt.Sprintf("%s%d", userPrefix, i)
+		config := chatclient.DefaultConfig(strings.TrimSpace(serverURLs[i%len(serverURLs)]), userID)
+		config.Subprotocol = subprotocol
+		if len(jwtSecret) > 0 {
+			token, err := jwt.SignHS256(jwt.Claims{Subject: userID, IssuedAt: time.Now().Unix()}, jwtSecret)
+			if err != nil {
+				log.Fatalf("Failed to mint token for %s: %v", userID, err)
+			}
+			config.Token = token
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			client := chatclient.New(config)
+			client.OnMessage(func(message chatclient.Message) {
+				results.recordReceive(message, time.Now())
+			})
+			client.OnDisconnect(func(error) {
+				results.disconnects.Add(1)
+			})
+			if err := client.Connect(ctx); err != nil {
+				results.connectErrors.Add(1)
+				log.Printf("Failed to connect %s: %v", userID, err)
+				return
+			}
+
+			mutex.Lock()
+			clients = append(clients, client)
+			userIDs = append(userIDs, userID)
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+	return clients, userIDs
+}
+
+// generateLoad sends messages between random pairs of users at the target rate
+func generateLoad(ctx context.Context, clients []*chatclient.Client, userIDs []string, rate float64, duration time.Duration, results *stats) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	deadline := time.After(duration)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+
+		sender := rand.Intn(len(clients))
+		receiver := rand.Intn(len(clients) - 1)
+		if receiver >= sender {
+			receiver++
+		}
+
+		results.attempted.Add(1)
+		wg.Add(1)
+		go func(client *chatclient.Client, senderID, receiverID string) {
+			defer wg.Done()
+			sentAt := time.Now()
+			ack, err := client.Send(ctx, chatclient.DirectChatID(senderID, receiverID), receiverID, messagePrefix+strconv.FormatInt(sentAt.UnixNano(), 10))
+			if err != nil {
+				results.recordSendError(err)
+				return
+			}
+			results.recordSend(ack.EventID, sentAt)
+		}(clients[sender], userIDs[sender], userIDs[receiver])
+	}
+}
+
+func report(results *stats, sendingTime time.Duration) {
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+
+	delivered, duplicates, unexpected := 0, 0, 0
+	for eventID, count := range results.received {
+		if _, ok := results.sent[eventID]; !ok {
+			unexpected++
+			continue
+		}
+		delivered++
+		duplicates += count - 1
+	}
+
+	sendErrors := 0
+	for _, count := range results.sendErrors {
+		sendErrors += count
+	}
+
+	fmt.Println()
+	fmt.Println("=== Load generation report ===")
+	fmt.Printf("Sending time:      %s\n", sendingTime.Round(time.Millisecond))
+	fmt.Printf("Attempted:         %d (%.1f msg/s)\n", results.attempted.Load(), float64(results.attempted.Load())/sendingTime.Seconds())
+	fmt.Printf("Acked:             %d\n", len(results.sent))
+	fmt.Printf("Send errors:       %d\n", sendErrors)
+	for message, count := range results.sendErrors {
+		fmt.Printf("  %6d  %s\n", count, message)
+	}
+	fmt.Printf("Connect errors:    %d\n", results.connectErrors.Load())
+	fmt.Printf("Disconnects:       %d\n", results.disconnects.Load())
+	fmt.Printf("Delivered:         %d\n", delivered)
+	if len(results.sent) > 0 {
+		fmt.Printf("Delivery ratio:    %.2f%%\n", 100*float64(delivered)/float64(len(results.sent)))
+	}
+	fmt.Printf("Duplicates:        %d\n", duplicates)
+	// Delivered although the send was never acked, e.g. the ack timed out
+	fmt.Printf("Unmatched:         %d\n", unexpected)
+
+	if len(results.latencies) == 0 {
+		fmt.Println("Latency:           no deliveries")
+		return
+	}
+	sort.Slice(results.latencies, func(i, j int) bool { return results.latencies[i] < results.latencies[j] })
+	fmt.Println("End-to-end latency:")
+	for _, percentile := range []float64{50, 90, 95, 99} {
+		fmt.Printf("  p%-4g %s\n", percentile, percentileOf(results.latencies, percentile).Round(time.Microsecond))
+	}
+	fmt.Printf("  max   %s\n", results.latencies[len(results.latencies)-1].Round(time.Microsecond))
+}
+
+// percentileOf returns the nearest-rank percentile of sorted durations
+func percentileOf(sorted []time.Duration, percentile float64) time.Duration {
+	rank := int(percentile/100*float64(len(sorted))+0.5) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return sorted[rank]
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"crypto/tls"
+	"distributed-chat-system/internal/apis/botgrpc"
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/apis/irc"
 	"distributed-chat-system/internal/apis/routes"
 	"distributed-chat-system/internal/di"
 	"log"
+	"net/http"
 	"os"
 
 	"github.com/gin-gonic/gin"
@@ -22,12 +27,41 @@ func main() {
 	}
 
 	di.InitializeDependencies()
-	// Create a Gin router instance
-	router := gin.Default()
+	// Create a Gin router instance, tokens are stripped from query strings before logging
+	router := gin.New()
+	router.Use(handlers.StripQueryToken, gin.Logger(), gin.Recovery())
 
 	// Setup routes
 	routes.Setup(router)
 
+	// Start the IRC gateway for legacy clients when a listen address is configured
+	if os.Getenv("IRC_LISTEN_ADDR") != "" {
+		di.Resolve(func(gateway *irc.Gateway) {
+			go func() {
+				if err := gateway.ListenAndServe(); err != nil {
+					log.Printf("IRC gateway stopped: %v", err)
+				}
+			}()
+		})
+	}
+
+	// Start the gRPC stream for bots when a listen address is configured
+	if os.Getenv("BOT_GRPC_LISTEN_ADDR") != "" {
+		di.Resolve(func(server *botgrpc.Server) {
+			var tlsConfig *tls.Config
+			if os.Getenv("TLS_CERT_FILE") != "" {
+				di.Resolve(func(config *tls.Config) {
+					tlsConfig = config
+				})
+			}
+			go func() {
+				if err := server.ListenAndServe(tlsConfig); err != nil {
+					log.Printf("gRPC bot stream stopped: %v", err)
+				}
+			}()
+		})
+	}
+
 	// Get the port from environment variables
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -35,9 +69,20 @@ func main() {
 		log.Printf("No PORT environment variable detected, using default port: %s", port)
 	}
 
-	// Start the Gin server
-	log.Printf("Starting server on port %s...", port)
-	if err := router.Run(":" + port); err != nil {
+	// Start the Gin server, terminating TLS itself when a certificate is configured
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	var err error
+	if os.Getenv("TLS_CERT_FILE") != "" {
+		di.Resolve(func(tlsConfig *tls.Config) {
+			server.TLSConfig = tlsConfig
+		})
+		log.Printf("Starting server with TLS on port %s...", port)
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("Starting server on port %s...", port)
+		err = server.ListenAndServe()
+	}
+	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
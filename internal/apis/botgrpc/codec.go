@@ -0,0 +1,22 @@
+package botgrpc
+
+import (
+	"distributed-chat-system/internal/apis/codecs"
+)
+
+// structCodec carries stream frames as google.protobuf.Struct messages, the same
+// encoding as the chat.v1.proto WebSocket subprotocol, so bots only need the
+// well-known types and no generated chat messages
+type structCodec struct {
+	codecs.ProtoCodec
+}
+
+func (structCodec) Name() string { return "proto" }
+
+func (c structCodec) Marshal(v any) ([]byte, error) {
+	return c.Encode(v)
+}
+
+func (c structCodec) Unmarshal(data []byte, v any) error {
+	return c.Decode(data, v)
+}
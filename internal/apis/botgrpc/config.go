@@ -0,0 +1,21 @@
+package botgrpc
+
+import (
+	"distributed-chat-system/internal/utils"
+	"time"
+)
+
+type Config struct {
+	ListenAddr       string        // e.g. ":9090", empty disables the gRPC bot stream
+	KeepaliveTime    time.Duration // How often idle streams are pinged
+	KeepaliveTimeout time.Duration // How long a ping may go unanswered before the stream is dropped
+}
+
+// ConfigFromEnv builds the bot stream configuration from BOT_GRPC_* environment variables
+func ConfigFromEnv() *Config {
+	return &Config{
+		ListenAddr:       utils.GetEnvString("BOT_GRPC_LISTEN_ADDR", ""),
+		KeepaliveTime:    utils.GetEnvDuration("BOT_GRPC_KEEPALIVE_TIME", 30*time.Second),
+		KeepaliveTimeout: utils.GetEnvDuration("BOT_GRPC_KEEPALIVE_TIMEOUT", 15*time.Second),
+	}
+}
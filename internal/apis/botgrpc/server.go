@@ -0,0 +1,316 @@
+package botgrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/services"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ServiceName and ConnectMethod make up the full method /chat.bots.v1.BotStream/Connect
+	ServiceName   = "chat.bots.v1.BotStream"
+	ConnectMethod = "Connect"
+
+	// Metadata a bot authenticates with, the same ID and secret it would use on /ws/bot/:bot_id
+	BotIDMetadata     = "x-bot-id"
+	BotSecretMetadata = "x-bot-secret"
+
+	registryRefreshInterval = time.Minute
+)
+
+var errStreamReplaced = errors.New("replaced by a newer stream of the same bot")
+
+// serviceDesc is written by hand: both directions carry google.protobuf.Struct, see bot_stream.proto
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    ConnectMethod,
+		Handler:       func(srv any, stream grpc.ServerStream) error { return srv.(*Server).connect(stream) },
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "bot_stream.proto",
+}
+
+// Server serves stream bots over a bidirectional gRPC stream. Connected bots register in
+// the user registry like WebSocket users and receive their invocations as a chat consumer,
+// so an invocation reaches the bot whichever server it is connected to.
+type Server struct {
+	config      *Config
+	chatService *services.ChatMessageService
+	botService  *services.BotService
+	rateLimiter *services.RateLimitService
+
+	mutex   sync.RWMutex
+	streams map[string]*botStream // Connected streams by bot ID
+}
+
+// botStream is one connected bot
+type botStream struct {
+	connectionID string // Keys the per-connection rate limit
+	botID        string
+	remoteAddr   string
+	connectedAt  time.Time
+	cancel       context.CancelCauseFunc
+
+	mutex  sync.Mutex // SendMsg is not safe for concurrent use
+	stream grpc.ServerStream
+	closed bool
+
+	messagesReceived atomic.Int64
+	messagesSent     atomic.Int64
+}
+
+// NewServer creates the bot stream server and subscribes it to the ChatMessageService
+func NewServer(config *Config, chatService *services.ChatMessageService, botService *services.BotService, rateLimiter *services.RateLimitService) *Server {
+	server := &Server{
+		config:      config,
+		chatService: chatService,
+		botService:  botService,
+		rateLimiter: rateLimiter,
+		streams:     make(map[string]*botStream),
+	}
+	chatService.AddChatConsumer(server)
+	return server
+}
+
+// ListenAndServe accepts bot streams until the listener fails. A non-nil tlsConfig
+// terminates TLS with the same certificate as the HTTP server.
+func (s *Server) ListenAndServe(tlsConfig *tls.Config) error {
+	listener, err := net.Listen("tcp", s.config.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	options := []grpc.ServerOption{
+		grpc.ForceServerCodec(structCodec{}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: s.config.KeepaliveTime, Timeout: s.config.KeepaliveTimeout}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: 10 * time.Second, PermitWithoutStream: true}),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(options...)
+	server.RegisterService(&serviceDesc, s)
+
+	log.Printf("gRPC bot stream listening on %s", s.config.ListenAddr)
+	return server.Serve(listener)
+}
+
+// connect runs one bot stream: invocations go out from Notify, replies come in here
+func (s *Server) connect(stream grpc.ServerStream) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	botID := firstValue(md, BotIDMetadata)
+	bot, ok := s.botService.AuthenticateBot(botID, firstValue(md, BotSecretMetadata))
+	if !ok {
+		return status.Error(codes.Unauthenticated, "invalid bot credentials")
+	}
+	if bot.Transport != constants.BotTransportStream {
+		return status.Error(codes.FailedPrecondition, "bot does not use stream transport")
+	}
+
+	ctx, cancel := context.WithCancelCause(stream.Context())
+	defer cancel(nil)
+	client := &botStream{
+		connectionID: uuid.NewString(),
+		botID:        botID,
+		connectedAt:  time.Now().UTC(),
+		cancel:       cancel,
+		stream:       stream,
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		client.remoteAddr = p.Addr.String()
+	}
+
+	s.attach(client)
+	defer s.detach(client)
+	log.Printf("gRPC stream established for bot: %s", botID)
+	s.chatService.SubscribeUserToChatServer(botID)
+
+	received := make(chan error, 1)
+	go func() { received <- s.receive(client) }()
+
+	ticker := time.NewTicker(registryRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-received:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+				return status.Error(codes.Aborted, cause.Error())
+			}
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+			if err := s.chatService.RefreshUserChatServer(botID); err != nil {
+				log.Printf("Error refreshing registry entry of bot %s: %v", botID, err)
+			}
+		}
+	}
+}
+
+// receive reads the bot's replies until the stream ends
+func (s *Server) receive(client *botStream) error {
+	for {
+		var reply dtos.BotStreamReplyDto
+		if err := client.stream.RecvMsg(&reply); err != nil {
+			return err
+		}
+		client.messagesReceived.Add(1)
+
+		var limitErr *services.RateLimitError
+		if errors.As(s.rateLimiter.AllowFrame(client.connectionID), &limitErr) {
+			if s.rateLimiter.RecordViolation(client.botID, limitErr) {
+				log.Printf("Disconnecting bot %s for flooding", client.botID)
+				return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+			}
+			client.send(dtos.BotStreamFrameDto{Type: constants.FrameTypeError, InvocationID: reply.InvocationID, Error: limitErr.Error(), RetryAfterMs: limitErr.RetryAfter.Milliseconds()})
+			continue
+		}
+
+		if err := s.chatService.ReplyToBotInvocation(client.botID, reply.InvocationID, reply.Reply); err != nil {
+			log.Printf("Error replying to bot invocation %s: %v", reply.InvocationID, err)
+			client.send(dtos.BotStreamFrameDto{Type: constants.FrameTypeError, InvocationID: reply.InvocationID, Error: err.Error()})
+		}
+	}
+}
+
+// attach makes a stream the one invocations of its bot go to, ending an older stream
+func (s *Server) attach(client *botStream) {
+	s.mutex.Lock()
+	previous := s.streams[client.botID]
+	s.streams[client.botID] = client
+	s.mutex.Unlock()
+	if previous != nil {
+		previous.cancel(errStreamReplaced)
+	}
+}
+
+// detach forgets a stream that ended, leaving the registry to a newer one
+func (s *Server) detach(client *botStream) {
+	client.mutex.Lock()
+	client.closed = true
+	client.mutex.Unlock()
+
+	s.mutex.Lock()
+	current := s.streams[client.botID] == client
+	if current {
+		delete(s.streams, client.botID)
+	}
+	s.mutex.Unlock()
+	if current {
+		s.chatService.UnsubscribeUserToChatServer(client.botID)
+	}
+	log.Printf("gRPC stream closed for bot: %s", client.botID)
+}
+
+func (s *Server) stream(botID string) (*botStream, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	client, exists := s.streams[botID]
+	return client, exists
+}
+
+// Notify hands an invocation routed to a bot connected to this server over its stream
+func (s *Server) Notify(senderUserID string, message models.ChatMessage) error {
+	client, exists := s.stream(message.ReceiverUserID)
+	if !exists || message.MessageType != constants.MessageTypeBotInvocation {
+		return nil
+	}
+
+	var invocation models.BotInvocation
+	if err := json.Unmarshal([]byte(message.Message), &invocation); err != nil {
+		log.Printf("Malformed invocation %s for bot %s: %v", message.EventID, client.botID, err)
+		return err
+	}
+	if err := client.send(dtos.BotStreamFrameDto{Type: constants.FrameTypeBotInvocation, Invocation: &invocation}); err != nil {
+		log.Printf("Error sending invocation to bot %s: %v", client.botID, err)
+		return err
+	}
+	client.messagesSent.Add(1)
+	s.chatService.MarkMessageDelivered(message)
+	return nil
+}
+
+// NotifyReceipt is a no-op: bots don't get delivery or read receipts
+func (s *Server) NotifyReceipt(event models.ChatEvent) error {
+	return nil
+}
+
+// NotifyModeration is a no-op: moderation notices are meant for people
+func (s *Server) NotifyModeration(event models.ChatEvent) error {
+	return nil
+}
+
+// Disconnect ends the stream of a bot on this server
+func (s *Server) Disconnect(userID string, reason string) error {
+	client, exists := s.stream(userID)
+	if !exists {
+		return nil
+	}
+	client.cancel(errors.New(reason))
+	return nil
+}
+
+// Connections lists the bot streams held by this server
+func (s *Server) Connections() []models.ConnectionInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	connections := make([]models.ConnectionInfo, 0, len(s.streams))
+	for _, client := range s.streams {
+		connections = append(connections, models.ConnectionInfo{
+			UserID:           client.botID,
+			ServerID:         os.Getenv("SERVER_ID"),
+			RemoteAddr:       client.remoteAddr,
+			Subprotocol:      "grpc",
+			IsBot:            true,
+			ConnectedAt:      client.connectedAt,
+			MessagesReceived: client.messagesReceived.Load(),
+			MessagesSent:     client.messagesSent.Load(),
+		})
+	}
+	return connections
+}
+
+// send writes a frame to the bot, unless its stream already ended
+func (c *botStream) send(frame dtos.BotStreamFrameDto) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return errors.New("stream closed")
+	}
+	return c.stream.SendMsg(&frame)
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package codecs
+
+// Codec encodes and decodes WebSocket frames for one negotiated subprotocol
+type Codec interface {
+	// Subprotocol is the Sec-WebSocket-Protocol value this codec answers to
+	Subprotocol() string
+	// FrameType is the websocket frame type (text or binary) used for writes
+	FrameType() int
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte, v interface{}) error
+}
+
+const (
+	JSONSubprotocol    = "chat.v1.json"
+	MsgpackSubprotocol = "chat.v1.msgpack"
+	ProtoSubprotocol   = "chat.v1.proto"
+)
+
+var registry = map[string]Codec{
+	JSONSubprotocol:    JSONCodec{},
+	MsgpackSubprotocol: NewMsgpackCodec(),
+	ProtoSubprotocol:   ProtoCodec{},
+}
+
+// Subprotocols lists every supported subprotocol in server preference order
+func Subprotocols() []string {
+	return []string{JSONSubprotocol, MsgpackSubprotocol, ProtoSubprotocol}
+}
+
+// ForSubprotocol returns the codec for a negotiated subprotocol.
+// Clients that did not ask for a subprotocol get plain JSON text frames.
+func ForSubprotocol(subprotocol string) Codec {
+	if codec, ok := registry[subprotocol]; ok {
+		return codec
+	}
+	return JSONCodec{}
+}
@@ -0,0 +1,115 @@
+package codecs
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestForSubprotocol(t *testing.T) {
+	tests := []struct {
+		subprotocol string
+		want        string
+		frameType   int
+	}{
+		{"", JSONSubprotocol, websocket.TextMessage},
+		{"chat.v2.unknown", JSONSubprotocol, websocket.TextMessage},
+		{JSONSubprotocol, JSONSubprotocol, websocket.TextMessage},
+		{MsgpackSubprotocol, MsgpackSubprotocol, websocket.BinaryMessage},
+		{ProtoSubprotocol, ProtoSubprotocol, websocket.BinaryMessage},
+	}
+	for _, tt := range tests {
+		codec := ForSubprotocol(tt.subprotocol)
+		if codec.Subprotocol() != tt.want {
+			t.Errorf("ForSubprotocol(%q) = %s, want %s", tt.subprotocol, codec.Subprotocol(), tt.want)
+		}
+		if codec.FrameType() != tt.frameType {
+			t.Errorf("ForSubprotocol(%q).FrameType() = %d, want %d", tt.subprotocol, codec.FrameType(), tt.frameType)
+		}
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	values := []struct {
+		name string
+		in   interface{}
+		out  func() interface{}
+	}{
+		{
+			name: "inbound message",
+			in: &dtos.ChatMessageDto{
+				ClientMsgID:    "c-1",
+				ChatID:         "chat-1",
+				ReceiverUserID: "bob",
+				MessageType:    "text",
+				Message:        "héllo 👋",
+			},
+			out: func() interface{} { return &dtos.ChatMessageDto{} },
+		},
+		{
+			name: "outbound message",
+			in: &dtos.ChatMessageResponseDto{
+				Type:        "message",
+				EventID:     "e-1",
+				ChatID:      "chat-1",
+				Sender:      "alice",
+				MessageType: "text",
+				Message:     "hi",
+				Sequence:    42,
+			},
+			out: func() interface{} { return &dtos.ChatMessageResponseDto{} },
+		},
+		{
+			name: "ack with error",
+			in:   &dtos.AckResponseDto{Type: "ack", ClientMsgID: "c-2", Code: "rate_limited", Error: "slow down", RetryAfterMs: 1500},
+			out:  func() interface{} { return &dtos.AckResponseDto{} },
+		},
+	}
+
+	for _, subprotocol := range Subprotocols() {
+		codec := ForSubprotocol(subprotocol)
+		for _, v := range values {
+			t.Run(subprotocol+"/"+v.name, func(t *testing.T) {
+				data, err := codec.Encode(v.in)
+				if err != nil {
+					t.Fatalf("Encode: %v", err)
+				}
+				got := v.out()
+				if err := codec.Decode(data, got); err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				if !reflect.DeepEqual(got, v.in) {
+					t.Errorf("round trip = %+v, want %+v", got, v.in)
+				}
+			})
+		}
+	}
+}
+
+func TestCodecsUseJsonFieldNames(t *testing.T) {
+	for _, subprotocol := range []string{MsgpackSubprotocol, ProtoSubprotocol} {
+		codec := ForSubprotocol(subprotocol)
+		data, err := codec.Encode(map[string]interface{}{"chat_id": "chat-1", "receiver_user_id": "bob", "message": "hi"})
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", subprotocol, err)
+		}
+		var message dtos.ChatMessageDto
+		if err := codec.Decode(data, &message); err != nil {
+			t.Fatalf("%s: Decode: %v", subprotocol, err)
+		}
+		if message.ChatID != "chat-1" || message.ReceiverUserID != "bob" || message.Message != "hi" {
+			t.Errorf("%s: decoded %+v", subprotocol, message)
+		}
+	}
+}
+
+func TestCodecsRejectGarbage(t *testing.T) {
+	for _, subprotocol := range Subprotocols() {
+		var message dtos.ChatMessageDto
+		if err := ForSubprotocol(subprotocol).Decode([]byte{0xc1, 0xff, 0x00}, &message); err == nil {
+			t.Errorf("%s: decoding garbage succeeded", subprotocol)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package codecs
+
+import (
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+)
+
+// JSONCodec is the original text frame format
+type JSONCodec struct{}
+
+func (JSONCodec) Subprotocol() string { return JSONSubprotocol }
+
+func (JSONCodec) FrameType() int { return websocket.TextMessage }
+
+func (JSONCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Decode(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
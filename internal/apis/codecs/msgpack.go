@@ -0,0 +1,33 @@
+package codecs
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// MsgpackCodec encodes frames as MessagePack binary frames.
+// Struct fields use the same names as their json tags.
+type MsgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func NewMsgpackCodec() *MsgpackCodec {
+	handle := &codec.MsgpackHandle{}
+	handle.RawToString = true
+	handle.WriteExt = true
+	return &MsgpackCodec{handle: handle}
+}
+
+func (c *MsgpackCodec) Subprotocol() string { return MsgpackSubprotocol }
+
+func (c *MsgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (c *MsgpackCodec) Encode(v interface{}) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, c.handle).Encode(v)
+	return data, err
+}
+
+func (c *MsgpackCodec) Decode(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, c.handle).Decode(v)
+}
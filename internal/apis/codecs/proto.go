@@ -0,0 +1,48 @@
+package codecs
+
+import (
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ProtoCodec encodes frames as a protobuf google.protobuf.Struct, so clients
+// only need the well-known types to read them. Field names follow the json tags.
+type ProtoCodec struct{}
+
+func (ProtoCodec) Subprotocol() string { return ProtoSubprotocol }
+
+func (ProtoCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (ProtoCodec) Encode(v interface{}) ([]byte, error) {
+	// Round trip through JSON to get a generic map honouring the json tags
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(jsonData, &fields); err != nil {
+		return nil, err
+	}
+
+	message, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(message)
+}
+
+func (ProtoCodec) Decode(data []byte, v interface{}) error {
+	var message structpb.Struct
+	if err := proto.Unmarshal(data, &message); err != nil {
+		return err
+	}
+
+	jsonData, err := json.Marshal(message.AsMap())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, v)
+}
@@ -0,0 +1,5 @@
+package dtos
+
+type DisconnectUserDto struct {
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,31 @@
+package dtos
+
+import "distributed-chat-system/internal/models"
+
+type CreateBotDto struct {
+	ID         string   `json:"id" binding:"required"`
+	Name       string   `json:"name" binding:"required"`
+	Transport  string   `json:"transport" binding:"required,oneof=webhook stream"`
+	WebhookURL string   `json:"webhook_url" binding:"required_if=Transport webhook,omitempty,url"`
+	Commands   []string `json:"commands"`
+}
+
+// BotReplyDto is the body a webhook bot may answer an invocation with
+type BotReplyDto struct {
+	Reply string `json:"reply"`
+}
+
+// BotStreamFrameDto is what the server sends on a gRPC bot stream
+type BotStreamFrameDto struct {
+	Type         string                `json:"type"` // bot_invocation or error
+	Invocation   *models.BotInvocation `json:"invocation,omitempty"`
+	InvocationID string                `json:"invocation_id,omitempty"` // Reply an error frame is about
+	Error        string                `json:"error,omitempty"`
+	RetryAfterMs int64                 `json:"retry_after_ms,omitempty"` // Set when the reply hit a rate limit
+}
+
+// BotStreamReplyDto is what a gRPC stream bot sends to answer an invocation
+type BotStreamReplyDto struct {
+	InvocationID string `json:"invocation_id"`
+	Reply        string `json:"reply"`
+}
@@ -0,0 +1,12 @@
+package dtos
+
+type AddChatMemberDto struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// SendMessageDto sends a message over REST. Without a receiver it goes to every member of the chat.
+type SendMessageDto struct {
+	ReceiverUserID string `json:"receiver_user_id"`
+	MessageType    string `json:"message_type"`
+	Message        string `json:"message" binding:"required"`
+}
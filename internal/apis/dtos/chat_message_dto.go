@@ -1,8 +1,77 @@
 package dtos
 
+import (
+	"distributed-chat-system/internal/constants"
+	"fmt"
+)
+
 type ChatMessageDto struct {
+	Type           string `json:"type,omitempty"`          // message (default) or read
+	EventID        string `json:"event_id,omitempty"`      // Message being acknowledged by a read frame
+	ClientMsgID    string `json:"client_msg_id,omitempty"` // Set by clients that want an ack frame back
 	ChatID         string `json:"chat_id"`
 	ReceiverUserID string `json:"receiver_user_id"`
 	MessageType    string `json:"message_type"`
 	Message        string `json:"message"`
 }
+
+// String keeps end-to-end encrypted payloads out of logs
+func (m ChatMessageDto) String() string {
+	message := m.Message
+	if m.MessageType == constants.MessageTypeCiphertext {
+		message = fmt.Sprintf("<ciphertext, %d bytes>", len(message))
+	}
+	return fmt.Sprintf("{Type:%s EventID:%s ClientMsgID:%s ChatID:%s ReceiverUserID:%s MessageType:%s Message:%s}",
+		m.Type, m.EventID, m.ClientMsgID, m.ChatID, m.ReceiverUserID, m.MessageType, message)
+}
+
+type ChatMessageResponseDto struct {
+	Type        string `json:"type"`
+	EventID     string `json:"event_id"`
+	ChatID      string `json:"chat_id"`
+	Sender      string `json:"sender"`
+	MessageType string `json:"message_type"`
+	Message     string `json:"message"`
+	Sequence    int64  `json:"sequence,omitempty"` // Present this as resume_from when reconnecting
+}
+
+type ErrorResponseDto struct {
+	Type         string `json:"type"`
+	Code         string `json:"code,omitempty"` // Set for denied sends, e.g. not_member, blocked or rate_limited
+	Error        string `json:"error"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"` // Set for rate_limited
+}
+
+func NewErrorResponse(message string) ErrorResponseDto {
+	return ErrorResponseDto{Type: constants.FrameTypeError, Error: message}
+}
+
+func NewCodedErrorResponse(code, message string) ErrorResponseDto {
+	return ErrorResponseDto{Type: constants.FrameTypeError, Code: code, Error: message}
+}
+
+// AckResponseDto confirms (or rejects) a message frame sent with a client_msg_id
+type AckResponseDto struct {
+	Type         string `json:"type"`
+	ClientMsgID  string `json:"client_msg_id"`
+	EventID      string `json:"event_id,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Code         string `json:"code,omitempty"` // Set when the policy or a rate limit rejected the message
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+// ReceiptResponseDto tells a sender that their message was delivered or read
+type ReceiptResponseDto struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	EventID string `json:"event_id"`
+	ChatID  string `json:"chat_id"`
+	UserID  string `json:"user_id"`
+}
+
+// ResumedResponseDto tells a resuming client that replay is done
+type ResumedResponseDto struct {
+	Type         string `json:"type"`
+	Replayed     int    `json:"replayed"`
+	LastSequence int64  `json:"last_sequence"`
+}
@@ -0,0 +1,6 @@
+package dtos
+
+type UpdateDigestPreferencesDto struct {
+	Email  string `json:"email" binding:"omitempty,email"`
+	OptOut bool   `json:"opt_out"`
+}
@@ -0,0 +1,17 @@
+package dtos
+
+type PreKeyDto struct {
+	KeyID     int64  `json:"key_id" binding:"min=0"`
+	PublicKey string `json:"public_key" binding:"required,base64"`
+	Signature string `json:"signature" binding:"omitempty,base64"`
+}
+
+type PublishKeysDto struct {
+	IdentityKey    string      `json:"identity_key" binding:"required,base64"`
+	SignedPreKey   PreKeyDto   `json:"signed_pre_key" binding:"required"`
+	OneTimePreKeys []PreKeyDto `json:"one_time_pre_keys" binding:"max=100,dive"`
+}
+
+type UploadPreKeysDto struct {
+	OneTimePreKeys []PreKeyDto `json:"one_time_pre_keys" binding:"required,min=1,max=100,dive"`
+}
@@ -0,0 +1,8 @@
+package dtos
+
+import "distributed-chat-system/internal/models"
+
+type ModerationRulesDto struct {
+	Rules            []models.ModerationRule `json:"rules" binding:"required,dive"`
+	RejectCiphertext bool                    `json:"reject_ciphertext"`
+}
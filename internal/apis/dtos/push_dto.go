@@ -0,0 +1,14 @@
+package dtos
+
+import "time"
+
+type RegisterDeviceDto struct {
+	Token    string `json:"token" binding:"required"`
+	Platform string `json:"platform" binding:"required,oneof=android ios"`
+}
+
+type UpdateNotificationSettingsDto struct {
+	MutedChats        []string   `json:"muted_chats"`
+	DoNotDisturb      bool       `json:"do_not_disturb"`
+	DoNotDisturbUntil *time.Time `json:"do_not_disturb_until"`
+}
@@ -0,0 +1,31 @@
+package dtos
+
+// CreateReportDto reports a received message by its event_id, or a user
+type CreateReportDto struct {
+	EventID        string `json:"event_id"`
+	ReportedUserID string `json:"reported_user_id"`
+	Reason         string `json:"reason" binding:"required,max=64"`
+	Details        string `json:"details" binding:"max=2000"`
+}
+
+type UpdateReportDto struct {
+	Status string `json:"status" binding:"required,oneof=open reviewing actioned dismissed"`
+	Note   string `json:"note" binding:"max=2000"`
+}
+
+// ReportActionDto applies an action to a report. Duration limits mutes and bans, e.g. "24h"; empty is permanent.
+type ReportActionDto struct {
+	Action   string `json:"action" binding:"required,oneof=delete_message warn mute ban"`
+	Duration string `json:"duration"`
+	Reason   string `json:"reason" binding:"max=2000"`
+}
+
+// ModerationResponseDto tells a client that a moderator acted on them or on a message they received
+type ModerationResponseDto struct {
+	Type    string `json:"type"`
+	Action  string `json:"action"` // delete_message, warn, mute or ban
+	Reason  string `json:"reason,omitempty"`
+	EventID string `json:"event_id,omitempty"` // delete_message: the message to remove
+	ChatID  string `json:"chat_id,omitempty"`
+	Until   string `json:"until,omitempty"` // mute: RFC 3339 end, empty when permanent
+}
@@ -0,0 +1,31 @@
+package dtos
+
+import "distributed-chat-system/internal/models"
+
+type CreateServiceAccountDto struct {
+	ID     string   `json:"id" binding:"required"`
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=send read_history admin"`
+	Tenant string   `json:"tenant"`
+}
+
+type UpdateServiceAccountDto struct {
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=send read_history admin"`
+}
+
+// RotateAPIKeyDto sets how long the account's current keys keep working, e.g. "1h". Empty revokes them at once.
+type RotateAPIKeyDto struct {
+	Grace string `json:"grace"`
+}
+
+// APIKeyResponseDto carries a new key's secret, which is not shown again
+type APIKeyResponseDto struct {
+	Key    string        `json:"api_key"`
+	APIKey models.APIKey `json:"key"`
+}
+
+// ServiceAccountResponseDto is an account with its keys
+type ServiceAccountResponseDto struct {
+	models.ServiceAccount
+	Keys []models.APIKey `json:"keys"`
+}
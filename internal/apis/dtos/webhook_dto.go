@@ -0,0 +1,7 @@
+package dtos
+
+type CreateWebhookDto struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Secret string   `json:"secret" binding:"required,min=16"`
+	Events []string `json:"events"` // Omit to receive every event
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	chatService *services.ChatMessageService
+	audit       *services.AuditService
+}
+
+func NewAdminHandler(chatService *services.ChatMessageService, audit *services.AuditService) *AdminHandler {
+	return &AdminHandler{chatService: chatService, audit: audit}
+}
+
+// ListConnections lists sockets per server, optionally filtered with ?server_id=
+func (h *AdminHandler) ListConnections(c *gin.Context) {
+	snapshots, err := h.chatService.ListConnections(c.Query("server_id"))
+	if err != nil {
+		log.Println("Error listing connections:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list connections"})
+		return
+	}
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// LookupUserServer returns the server currently holding a user's socket
+func (h *AdminHandler) LookupUserServer(c *gin.Context) {
+	userID := c.Param("user_id")
+	serverID := h.chatService.LookupUserChatServer(userID)
+	if serverID == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrUserNotConnected.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "server_id": *serverID})
+}
+
+// DisconnectUser closes a user's socket on whichever server holds it
+func (h *AdminHandler) DisconnectUser(c *gin.Context) {
+	var request dtos.DisconnectUserDto
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if request.Reason == "" {
+		request.Reason = "disconnected by administrator"
+	}
+
+	userID := c.Param("user_id")
+	serverID, err := h.chatService.ForceDisconnect(userID, request.Reason)
+	if errors.Is(err, services.ErrUserNotConnected) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error requesting disconnect:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disconnect user"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditUserDisconnected, userID, map[string]string{"reason": request.Reason, "server_id": serverID}))
+	c.JSON(http.StatusAccepted, gin.H{"user_id": userID, "server_id": serverID})
+}
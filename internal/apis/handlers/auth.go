@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	accessTokenQueryParam = "access_token"
+	accessTokenContextKey = "access_token"
+)
+
+// StripQueryToken moves an access_token query parameter into the request context before
+// the request logger runs, so tokens never end up in access logs
+func StripQueryToken(c *gin.Context) {
+	query := c.Request.URL.Query()
+	if token := query.Get(accessTokenQueryParam); token != "" {
+		c.Set(accessTokenContextKey, token)
+		query.Del(accessTokenQueryParam)
+		c.Request.URL.RawQuery = query.Encode()
+	}
+	c.Next()
+}
+
+// requestToken finds the bearer token of a request in the Authorization header, the
+// access_token query parameter or a "bearer.<token>" WebSocket subprotocol
+func requestToken(c *gin.Context) string {
+	if token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+		return strings.TrimSpace(token)
+	}
+	if token := c.GetString(accessTokenContextKey); token != "" {
+		return token
+	}
+	if token := c.Query(accessTokenQueryParam); token != "" {
+		return token
+	}
+	for _, protocol := range websocket.Subprotocols(c.Request) {
+		if token, found := strings.CutPrefix(protocol, constants.AuthSubprotocolPrefix); found {
+			return token
+		}
+	}
+	return ""
+}
+
+// requestAPIKey finds a service account API key in the X-API-Key header, or wherever
+// requestToken looks for tokens
+func requestAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if token := requestToken(c); strings.HasPrefix(token, constants.APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+const (
+	userIDContextKey  = "user_id"
+	serviceContextKey = "service"
+	rolesContextKey   = "roles"
+	tenantContextKey  = "tenant"
+)
+
+type AuthHandler struct {
+	authService     *services.AuthService
+	serviceAccounts *services.ServiceAccountService
+}
+
+func NewAuthHandler(authService *services.AuthService, serviceAccounts *services.ServiceAccountService) *AuthHandler {
+	return &AuthHandler{authService: authService, serviceAccounts: serviceAccounts}
+}
+
+// RequireUser authenticates REST requests by their bearer token. Services with a client
+// certificate act for the user named in the X-User-ID header, as does everyone when
+// authentication is disabled. Service accounts with the send scope act as themselves.
+func (h *AuthHandler) RequireUser(c *gin.Context) {
+	if apiKey := requestAPIKey(c); apiKey != "" {
+		if h.authenticateAPIKey(c, apiKey, constants.ScopeSend) {
+			c.Next()
+		}
+		return
+	}
+	if service, ok := h.service(c); ok {
+		if c.GetHeader("X-User-ID") == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "services must name the user in X-User-ID"})
+			return
+		}
+		c.Set(serviceContextKey, service)
+		c.Set(userIDContextKey, c.GetHeader("X-User-ID"))
+		c.Next()
+		return
+	}
+	if !h.authService.Enabled() {
+		c.Set(userIDContextKey, c.GetHeader("X-User-ID"))
+		c.Next()
+		return
+	}
+
+	claims, err := h.authService.Authenticate(requestToken(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	c.Set(userIDContextKey, claims.Subject)
+	c.Set(rolesContextKey, claims.Roles)
+	c.Set(tenantContextKey, claims.Tenant)
+	c.Next()
+}
+
+// RequireSelfOrScope is RequireSelf that also admits service accounts holding the scope,
+// acting on any user's resources
+func (h *AuthHandler) RequireSelfOrScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := requestAPIKey(c); apiKey != "" {
+			if h.authenticateAPIKey(c, apiKey, scope) {
+				c.Next()
+			}
+			return
+		}
+		h.RequireSelf(c)
+	}
+}
+
+// RequireSelf only lets users act on their own :user_id resources. Services with a
+// client certificate may act on any user's.
+func (h *AuthHandler) RequireSelf(c *gin.Context) {
+	if service, ok := h.service(c); ok {
+		c.Set(serviceContextKey, service)
+		c.Set(userIDContextKey, c.Param("user_id"))
+		c.Next()
+		return
+	}
+	if !h.authService.Enabled() {
+		c.Next()
+		return
+	}
+
+	claims, err := h.authService.AuthenticateUser(requestToken(c), c.Param("user_id"))
+	if errors.Is(err, services.ErrTokenMismatch) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	c.Set(userIDContextKey, claims.Subject)
+	c.Next()
+}
+
+// RequireService reserves admin routes for services with a client certificate, service
+// accounts with the admin scope, the ADMIN_BOOTSTRAP_KEY and users whose token carries
+// the admin role. With ADMIN_REQUIRE_SERVICE users are refused too. Only AUTH_DISABLED
+// leaves them open.
+func (h *AuthHandler) RequireService(c *gin.Context) {
+	if apiKey := requestAPIKey(c); apiKey != "" {
+		if h.authService.BootstrapAdmin(apiKey) {
+			log.Printf("Bootstrap key: %s %s from %s", c.Request.Method, c.Request.URL.Path, c.ClientIP())
+			c.Set(serviceContextKey, bootstrapActor)
+			c.Next()
+			return
+		}
+		if h.authenticateAPIKey(c, apiKey, constants.ScopeAdmin) {
+			c.Next()
+		}
+		return
+	}
+	if service, ok := h.service(c); ok {
+		c.Set(serviceContextKey, service)
+		c.Next()
+		return
+	}
+	if h.authService.AdminRequiresService() {
+		log.Printf("Rejected %s %s from %s without a service certificate", c.Request.Method, c.Request.URL.Path, c.ClientIP())
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "a service client certificate is required"})
+		return
+	}
+	if !h.authService.Enabled() {
+		c.Next()
+		return
+	}
+
+	claims, err := h.authService.Authenticate(requestToken(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if !slices.Contains(claims.Roles, constants.RoleAdmin) {
+		log.Printf("Rejected %s %s by %s without the admin role", c.Request.Method, c.Request.URL.Path, claims.Subject)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "the admin role is required"})
+		return
+	}
+	c.Set(userIDContextKey, claims.Subject)
+	c.Set(rolesContextKey, claims.Roles)
+	c.Set(tenantContextKey, claims.Tenant)
+	c.Next()
+}
+
+// service identifies a caller by the client certificate the TLS handshake verified
+func (h *AuthHandler) service(c *gin.Context) (string, bool) {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		return "", false
+	}
+	return h.authService.ServiceIdentity(c.Request.TLS.VerifiedChains[0][0])
+}
+
+// bootstrapActor names the holder of the ADMIN_BOOTSTRAP_KEY in audit records
+const bootstrapActor = "bootstrap"
+
+// authenticateAPIKey admits a service account holding the scope, aborting the request
+// otherwise. The account is the caller, as service-account:<id> so that it can't be
+// mistaken for a user, and the actor recorded for what it does.
+func (h *AuthHandler) authenticateAPIKey(c *gin.Context, apiKey, scope string) bool {
+	account, err := h.serviceAccounts.Authenticate(apiKey, scope)
+	if errors.Is(err, services.ErrScopeDenied) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return false
+	}
+
+	actor := services.ServiceAccountActor(account.ID)
+	c.Set(userIDContextKey, actor)
+	c.Set(serviceContextKey, actor)
+	c.Set(rolesContextKey, []string{constants.RoleService})
+	c.Set(tenantContextKey, account.Tenant)
+	log.Printf("Service account %s: %s %s", account.ID, c.Request.Method, c.Request.URL.Path)
+	return true
+}
+
+// authenticatedUser is the caller resolved by RequireUser
+func authenticatedUser(c *gin.Context) string {
+	return c.GetString(userIDContextKey)
+}
+
+// requestActor names the caller in audit records: the service or service account that
+// authenticated the request, else the user
+func requestActor(c *gin.Context) string {
+	if service := c.GetString(serviceContextKey); service != "" {
+		return service
+	}
+	if user := authenticatedUser(c); user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+// authenticatedSender is the caller resolved by RequireUser, as the sender of a message
+func authenticatedSender(c *gin.Context) services.Sender {
+	return services.Sender{
+		UserID: authenticatedUser(c),
+		Roles:  c.GetStringSlice(rolesContextKey),
+		Tenant: c.GetString(tenantContextKey),
+	}
+}
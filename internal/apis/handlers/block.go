@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BlockHandler struct {
+	blockService *services.BlockService
+}
+
+func NewBlockHandler(blockService *services.BlockService) *BlockHandler {
+	return &BlockHandler{blockService: blockService}
+}
+
+func (h *BlockHandler) ListBlocked(c *gin.Context) {
+	blocked, err := h.blockService.Blocked(c.Param("user_id"))
+	if err != nil {
+		log.Println("Error listing blocked users:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list blocked users"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": c.Param("user_id"), "blocked": blocked})
+}
+
+func (h *BlockHandler) Block(c *gin.Context) {
+	if c.Param("user_id") == c.Param("blocked_user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "users cannot block themselves"})
+		return
+	}
+	if err := h.blockService.Block(c.Param("user_id"), c.Param("blocked_user_id")); err != nil {
+		log.Println("Error blocking user:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to block user"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *BlockHandler) Unblock(c *gin.Context) {
+	if err := h.blockService.Unblock(c.Param("user_id"), c.Param("blocked_user_id")); err != nil {
+		log.Println("Error unblocking user:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unblock user"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
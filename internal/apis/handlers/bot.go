@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BotHandler struct {
+	botService *services.BotService
+	audit      *services.AuditService
+}
+
+func NewBotHandler(botService *services.BotService, audit *services.AuditService) *BotHandler {
+	return &BotHandler{botService: botService, audit: audit}
+}
+
+// CreateBot registers a bot; the secret is only returned in this response
+func (h *BotHandler) CreateBot(c *gin.Context) {
+	var request dtos.CreateBotDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bot, err := h.botService.RegisterBot(request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	details := map[string]string{"transport": bot.Transport}
+	if bot.WebhookURL != "" {
+		details["webhook_url"] = bot.WebhookURL
+	}
+	h.audit.Record(auditEntry(c, constants.AuditBotRegistered, bot.ID, details))
+	c.JSON(http.StatusCreated, bot)
+}
+
+func (h *BotHandler) ListBots(c *gin.Context) {
+	bots, err := h.botService.ListBots()
+	if err != nil {
+		log.Println("Error listing bots:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list bots"})
+		return
+	}
+
+	response := make([]models.Bot, 0, len(bots))
+	for _, bot := range bots {
+		bot.Secret = ""
+		response = append(response, bot)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *BotHandler) DeleteBot(c *gin.Context) {
+	err := h.botService.DeleteBot(c.Param("id"))
+	if errors.Is(err, services.ErrBotNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error deleting bot:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete bot"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditBotDeleted, c.Param("id"), nil))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChatHandler struct {
+	memberships *services.MembershipService
+	audit       *services.AuditService
+}
+
+func NewChatHandler(memberships *services.MembershipService, audit *services.AuditService) *ChatHandler {
+	return &ChatHandler{memberships: memberships, audit: audit}
+}
+
+// ListMembers lists the members of a group chat to its members
+func (h *ChatHandler) ListMembers(c *gin.Context) {
+	members, err := h.memberships.Members(c.Param("chat_id"))
+	if err != nil {
+		log.Println("Error listing chat members:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list chat members"})
+		return
+	}
+	if len(members) > 0 && !slices.Contains(members, authenticatedUser(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrNotChatMember.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"chat_id": c.Param("chat_id"), "members": members})
+}
+
+// AddMember invites a user into a group chat, creating it when it has no members yet
+func (h *ChatHandler) AddMember(c *gin.Context) {
+	var request dtos.AddChatMemberDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.memberships.Invite(c.Param("chat_id"), authenticatedUser(c), request.UserID)
+	if errors.Is(err, services.ErrNotChatMember) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrDirectChat) || errors.Is(err, services.ErrChatHasTraffic) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error adding chat member:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add chat member"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditChatMemberAdded, c.Param("chat_id"), map[string]string{"user_id": request.UserID}))
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveMember lets users leave a group chat
+func (h *ChatHandler) RemoveMember(c *gin.Context) {
+	if c.Param("user_id") != authenticatedUser(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "users can only remove themselves"})
+		return
+	}
+	if err := h.memberships.RemoveMember(c.Param("chat_id"), c.Param("user_id")); err != nil {
+		log.Println("Error removing chat member:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove chat member"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditChatMemberRemoved, c.Param("chat_id"), map[string]string{"user_id": c.Param("user_id")}))
+	c.Status(http.StatusNoContent)
+}
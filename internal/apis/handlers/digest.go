@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DigestHandler struct {
+	digestService *services.DigestService
+}
+
+func NewDigestHandler(digestService *services.DigestService) *DigestHandler {
+	return &DigestHandler{digestService: digestService}
+}
+
+func (h *DigestHandler) GetPreferences(c *gin.Context) {
+	preferences, err := h.digestService.GetPreferences(c.Param("user_id"))
+	if err != nil {
+		log.Println("Error loading digest preferences:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load digest preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, preferences)
+}
+
+func (h *DigestHandler) UpdatePreferences(c *gin.Context) {
+	var request dtos.UpdateDigestPreferencesDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preferences, err := h.digestService.UpdatePreferences(c.Param("user_id"), request)
+	if err != nil {
+		log.Println("Error updating digest preferences:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update digest preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, preferences)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EncryptionHandler struct {
+	encryption *services.EncryptionService
+	audit      *services.AuditService
+}
+
+func NewEncryptionHandler(encryption *services.EncryptionService, audit *services.AuditService) *EncryptionHandler {
+	return &EncryptionHandler{encryption: encryption, audit: audit}
+}
+
+// GetKeys lists the versions of a tenant's data key, without the keys themselves
+func (h *EncryptionHandler) GetKeys(c *gin.Context) {
+	ring, err := h.encryption.KeyRing(c.Param("tenant"))
+	if errors.Is(err, services.ErrDataKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant has no data key"})
+		return
+	}
+	if err != nil {
+		log.Println("Error loading key ring:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load key ring"})
+		return
+	}
+	c.JSON(http.StatusOK, keyRingResponse(ring))
+}
+
+// RotateDataKey makes a new version of a tenant's data key active
+func (h *EncryptionHandler) RotateDataKey(c *gin.Context) {
+	ring, err := h.encryption.RotateDataKey(c.Param("tenant"))
+	if errors.Is(err, services.ErrKeyRingBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error rotating data key:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate data key"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditDataKeyRotated, ring.Tenant, map[string]string{"version": strconv.Itoa(ring.ActiveVersion)}))
+	c.JSON(http.StatusOK, keyRingResponse(ring))
+}
+
+// RotateMasterKey makes a new KMS master key active and rewraps every tenant's data keys
+func (h *EncryptionHandler) RotateMasterKey(c *gin.Context) {
+	masterKeyID, rewrapped, err := h.encryption.RotateMasterKey()
+	if masterKeyID != "" {
+		h.audit.Record(auditEntry(c, constants.AuditMasterKeyRotated, "kms", map[string]string{"master_key_id": masterKeyID, "tenants_rewrapped": strconv.Itoa(rewrapped)}))
+	}
+	if err != nil {
+		// Tenants not rewrapped yet keep working with the previous master key; retrying finishes them
+		log.Println("Error rotating master key:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate master key", "master_key_id": masterKeyID, "tenants_rewrapped": rewrapped})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"master_key_id": masterKeyID, "tenants_rewrapped": rewrapped})
+}
+
+// StartReencryption starts moving a tenant's stored data onto its active data key;
+// poll GetReencryption for progress
+func (h *EncryptionHandler) StartReencryption(c *gin.Context) {
+	job, err := h.encryption.Reencrypt(c.Param("tenant"), requestActor(c))
+	switch {
+	case errors.Is(err, services.ErrDataKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant has no data key"})
+		return
+	case errors.Is(err, services.ErrReencryptionRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Println("Error starting re-encryption:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start re-encryption"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditReencryptionStarted, job.Tenant, map[string]string{"version": strconv.Itoa(job.KeyVersion)}))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetReencryption reports the last re-encryption of a tenant
+func (h *EncryptionHandler) GetReencryption(c *gin.Context) {
+	job, err := h.encryption.Reencryption(c.Param("tenant"))
+	if errors.Is(err, services.ErrNoReencryptionJob) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error loading re-encryption job:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load re-encryption job"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// Shred deletes a tenant's data keys, making everything stored for it unreadable.
+// It can't be undone, so the tenant must be repeated as ?confirm=.
+func (h *EncryptionHandler) Shred(c *gin.Context) {
+	tenant := c.Param("tenant")
+	if c.Query("confirm") != tenant {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "repeat the tenant as ?confirm= to shred its data keys"})
+		return
+	}
+	err := h.encryption.Shred(tenant)
+	switch {
+	case errors.Is(err, services.ErrDataKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant has no data key"})
+		return
+	case errors.Is(err, services.ErrKeyRingBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Println("Error shredding data keys:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to shred data keys"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditTenantShredded, tenant, nil))
+	c.Status(http.StatusNoContent)
+}
+
+func keyRingResponse(ring *models.TenantKeyRing) gin.H {
+	versions := make([]gin.H, 0, len(ring.Keys))
+	for _, key := range ring.Keys {
+		versions = append(versions, gin.H{"version": key.Version, "master_key_id": key.MasterKeyID, "created_at": key.CreatedAt})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i]["version"].(int) < versions[j]["version"].(int) })
+	return gin.H{
+		"tenant":         ring.Tenant,
+		"active_version": ring.ActiveVersion,
+		"versions":       versions,
+		"updated_at":     ring.UpdatedAt,
+	}
+}
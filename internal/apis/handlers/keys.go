@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyHandler struct {
+	keyDirectory *services.KeyDirectoryService
+}
+
+func NewKeyHandler(keyDirectory *services.KeyDirectoryService) *KeyHandler {
+	return &KeyHandler{keyDirectory: keyDirectory}
+}
+
+// PublishKeys stores a device's identity key, signed prekey and one-time prekeys
+func (h *KeyHandler) PublishKeys(c *gin.Context) {
+	var request dtos.PublishKeysDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	keys, err := h.keyDirectory.Publish(c.Param("user_id"), c.Param("device_id"), request)
+	if errors.Is(err, services.ErrUnsignedPreKey) || errors.Is(err, services.ErrInvalidDeviceID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error publishing keys:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to publish keys"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// UploadPreKeys adds one-time prekeys to a device that published its keys
+func (h *KeyHandler) UploadPreKeys(c *gin.Context) {
+	var request dtos.UploadPreKeysDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	remaining, err := h.keyDirectory.AddPreKeys(c.Param("user_id"), c.Param("device_id"), request.OneTimePreKeys)
+	if errors.Is(err, services.ErrDeviceKeysNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error uploading prekeys:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload prekeys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"one_time_pre_keys": remaining})
+}
+
+// GetDeviceKeys shows a device what it has published and how many one-time prekeys are left
+func (h *KeyHandler) GetDeviceKeys(c *gin.Context) {
+	keys, err := h.keyDirectory.DeviceKeys(c.Param("user_id"), c.Param("device_id"))
+	if errors.Is(err, services.ErrDeviceKeysNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error loading device keys:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load device keys"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *KeyHandler) RemoveDeviceKeys(c *gin.Context) {
+	err := h.keyDirectory.RemoveDevice(c.Param("user_id"), c.Param("device_id"))
+	if errors.Is(err, services.ErrDeviceKeysNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error removing device keys:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove device keys"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// FetchBundles hands out a prekey bundle for every device of a peer
+func (h *KeyHandler) FetchBundles(c *gin.Context) {
+	bundles, err := h.keyDirectory.Bundles(c.Param("user_id"))
+	if err != nil {
+		log.Println("Error fetching prekey bundles:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch prekey bundles"})
+		return
+	}
+	if len(bundles) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user has not published any keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": c.Param("user_id"), "devices": bundles})
+}
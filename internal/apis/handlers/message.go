@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxHistoryLimit = 1000
+
+// MessageHandler sends and reads messages over REST, for integrations without a socket
+type MessageHandler struct {
+	chatService  *services.ChatMessageService
+	inboxService *services.InboxService
+}
+
+func NewMessageHandler(chatService *services.ChatMessageService, inboxService *services.InboxService) *MessageHandler {
+	return &MessageHandler{chatService: chatService, inboxService: inboxService}
+}
+
+// SendMessage sends a message as the caller, to one receiver or to every member of a group chat
+func (h *MessageHandler) SendMessage(c *gin.Context) {
+	var request dtos.SendMessageDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.MessageType == "" {
+		request.MessageType = constants.MessageTypeText
+	}
+
+	sender := authenticatedSender(c)
+	chatID := c.Param("chat_id")
+	var eventID string
+	var err error
+	if request.ReceiverUserID == "" {
+		err = h.chatService.SendMessageToChat(sender, chatID, request.MessageType, request.Message)
+	} else {
+		eventID, err = h.chatService.SendMessageToUser(sender, dtos.ChatMessageDto{
+			ChatID:         chatID,
+			ReceiverUserID: request.ReceiverUserID,
+			MessageType:    request.MessageType,
+			Message:        request.Message,
+		})
+	}
+
+	var policyErr *services.PolicyError
+	var limitErr *services.RateLimitError
+	switch {
+	case errors.As(err, &limitErr):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": limitErr.Error(), "code": constants.ErrorRateLimited, "retry_after_ms": limitErr.RetryAfter.Milliseconds()})
+	case errors.As(err, &policyErr):
+		c.JSON(http.StatusForbidden, gin.H{"error": policyErr.Reason, "code": policyErr.Code})
+	case errors.Is(err, services.ErrUserNotConnected):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCiphertextToBot):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error sending message by %s to chat %s: %v", sender.UserID, chatID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send message"})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"event_id": eventID})
+	}
+}
+
+// ListMessages returns the messages stored in a user's inbox after ?after=<sequence>, oldest first
+func (h *MessageHandler) ListMessages(c *gin.Context) {
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil || after < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "after must be a non-negative integer"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > maxHistoryLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	messages, err := h.inboxService.Since(c.Param("user_id"), after)
+	if err != nil {
+		log.Println("Error reading message history:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read messages"})
+		return
+	}
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	c.JSON(http.StatusOK, messages)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModerationHandler struct {
+	moderation *services.ModerationService
+	audit      *services.AuditService
+}
+
+func NewModerationHandler(moderation *services.ModerationService, audit *services.AuditService) *ModerationHandler {
+	return &ModerationHandler{moderation: moderation, audit: audit}
+}
+
+func (h *ModerationHandler) GetRules(c *gin.Context) {
+	scope, id := moderationScope(c)
+	rules, err := h.moderation.GetRules(scope, id)
+	if errors.Is(err, services.ErrModerationRulesNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error loading moderation rules:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load moderation rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// SetRules replaces the moderation pipeline of a tenant or chat
+func (h *ModerationHandler) SetRules(c *gin.Context) {
+	var request dtos.ModerationRulesDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, id := moderationScope(c)
+	rules, err := h.moderation.SetRules(scope, id, request)
+	if errors.Is(err, services.ErrInvalidModerationRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error storing moderation rules:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store moderation rules"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditModerationRulesUpdated, scope+":"+id, map[string]string{"rules": strconv.Itoa(len(rules.Rules))}))
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *ModerationHandler) DeleteRules(c *gin.Context) {
+	scope, id := moderationScope(c)
+	err := h.moderation.DeleteRules(scope, id)
+	if errors.Is(err, services.ErrModerationRulesNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error deleting moderation rules:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete moderation rules"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditModerationRulesDeleted, scope+":"+id, nil))
+	c.Status(http.StatusNoContent)
+}
+
+// ListHits returns recent moderation hits for review, optionally of one chat
+func (h *ModerationHandler) ListHits(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+
+	hits, err := h.moderation.Hits(c.Query("chat_id"), limit)
+	if err != nil {
+		log.Println("Error listing moderation hits:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list moderation hits"})
+		return
+	}
+	c.JSON(http.StatusOK, hits)
+}
+
+func moderationScope(c *gin.Context) (string, string) {
+	if chatID := c.Param("chat_id"); chatID != "" {
+		return constants.ModerationScopeChat, chatID
+	}
+	return constants.ModerationScopeTenant, c.Param("tenant_id")
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/utils"
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// originRejections counts requests refused for their Origin, keyed by "websocket" and "http"
+var originRejections = expvar.NewMap("origin_rejections")
+
+type OriginConfig struct {
+	AllowedOrigins   []string      // Exact origins or hosts, "*.example.com" for any subdomain, "*" for all
+	AllowCredentials bool          // Let browsers send cookies and Authorization on cross-origin REST calls
+	MaxAge           time.Duration // How long browsers may cache a preflight response
+}
+
+// OriginConfigFromEnv builds the origin policy from ALLOWED_ORIGINS and CORS_* environment variables
+func OriginConfigFromEnv() *OriginConfig {
+	var origins []string
+	for _, origin := range strings.Split(utils.GetEnvString("ALLOWED_ORIGINS", ""), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.ToLower(origin))
+		}
+	}
+	return &OriginConfig{
+		AllowedOrigins:   origins,
+		AllowCredentials: utils.GetEnvBool("CORS_ALLOW_CREDENTIALS", false),
+		MaxAge:           utils.GetEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+	}
+}
+
+// Validate refuses credentials for every origin, which would let any site call the API
+// with a visitor's cookies
+func (c *OriginConfig) Validate() error {
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		return errors.New("ALLOWED_ORIGINS=* can't be combined with CORS_ALLOW_CREDENTIALS=true")
+	}
+	return nil
+}
+
+// OriginHandler decides which browser origins may open sockets and call the REST API.
+// Requests without an Origin header don't come from a browser and are always allowed,
+// as are same-origin requests.
+type OriginHandler struct {
+	config *OriginConfig
+}
+
+func NewOriginHandler(config *OriginConfig) *OriginHandler {
+	if len(config.AllowedOrigins) == 0 {
+		log.Println("🚀 Initialized Origin policy : same origin only")
+	} else {
+		log.Println("🚀 Initialized Origin policy :", strings.Join(config.AllowedOrigins, ", "))
+	}
+	return &OriginHandler{config: config}
+}
+
+// Allowed reports whether a request's Origin is allowed
+func (h *OriginHandler) Allowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	if strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+	for _, pattern := range h.config.AllowedOrigins {
+		if matchOrigin(pattern, parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin matches an origin against "*", "https://app.example.com", "app.example.com:8443"
+// or "*.example.com". Patterns without a scheme accept any scheme, patterns without a port any port.
+func matchOrigin(pattern string, origin *url.URL) bool {
+	if pattern == "*" {
+		return true
+	}
+	if scheme, rest, found := strings.Cut(pattern, "://"); found {
+		if !strings.EqualFold(scheme, origin.Scheme) {
+			return false
+		}
+		pattern = rest
+	}
+
+	host := strings.ToLower(origin.Hostname())
+	if strings.Contains(pattern, ":") {
+		host = strings.ToLower(origin.Host)
+	}
+	if suffix, found := strings.CutPrefix(pattern, "*."); found {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// CheckOrigin is the WebSocket upgrader's origin check
+func (h *OriginHandler) CheckOrigin(r *http.Request) bool {
+	if h.Allowed(r) {
+		return true
+	}
+	originRejections.Add("websocket", 1)
+	log.Printf("Rejected WebSocket upgrade from origin %q (%s)", r.Header.Get("Origin"), r.RemoteAddr)
+	return false
+}
+
+// CORS answers preflight requests and sets the CORS headers of REST responses. Requests
+// from origins that aren't allowed are refused with 403. WebSocket upgrades are left to
+// CheckOrigin.
+func (h *OriginHandler) CORS(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" || websocket.IsWebSocketUpgrade(c.Request) {
+		c.Next()
+		return
+	}
+
+	c.Writer.Header().Add("Vary", "Origin")
+	if !h.Allowed(c.Request) {
+		originRejections.Add("http", 1)
+		log.Printf("Rejected %s %s from origin %q (%s)", c.Request.Method, c.Request.URL.Path, origin, c.ClientIP())
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "origin not allowed"})
+		return
+	}
+
+	c.Header("Access-Control-Allow-Origin", origin)
+	if h.config.AllowCredentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+
+	if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key, X-User-ID, X-Bot-Secret")
+		c.Header("Access-Control-Max-Age", strconv.Itoa(int(h.config.MaxAge.Seconds())))
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+	c.Next()
+}
+
+// Metrics serves the process counters, including origin rejections, as JSON
+func Metrics(c *gin.Context) {
+	expvar.Handler().ServeHTTP(c.Writer, c.Request)
+}
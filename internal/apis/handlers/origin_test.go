@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		pattern string
+		origin  string
+		want    bool
+	}{
+		{"*", "https://anything.example.org", true},
+		{"https://app.example.com", "https://app.example.com", true},
+		{"https://app.example.com", "http://app.example.com", false},
+		{"https://app.example.com", "https://app.example.com:8443", true},
+		{"https://app.example.com", "https://app.example.com.evil.com", false},
+		{"app.example.com", "http://app.example.com", true},
+		{"app.example.com", "https://APP.Example.com", true},
+		{"app.example.com", "https://other.example.com", false},
+		{"localhost:3000", "http://localhost:3000", true},
+		{"localhost:3000", "http://localhost:3001", false},
+		{"localhost:3000", "http://localhost", false},
+		{"*.example.com", "https://a.example.com", true},
+		{"*.example.com", "https://a.b.example.com", true},
+		{"*.example.com", "https://example.com", false},
+		{"*.example.com", "https://evilexample.com", false},
+		{"*.example.com", "https://example.com.evil.com", false},
+		{"https://*.example.com", "https://a.example.com", true},
+		{"https://*.example.com", "http://a.example.com", false},
+		{"*.example.com:8443", "https://a.example.com:8443", true},
+		{"*.example.com:8443", "https://a.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.origin, func(t *testing.T) {
+			origin, err := url.Parse(tt.origin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchOrigin(tt.pattern, origin); got != tt.want {
+				t.Errorf("matchOrigin(%q, %q) = %v, want %v", tt.pattern, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	handler := NewOriginHandler(&OriginConfig{AllowedOrigins: []string{"app.example.com"}})
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   bool
+	}{
+		{"no origin", "chat.example.com", "", true},
+		{"same origin", "chat.example.com", "https://chat.example.com", true},
+		{"listed origin", "chat.example.com", "https://app.example.com", true},
+		{"other origin", "chat.example.com", "https://evil.example.com", false},
+		{"opaque origin", "chat.example.com", "null", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/chats", nil)
+			if tt.origin != "" {
+				request.Header.Set("Origin", tt.origin)
+			}
+			if got := handler.Allowed(request); got != tt.want {
+				t.Errorf("Allowed() with origin %q = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  OriginConfig
+		wantErr bool
+	}{
+		{"wildcard without credentials", OriginConfig{AllowedOrigins: []string{"*"}}, false},
+		{"wildcard with credentials", OriginConfig{AllowedOrigins: []string{"app.example.com", "*"}, AllowCredentials: true}, true},
+		{"listed origins with credentials", OriginConfig{AllowedOrigins: []string{"*.example.com"}, AllowCredentials: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewOriginHandler(&OriginConfig{AllowedOrigins: []string{"app.example.com"}}).CORS)
+
+	request := httptest.NewRequest(http.MethodOptions, "http://chat.example.com/admin/service-accounts/billing", nil)
+	request.Header.Set("Origin", "https://app.example.com")
+	request.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+	request.Header.Set("Access-Control-Request-Headers", "X-API-Key")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want %d", recorder.Code, http.StatusNoContent)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q without AllowCredentials", got)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "X-API-Key") {
+		t.Errorf("Access-Control-Allow-Headers = %q, want X-API-Key", got)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, http.MethodPatch) {
+		t.Errorf("Access-Control-Allow-Methods = %q, want PATCH", got)
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PresenceHandler struct {
+	chatService *services.ChatMessageService
+}
+
+func NewPresenceHandler(chatService *services.ChatMessageService) *PresenceHandler {
+	return &PresenceHandler{chatService: chatService}
+}
+
+// GetPresence reports whether a user currently holds a socket on any server
+func (h *PresenceHandler) GetPresence(c *gin.Context) {
+	userID := c.Param("user_id")
+	online := h.chatService.LookupUserChatServer(userID) != nil
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "online": online})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrivacyHandler struct {
+	privacy *services.PrivacyService
+	audit   *services.AuditService
+}
+
+func NewPrivacyHandler(privacy *services.PrivacyService, audit *services.AuditService) *PrivacyHandler {
+	return &PrivacyHandler{privacy: privacy, audit: audit}
+}
+
+// StartExport starts collecting everything held about a user; poll the job for progress
+func (h *PrivacyHandler) StartExport(c *gin.Context) {
+	job, err := h.privacy.StartExport(c.Param("user_id"), requestActor(c))
+	if err != nil {
+		log.Println("Error starting export:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start export"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditUserExportRequested, job.UserID, map[string]string{"job_id": job.ID}))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// StartErasure starts deleting everything held about a user; poll the job for progress
+func (h *PrivacyHandler) StartErasure(c *gin.Context) {
+	job, err := h.privacy.StartErasure(c.Param("user_id"), requestActor(c))
+	if err != nil {
+		log.Println("Error starting erasure:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start erasure"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditUserErasureRequested, job.UserID, map[string]string{"job_id": job.ID}))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetJob reports the progress of an export or erasure
+func (h *PrivacyHandler) GetJob(c *gin.Context) {
+	job, err := h.privacy.Get(c.Param("job_id"))
+	if errors.Is(err, services.ErrPrivacyJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error loading privacy job:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load privacy job"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadArchive returns the zip archive of a completed export
+func (h *PrivacyHandler) DownloadArchive(c *gin.Context) {
+	archive, job, err := h.privacy.Archive(c.Param("job_id"))
+	switch {
+	case errors.Is(err, services.ErrPrivacyJobNotFound), errors.Is(err, services.ErrArchiveExpired):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrArchiveNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": job.Status})
+		return
+	case err != nil:
+		log.Println("Error loading export archive:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load export archive"})
+		return
+	}
+
+	h.audit.Record(auditEntry(c, constants.AuditUserExportDownloaded, job.UserID, map[string]string{"job_id": job.ID}))
+	c.Header("Content-Disposition", `attachment; filename="export-`+job.UserID+`-`+job.ID+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", archive)
+}
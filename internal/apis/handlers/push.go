@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PushHandler struct {
+	pushService *services.PushService
+}
+
+func NewPushHandler(pushService *services.PushService) *PushHandler {
+	return &PushHandler{pushService: pushService}
+}
+
+func (h *PushHandler) RegisterDevice(c *gin.Context) {
+	var request dtos.RegisterDeviceDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device, err := h.pushService.RegisterDevice(c.Param("user_id"), request)
+	if err != nil {
+		log.Println("Error registering device:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register device"})
+		return
+	}
+	c.JSON(http.StatusCreated, device)
+}
+
+func (h *PushHandler) ListDevices(c *gin.Context) {
+	devices, err := h.pushService.ListDevices(c.Param("user_id"))
+	if err != nil {
+		log.Println("Error listing devices:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list devices"})
+		return
+	}
+	c.JSON(http.StatusOK, devices)
+}
+
+func (h *PushHandler) UnregisterDevice(c *gin.Context) {
+	err := h.pushService.UnregisterDevice(c.Param("user_id"), c.Param("token"))
+	if errors.Is(err, services.ErrDeviceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error unregistering device:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unregister device"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PushHandler) GetSettings(c *gin.Context) {
+	settings, err := h.pushService.GetSettings(c.Param("user_id"))
+	if err != nil {
+		log.Println("Error loading notification settings:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load notification settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *PushHandler) UpdateSettings(c *gin.Context) {
+	var request dtos.UpdateNotificationSettingsDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.pushService.UpdateSettings(c.Param("user_id"), request)
+	if err != nil {
+		log.Println("Error updating notification settings:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notification settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
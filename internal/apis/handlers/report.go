@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	reportService *services.ReportService
+	sanctions     *services.SanctionService
+	audit         *services.AuditService
+}
+
+func NewReportHandler(reportService *services.ReportService, sanctions *services.SanctionService, audit *services.AuditService) *ReportHandler {
+	return &ReportHandler{reportService: reportService, sanctions: sanctions, audit: audit}
+}
+
+// CreateReport files a report by the authenticated user about a message they received or a user
+func (h *ReportHandler) CreateReport(c *gin.Context) {
+	var request dtos.CreateReportDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.reportService.Create(authenticatedUser(c), request)
+	if errors.Is(err, services.ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidReport) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error creating report:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create report"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": report.ID, "status": report.Status})
+}
+
+// ListReports returns the moderation queue, or the reports in ?status=
+func (h *ReportHandler) ListReports(c *gin.Context) {
+	status := c.Query("status")
+	statuses := []string{constants.ReportStatusOpen, constants.ReportStatusReviewing, constants.ReportStatusActioned, constants.ReportStatusDismissed}
+	if status != "" && !slices.Contains(statuses, status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown status"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+
+	reports, err := h.reportService.List(status, limit)
+	if err != nil {
+		log.Println("Error listing reports:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list reports"})
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+func (h *ReportHandler) GetReport(c *gin.Context) {
+	report, err := h.reportService.Get(c.Param("report_id"))
+	if errors.Is(err, services.ErrReportNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error loading report:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// UpdateReport moves a report to another status, e.g. reviewing or dismissed
+func (h *ReportHandler) UpdateReport(c *gin.Context) {
+	var request dtos.UpdateReportDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.reportService.UpdateStatus(c.Param("report_id"), request.Status, request.Note)
+	if errors.Is(err, services.ErrReportNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrReportTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error updating report:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ActOnReport deletes the reported message, or warns, mutes or bans the reported user
+func (h *ReportHandler) ActOnReport(c *gin.Context) {
+	var request dtos.ReportActionDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.reportService.Act(c.Param("report_id"), request, requestActor(c))
+	switch {
+	case errors.Is(err, services.ErrReportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDuration), errors.Is(err, services.ErrNoReportedMessage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReportDismissed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Println("Error acting on report:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to act on report"})
+	default:
+		h.audit.Record(auditEntry(c, constants.AuditReportAction, report.ReportedUserID, map[string]string{
+			"report_id": report.ID,
+			"action":    request.Action,
+			"duration":  request.Duration,
+			"reason":    request.Reason,
+		}))
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// ListSanctions returns a user's active mute and ban
+func (h *ReportHandler) ListSanctions(c *gin.Context) {
+	c.JSON(http.StatusOK, h.sanctions.List(c.Param("user_id")))
+}
+
+// LiftSanction ends a user's mute or ban early
+func (h *ReportHandler) LiftSanction(c *gin.Context) {
+	kind := c.Param("kind")
+	if kind != constants.SanctionMute && kind != constants.SanctionBan {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be mute or ban"})
+		return
+	}
+
+	err := h.sanctions.Lift(c.Param("user_id"), kind)
+	if errors.Is(err, services.ErrSanctionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error lifting sanction:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to lift sanction"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditSanctionLifted, c.Param("user_id"), map[string]string{"kind": kind}))
+	c.Status(http.StatusNoContent)
+}
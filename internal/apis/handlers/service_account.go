@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccounts *services.ServiceAccountService
+	chatService     *services.ChatMessageService
+	audit           *services.AuditService
+}
+
+func NewServiceAccountHandler(serviceAccounts *services.ServiceAccountService, chatService *services.ChatMessageService, audit *services.AuditService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{serviceAccounts: serviceAccounts, chatService: chatService, audit: audit}
+}
+
+// CreateServiceAccount registers an account and returns its first API key, shown only once
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var request dtos.CreateServiceAccountDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, key, err := h.serviceAccounts.Create(request)
+	if errors.Is(err, services.ErrServiceAccountExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error creating service account:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create service account"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditServiceAccountCreated, account.ID, map[string]string{
+		"scopes": strings.Join(account.Scopes, ","),
+		"key_id": key.APIKey.ID,
+	}))
+	c.JSON(http.StatusCreated, gin.H{"account": account, "api_key": key.Key, "key": key.APIKey})
+}
+
+func (h *ServiceAccountHandler) ListServiceAccounts(c *gin.Context) {
+	accounts, err := h.serviceAccounts.List()
+	if err != nil {
+		log.Println("Error listing service accounts:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list service accounts"})
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
+
+// GetServiceAccount returns an account with its keys and when each was last used
+func (h *ServiceAccountHandler) GetServiceAccount(c *gin.Context) {
+	accountID := c.Param("account_id")
+	account, err := h.serviceAccounts.Get(accountID)
+	if errors.Is(err, services.ErrServiceAccountNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error loading service account:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load service account"})
+		return
+	}
+	keys, err := h.serviceAccounts.Keys(accountID)
+	if err != nil {
+		log.Println("Error listing API keys:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, dtos.ServiceAccountResponseDto{ServiceAccount: *account, Keys: keys})
+}
+
+// UpdateServiceAccount replaces an account's scopes
+func (h *ServiceAccountHandler) UpdateServiceAccount(c *gin.Context) {
+	var request dtos.UpdateServiceAccountDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.serviceAccounts.UpdateScopes(c.Param("account_id"), request.Scopes)
+	if errors.Is(err, services.ErrServiceAccountNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error updating service account:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update service account"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditServiceAccountUpdated, account.ID, map[string]string{"scopes": strings.Join(account.Scopes, ",")}))
+	c.JSON(http.StatusOK, account)
+}
+
+// DeleteServiceAccount removes an account with its keys and closes its socket, if any
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *gin.Context) {
+	accountID := c.Param("account_id")
+	err := h.serviceAccounts.Delete(accountID)
+	if errors.Is(err, services.ErrServiceAccountNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error deleting service account:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete service account"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditServiceAccountDeleted, accountID, nil))
+	if _, err := h.chatService.ForceDisconnect(accountID, "service account deleted"); err != nil && !errors.Is(err, services.ErrUserNotConnected) {
+		log.Printf("Error disconnecting deleted service account %s: %v", accountID, err)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RotateKey issues a new API key; the previous keys keep working for the requested grace period
+func (h *ServiceAccountHandler) RotateKey(c *gin.Context) {
+	var request dtos.RotateAPIKeyDto
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	var grace time.Duration
+	if request.Grace != "" {
+		var err error
+		if grace, err = time.ParseDuration(request.Grace); err != nil || grace < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grace must be a Go duration, e.g. 1h"})
+			return
+		}
+	}
+
+	key, err := h.serviceAccounts.Rotate(c.Param("account_id"), grace)
+	if errors.Is(err, services.ErrServiceAccountNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error rotating API key:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate API key"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditAPIKeyRotated, c.Param("account_id"), map[string]string{"key_id": key.APIKey.ID, "grace": grace.String()}))
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *ServiceAccountHandler) RevokeKey(c *gin.Context) {
+	err := h.serviceAccounts.Revoke(c.Param("account_id"), c.Param("key_id"))
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error revoking API key:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke API key"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditAPIKeyRevoked, c.Param("account_id"), map[string]string{"key_id": c.Param("key_id")}))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/codecs"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/services"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// socketClient is a connected user together with the frame codec it negotiated
+type socketClient struct {
+	connectionID string // Keys the per-connection rate limit
+	userID       string
+	roles        []string // Token roles, only touched by the read loop
+	tenant       string   // Token tenant, only touched by the read loop
+	isBot        bool
+	conn         *websocket.Conn
+	codec        codecs.Codec
+	writeTimeout time.Duration
+	connectedAt  time.Time
+	// gorilla/websocket allows a single concurrent writer per connection
+	writeMutex sync.Mutex
+
+	messagesReceived atomic.Int64
+	messagesSent     atomic.Int64
+
+	// Guards the switch from resume replay to live delivery
+	deliveryMutex sync.Mutex
+	replaying     bool
+	pending       []models.ChatMessage // Live messages held back while replaying
+	lastSequence  int64                // Highest inbox sequence written to the client
+	// Event IDs written by the replay whose live copy has not arrived yet. Each is
+	// dropped on its match, so the set never outgrows one replay.
+	replayed map[string]bool
+
+	expiryMutex sync.Mutex
+	expiryTimer *time.Timer // Closes the socket when the session's token expires
+
+	lastActive atomic.Int64 // Unix nanoseconds of the last frame the client sent
+}
+
+// writeFrame encodes a payload with the client's codec and writes it
+func (s *socketClient) writeFrame(payload interface{}) error {
+	data, err := s.codec.Encode(payload)
+	if err != nil {
+		return err
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	return s.conn.WriteMessage(s.codec.FrameType(), data)
+}
+
+// close sends a close frame with the given code and reason, then drops the connection.
+// The read loop notices the closed socket and performs the usual cleanup.
+func (s *socketClient) close(code int, reason string) {
+	s.writeMutex.Lock()
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, closeReason(reason)), time.Now().Add(s.writeTimeout))
+	s.writeMutex.Unlock()
+	s.conn.Close()
+}
+
+// maxCloseReasonBytes is what fits in a close frame: control frames carry at most 125
+// bytes, two of which are the close code
+const maxCloseReasonBytes = 123
+
+// closeReason cuts a reason to fit a close frame, at a UTF-8 boundary. A longer one
+// would make WriteControl fail and the client would get neither code nor reason.
+func closeReason(reason string) string {
+	if len(reason) <= maxCloseReasonBytes {
+		return reason
+	}
+	end := maxCloseReasonBytes
+	for end > 0 && !utf8.RuneStart(reason[end]) {
+		end--
+	}
+	return reason[:end]
+}
+
+// keepAlive pings the client every PingInterval, renews its registry entry through
+// refresh every refreshInterval, and closes it once it has sent nothing for the idle
+// timeout. Once the returned stop returns, refresh no longer runs.
+func (s *socketClient) keepAlive(config *WebSocketConfig, refreshInterval time.Duration, refresh func()) (stop func()) {
+	done, stopped := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		var pings <-chan time.Time
+		if config.PingInterval > 0 {
+			pingTicker := time.NewTicker(config.PingInterval)
+			defer pingTicker.Stop()
+			pings = pingTicker.C
+		}
+		refreshTicker := time.NewTicker(refreshInterval)
+		defer refreshTicker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-pings:
+				if s.idle(config) {
+					return
+				}
+				// WriteControl may run concurrently with the other writes
+				if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.writeTimeout)); err != nil {
+					log.Printf("Error pinging user %s, dropping the connection: %v", s.userID, err)
+					s.conn.Close()
+					return
+				}
+			case <-refreshTicker.C:
+				if s.idle(config) {
+					return
+				}
+				refresh()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// idle closes the socket when the client has sent nothing for the idle timeout
+func (s *socketClient) idle(config *WebSocketConfig) bool {
+	if config.IdleTimeout <= 0 || time.Since(time.Unix(0, s.lastActive.Load())) < config.IdleTimeout {
+		return false
+	}
+	log.Printf("Closing WebSocket of user %s, idle for %s", s.userID, config.IdleTimeout)
+	s.close(constants.CloseIdleTimeout, "idle timeout")
+	return true
+}
+
+// touch records a frame from the client and, with heartbeats on, gives it until the next
+// ping is due plus the pong timeout to send something again. Only called by the read loop.
+func (s *socketClient) touch(config *WebSocketConfig) {
+	s.lastActive.Store(time.Now().UnixNano())
+	s.extendReadDeadline(config)
+}
+
+// extendReadDeadline pushes the read deadline back after a frame or a pong. A peer that
+// stops answering pings makes the blocked read fail with a timeout.
+func (s *socketClient) extendReadDeadline(config *WebSocketConfig) {
+	if config.PingInterval > 0 {
+		s.conn.SetReadDeadline(time.Now().Add(config.PingInterval + config.PongTimeout))
+	}
+}
+
+// sender is the client as the author of messages
+func (s *socketClient) sender() services.Sender {
+	return services.Sender{UserID: s.userID, Roles: s.roles, Tenant: s.tenant}
+}
+
+// expireAt (re)arms the timer closing the socket at the given time, a zero time disarms it
+func (s *socketClient) expireAt(expiry time.Time) {
+	s.expiryMutex.Lock()
+	defer s.expiryMutex.Unlock()
+	if s.expiryTimer != nil {
+		s.expiryTimer.Stop()
+		s.expiryTimer = nil
+	}
+	if expiry.IsZero() {
+		return
+	}
+	s.expiryTimer = time.AfterFunc(time.Until(expiry), func() {
+		s.close(constants.CloseTokenExpired, "token expired")
+	})
+}
+
+// deliverLive writes a message arriving through Kafka. While a resume replay is running
+// it is queued instead, and messages the replay already wrote are dropped.
+func (s *socketClient) deliverLive(message models.ChatMessage) (bool, error) {
+	s.deliveryMutex.Lock()
+	defer s.deliveryMutex.Unlock()
+
+	if s.replaying {
+		s.pending = append(s.pending, message)
+		return false, nil
+	}
+	return s.deliverLocked(message)
+}
+
+// replay writes missed messages, then flushes what arrived live meanwhile and switches to live delivery
+func (s *socketClient) replay(missed []models.ChatMessage) (int, error) {
+	s.deliveryMutex.Lock()
+	defer s.deliveryMutex.Unlock()
+
+	// Sequences are taken before the Kafka publish and the producer's LeastBytes balancer
+	// ignores keys, so Kafka doesn't keep even a chat in order. A live message can
+	// legitimately arrive below one already written. Only the copies of replayed
+	// messages are duplicates.
+	s.replayed = make(map[string]bool, len(missed))
+	replayed := 0
+	for _, message := range missed {
+		delivered, err := s.deliverLocked(message)
+		if err != nil {
+			return replayed, err
+		}
+		if delivered {
+			s.replayed[message.EventID] = true
+			replayed++
+		}
+	}
+
+	sort.SliceStable(s.pending, func(i, j int) bool { return s.pending[i].Sequence < s.pending[j].Sequence })
+	for _, message := range s.pending {
+		if _, err := s.deliverLocked(message); err != nil {
+			return replayed, err
+		}
+	}
+	s.pending = nil
+	s.replaying = false
+
+	err := s.writeFrame(dtos.ResumedResponseDto{
+		Type:         constants.FrameTypeResumed,
+		Replayed:     replayed,
+		LastSequence: s.lastSequence,
+	})
+	return replayed, err
+}
+
+func (s *socketClient) deliverLocked(message models.ChatMessage) (bool, error) {
+	if s.replayed[message.EventID] {
+		delete(s.replayed, message.EventID)
+		return false, nil
+	}
+
+	err := s.writeFrame(dtos.ChatMessageResponseDto{
+		Type:        constants.FrameTypeMessage,
+		EventID:     message.EventID,
+		ChatID:      message.ChatID,
+		Sender:      message.SenderUserID,
+		MessageType: message.MessageType,
+		Message:     message.Message,
+		Sequence:    message.Sequence,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if message.Sequence > s.lastSequence {
+		s.lastSequence = message.Sequence
+	}
+	s.messagesSent.Add(1)
+	return true, nil
+}
+
+func (s *socketClient) info() models.ConnectionInfo {
+	return models.ConnectionInfo{
+		UserID:           s.userID,
+		ServerID:         os.Getenv("SERVER_ID"),
+		RemoteAddr:       s.conn.RemoteAddr().String(),
+		Subprotocol:      s.codec.Subprotocol(),
+		IsBot:            s.isBot,
+		ConnectedAt:      s.connectedAt,
+		MessagesReceived: s.messagesReceived.Load(),
+		MessagesSent:     s.messagesSent.Load(),
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCloseReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+		want   string
+	}{
+		{"empty", "", ""},
+		{"short", "idle timeout", "idle timeout"},
+		{"exactly the limit", strings.Repeat("a", 123), strings.Repeat("a", 123)},
+		{"ascii over the limit", strings.Repeat("a", 2000), strings.Repeat("a", 123)},
+		// 61 two-byte runes are 122 bytes, the 62nd would end at byte 124
+		{"multi-byte rune across the limit", "b" + strings.Repeat("é", 100), "b" + strings.Repeat("é", 61)},
+		{"four-byte runes", strings.Repeat("👋", 40), strings.Repeat("👋", 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := closeReason(tt.reason)
+			if got != tt.want {
+				t.Errorf("closeReason() = %q (%d bytes), want %q (%d bytes)", got, len(got), tt.want, len(tt.want))
+			}
+			if len(got) > maxCloseReasonBytes || !utf8.ValidString(got) {
+				t.Errorf("closeReason() = %q is not a valid close reason", got)
+			}
+		})
+	}
+}
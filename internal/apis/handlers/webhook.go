@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	audit          *services.AuditService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService, audit *services.AuditService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService, audit: audit}
+}
+
+// withoutSecret hides the signing secret once a subscription has been created
+func withoutSecret(subscription models.WebhookSubscription) models.WebhookSubscription {
+	subscription.Secret = ""
+	return subscription
+}
+
+func (h *WebhookHandler) CreateSubscription(c *gin.Context) {
+	var request dtos.CreateWebhookDto
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := h.webhookService.CreateSubscription(request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditWebhookCreated, subscription.ID, map[string]string{"url": subscription.URL, "events": strings.Join(subscription.Events, ",")}))
+	c.JSON(http.StatusCreated, withoutSecret(*subscription))
+}
+
+func (h *WebhookHandler) ListSubscriptions(c *gin.Context) {
+	subscriptions, err := h.webhookService.ListSubscriptions()
+	if err != nil {
+		log.Println("Error listing webhook subscriptions:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list webhook subscriptions"})
+		return
+	}
+
+	response := make([]models.WebhookSubscription, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		response = append(response, withoutSecret(subscription))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	err := h.webhookService.DeleteSubscription(c.Param("id"))
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error deleting webhook subscription:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete webhook subscription"})
+		return
+	}
+	h.audit.Record(auditEntry(c, constants.AuditWebhookDeleted, c.Param("id"), nil))
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) ListDeadLetters(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+
+	deadLetters, err := h.webhookService.ListDeadLetters(limit)
+	if err != nil {
+		log.Println("Error listing webhook dead letters:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list dead letters"})
+		return
+	}
+	c.JSON(http.StatusOK, deadLetters)
+}
@@ -1,128 +1,472 @@
 package handlers
 
 import (
+	"distributed-chat-system/internal/apis/codecs"
 	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
 	"distributed-chat-system/internal/models"
 	"distributed-chat-system/internal/services"
-	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+// registryRefreshInterval is how often a connected user's registry entry is renewed,
+// well within the entry's TTL
+const registryRefreshInterval = time.Minute
+
 type WebSocketHandler struct {
 	upgrader    websocket.Upgrader
-	conns       map[string]*websocket.Conn
+	config      *WebSocketConfig
+	conns       map[string]*socketClient
+	connsMutex  sync.RWMutex
 	chatService *services.ChatMessageService
+	botService  *services.BotService
+	authService *services.AuthService
+	rateLimiter *services.RateLimitService
+	sanctions   *services.SanctionService
+	accounts    *services.ServiceAccountService
 }
 
 // InitWebSocketHandler initializes the WebSocketHandler and subscribes it to the ChatMessageService
-func InitWebSocketHandler(chatService *services.ChatMessageService) *WebSocketHandler {
+func InitWebSocketHandler(chatService *services.ChatMessageService, botService *services.BotService, authService *services.AuthService, rateLimiter *services.RateLimitService, sanctions *services.SanctionService, accounts *services.ServiceAccountService, originHandler *OriginHandler, config *WebSocketConfig) *WebSocketHandler {
 
 	handler := &WebSocketHandler{
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				// Allow all origins for simplicity, modify for production
-				return true
-			},
+			CheckOrigin: originHandler.CheckOrigin,
+			// Frame encodings, negotiated through Sec-WebSocket-Protocol
+			Subprotocols:      codecs.Subprotocols(),
+			EnableCompression: config.EnableCompression,
 		},
-		conns:       make(map[string]*websocket.Conn),
+		config:      config,
+		conns:       make(map[string]*socketClient),
 		chatService: chatService,
+		botService:  botService,
+		authService: authService,
+		rateLimiter: rateLimiter,
+		sanctions:   sanctions,
+		accounts:    accounts,
 	}
 
 	// Subscribe to the ChatMessageService once
-	chatService.SetChatConsumer(handler)
+	chatService.AddChatConsumer(handler)
 	return handler
 }
 
-// InitWebSocket handles WebSocket connections and communication
+// InitWebSocket handles WebSocket connections and communication. The user is the
+// subject of the presented token; a user_id in the path must match it.
 func (h *WebSocketHandler) InitWebSocket(c *gin.Context) {
-	// Extract user_id from path params
-	userID := c.Param("user_id")
-	log.Println("user_id", userID)
-	if userID == "" {
+	sender, expiry, err := h.authenticate(c)
+	if err != nil {
+		log.Printf("Rejected WebSocket upgrade from %s: %v", c.ClientIP(), err)
+		status := http.StatusUnauthorized
+		if errors.Is(err, services.ErrTokenMismatch) || errors.Is(err, services.ErrScopeDenied) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if sender.UserID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
 		return
 	}
 
-	// Upgrade the connection to WebSocket
-	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	// Bot accounts may only connect through /ws/bot/:bot_id with their secret
+	if h.botService.LookupBot(sender.UserID) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user_id belongs to a bot"})
+		return
+	}
+	if h.sanctions.Banned(sender.UserID) {
+		log.Printf("Rejected WebSocket upgrade of banned user %s", sender.UserID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are banned", "code": constants.DenyBanned})
+		return
+	}
+
+	h.serveSocket(c, sender, false, expiry)
+}
+
+// authenticate resolves the connecting user and their roles before the upgrade,
+// returning when the session's token expires. Service accounts connect with an API key
+// holding the send scope, as service-account:<id>; their sessions don't expire.
+func (h *WebSocketHandler) authenticate(c *gin.Context) (services.Sender, time.Time, error) {
+	pathUserID := c.Param("user_id")
+	if apiKey := requestAPIKey(c); apiKey != "" {
+		account, err := h.accounts.Authenticate(apiKey, constants.ScopeSend)
+		if err != nil {
+			return services.Sender{}, time.Time{}, err
+		}
+		actor := services.ServiceAccountActor(account.ID)
+		if pathUserID != "" && pathUserID != actor {
+			return services.Sender{}, time.Time{}, services.ErrTokenMismatch
+		}
+		log.Printf("Service account %s connecting over WebSocket", account.ID)
+		return services.Sender{UserID: actor, Roles: []string{constants.RoleService}, Tenant: account.Tenant}, time.Time{}, nil
+	}
+	if !h.authService.Enabled() {
+		return services.Sender{UserID: pathUserID}, time.Time{}, nil
+	}
+
+	claims, err := h.authService.Authenticate(requestToken(c))
+	if err != nil {
+		return services.Sender{}, time.Time{}, err
+	}
+	if pathUserID != "" && pathUserID != claims.Subject {
+		return services.Sender{}, time.Time{}, services.ErrTokenMismatch
+	}
+	return services.Sender{UserID: claims.Subject, Roles: claims.Roles, Tenant: claims.Tenant}, claims.Expiry(), nil
+}
+
+// InitBotWebSocket connects a stream bot, authenticated by the X-Bot-Secret header
+func (h *WebSocketHandler) InitBotWebSocket(c *gin.Context) {
+	botID := c.Param("bot_id")
+	bot, ok := h.botService.AuthenticateBot(botID, c.GetHeader("X-Bot-Secret"))
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid bot credentials"})
+		return
+	}
+	if bot.Transport != constants.BotTransportStream {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bot does not use stream transport"})
+		return
+	}
+
+	h.serveSocket(c, services.Sender{UserID: botID, Roles: []string{constants.RoleBot}}, true, time.Time{})
+}
 
-	log.Printf("Headers: %+v\n", c.Request.Header)
+// parseResumeRequest reads the optional resume_from / last_event_id query parameters
+func parseResumeRequest(c *gin.Context) (*services.ResumeRequest, error) {
+	resumeFrom, lastEventID := c.Query("resume_from"), c.Query("last_event_id")
+	if resumeFrom == "" && lastEventID == "" {
+		return nil, nil
+	}
+
+	resume := &services.ResumeRequest{LastEventID: lastEventID}
+	if resumeFrom != "" {
+		sequence, err := strconv.ParseInt(resumeFrom, 10, 64)
+		if err != nil || sequence < 0 {
+			return nil, errors.New("resume_from must be a non-negative integer")
+		}
+		resume.LastSequence = sequence
+	}
+	return resume, nil
+}
+
+// serveSocket upgrades the request and runs the read loop for a user or stream bot.
+// A non-zero expiry closes the socket once the session's token runs out.
+func (h *WebSocketHandler) serveSocket(c *gin.Context, sender services.Sender, isBot bool, expiry time.Time) {
+	userID := sender.UserID
+	resume, err := parseResumeRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
+	// Upgrade the connection to WebSocket
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println("Failed to upgrade to WebSocket:", err)
 		return
 	}
 
-	// Store the connection
-	h.conns[userID] = conn
+	// Store the connection along with the codec the client negotiated
+	client := &socketClient{
+		connectionID: uuid.NewString(),
+		userID:       userID,
+		roles:        sender.Roles,
+		tenant:       sender.Tenant,
+		isBot:        isBot,
+		conn:         conn,
+		codec:        codecs.ForSubprotocol(conn.Subprotocol()),
+		writeTimeout: h.config.WriteTimeout,
+		connectedAt:  time.Now().UTC(),
+		// Live messages are held back until the missed ones have been replayed
+		replaying: resume != nil,
+	}
+
+	// Oversized frames make ReadMessage fail after gorilla closes with 1009 (message too big)
+	conn.SetReadLimit(h.config.MaxMessageBytes)
+	if h.config.EnableCompression {
+		if err := conn.SetCompressionLevel(h.config.CompressionLevel); err != nil {
+			log.Printf("Invalid compression level %d: %v", h.config.CompressionLevel, err)
+		}
+	}
+	// Pongs are handled while the read loop reads; each one proves the peer is still there
+	conn.SetPongHandler(func(string) error {
+		client.extendReadDeadline(h.config)
+		return nil
+	})
+	client.touch(h.config)
+
+	h.connsMutex.Lock()
+	h.conns[userID] = client
+	h.connsMutex.Unlock()
+	client.expireAt(expiry)
+	stopKeepAlive := client.keepAlive(h.config, registryRefreshInterval, func() {
+		if err := h.chatService.RefreshUserChatServer(userID); err != nil {
+			log.Printf("Error refreshing registry entry of user %s: %v", userID, err)
+		}
+	})
 	defer func() {
+		// Stopped first, so a refresh can't recreate the registry entry removed below
+		stopKeepAlive()
+		client.expireAt(time.Time{})
 		conn.Close()
-		delete(h.conns, userID)
+		// A reconnect may already have replaced this socket; leave the newer one registered
+		h.connsMutex.Lock()
+		current := h.conns[userID] == client
+		if current {
+			delete(h.conns, userID)
+		}
+		h.connsMutex.Unlock()
+		if current {
+			h.chatService.UnsubscribeUserToChatServer(userID)
+		}
 		log.Printf("WebSocket connection closed for user: %s", userID)
 	}()
 
-	log.Printf("WebSocket connection established for user: %s", userID)
+	log.Printf("WebSocket connection established for user: %s (codec: %s)", userID, client.codec.Subprotocol())
 
 	h.chatService.SubscribeUserToChatServer(userID)
-	defer h.chatService.UnsubscribeUserToChatServer(userID)
-	// WebSocket communication loop
+
+	// Replay only after subscribing: anything routed from now on is queued by deliverLive,
+	// anything routed before is already in the inbox, so the switch has no gap
+	if resume != nil {
+		missed, err := h.chatService.MissedMessages(userID, *resume)
+		if err != nil {
+			log.Printf("Error loading missed messages for user %s: %v", userID, err)
+			client.close(websocket.CloseInternalServerErr, "resume failed")
+			return
+		}
+		replayed, err := client.replay(missed)
+		if err != nil {
+			log.Printf("Error replaying messages to user %s: %v", userID, err)
+			return
+		}
+		log.Printf("Replayed %d missed messages to user %s", replayed, userID)
+	}
+
+	// WebSocket communication loop. The replay may have taken a while, so the read
+	// deadline starts over.
+	client.touch(h.config)
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
-			log.Println("Error reading message:", err)
+			var netErr net.Error
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Printf("User %s exceeded max message size of %d bytes", userID, h.config.MaxMessageBytes)
+			} else if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("User %s stopped answering pings, dropping the connection", userID)
+			} else {
+				log.Println("Error reading message:", err)
+			}
 			break
 		}
+		client.touch(h.config)
+		client.messagesReceived.Add(1)
 
-		// Parse the received JSON message
+		// Parse the received frame
 		var chatMessage dtos.ChatMessageDto
-		err = json.Unmarshal(message, &chatMessage)
+		err = client.codec.Decode(message, &chatMessage)
+
+		var limitErr *services.RateLimitError
+		if errors.As(h.rateLimiter.AllowFrame(client.connectionID), &limitErr) {
+			if !h.rejectRateLimited(client, chatMessage.ClientMsgID, limitErr) {
+				break
+			}
+			continue
+		}
 		if err != nil {
 			log.Println("Invalid message format:", err)
-			conn.WriteMessage(websocket.TextMessage, []byte(`{"error": "Invalid message format"}`))
+			client.writeFrame(dtos.NewErrorResponse("Invalid message format"))
+			continue
+		}
+
+		if chatMessage.Type == constants.FrameTypeRead {
+			// The receipt goes to the sender stored with the message, not to one the client names
+			h.chatService.MarkMessageRead(userID, chatMessage.EventID)
+			continue
+		}
+
+		if chatMessage.Type == constants.FrameTypeAuth {
+			h.reauthenticate(client, chatMessage)
+			continue
+		}
+
+		if chatMessage.Type == constants.FrameTypeBotReply {
+			if !isBot {
+				client.writeFrame(dtos.NewErrorResponse("Only bots may send bot_reply frames"))
+				continue
+			}
+			if err := h.chatService.ReplyToBotInvocation(userID, chatMessage.EventID, chatMessage.Message); err != nil {
+				log.Printf("Error replying to bot invocation %s: %v", chatMessage.EventID, err)
+				client.writeFrame(dtos.NewErrorResponse(err.Error()))
+			}
 			continue
 		}
 
 		// Log and send the message to the service
 		log.Printf("Message received from user %s: %+v", userID, chatMessage)
-		err = h.chatService.SendMessageToUser(userID, chatMessage)
-		if err != nil {
+		eventID, err := h.chatService.SendMessageToUser(client.sender(), chatMessage)
+		if errors.As(err, &limitErr) {
+			if !h.rejectRateLimited(client, chatMessage.ClientMsgID, limitErr) {
+				break
+			}
+			continue
+		}
+		var policyErr *services.PolicyError
+		if err != nil && !errors.As(err, &policyErr) {
 			log.Printf("Error sending message: %v", err)
 		}
+		if chatMessage.ClientMsgID != "" {
+			ack := dtos.AckResponseDto{Type: constants.FrameTypeAck, ClientMsgID: chatMessage.ClientMsgID, EventID: eventID}
+			if err != nil {
+				ack.Error = err.Error()
+			}
+			if policyErr != nil {
+				ack.Code = policyErr.Code
+			}
+			client.writeFrame(ack)
+		} else if policyErr != nil {
+			// Without an ack to carry it the denial goes out as an error frame
+			client.writeFrame(dtos.NewCodedErrorResponse(policyErr.Code, policyErr.Reason))
+		}
+	}
+}
+
+// rejectRateLimited answers a frame over a rate limit with the time to wait. It returns
+// false once the user exceeded the limits so often that the socket has been closed.
+func (h *WebSocketHandler) rejectRateLimited(client *socketClient, clientMsgID string, limitErr *services.RateLimitError) bool {
+	retryAfterMs := limitErr.RetryAfter.Milliseconds()
+	if clientMsgID != "" {
+		client.writeFrame(dtos.AckResponseDto{Type: constants.FrameTypeAck, ClientMsgID: clientMsgID, Error: limitErr.Error(), Code: constants.ErrorRateLimited, RetryAfterMs: retryAfterMs})
+	} else {
+		client.writeFrame(dtos.ErrorResponseDto{Type: constants.FrameTypeError, Code: constants.ErrorRateLimited, Error: limitErr.Error(), RetryAfterMs: retryAfterMs})
+	}
+
+	if h.rateLimiter.RecordViolation(client.userID, limitErr) {
+		log.Printf("Disconnecting user %s for flooding", client.userID)
+		client.close(constants.CloseRateLimited, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// reauthenticate extends a session with a fresh token sent in an auth frame
+func (h *WebSocketHandler) reauthenticate(client *socketClient, frame dtos.ChatMessageDto) {
+	if client.isBot || !h.authService.Enabled() {
+		client.writeFrame(dtos.NewErrorResponse("auth frames are not accepted on this connection"))
+		return
+	}
+
+	claims, err := h.authService.AuthenticateUser(frame.Message, client.userID)
+	if err != nil {
+		log.Printf("Rejected token refresh of user %s: %v", client.userID, err)
+		client.writeFrame(dtos.NewErrorResponse(err.Error()))
+		return
+	}
+	client.roles = claims.Roles
+	client.tenant = claims.Tenant
+	client.expireAt(claims.Expiry())
+	if frame.ClientMsgID != "" {
+		client.writeFrame(dtos.AckResponseDto{Type: constants.FrameTypeAck, ClientMsgID: frame.ClientMsgID})
 	}
 }
 
 // Notify sends a message to the connected WebSocket user
 func (h *WebSocketHandler) Notify(senderUserID string, message models.ChatMessage) error {
 	log.Println("Got Notified with event id:", message.EventID)
-	conn, exists := h.conns[message.ReceiverUserID]
+	client, exists := h.client(message.ReceiverUserID)
 	if !exists {
 		log.Printf("No active WebSocket connection for receiver_user: %s. Skipping ahead.", message.ReceiverUserID)
 		return nil
 	}
 
-	response := gin.H{
-		"chat_id":      message.ChatID,
-		"sender":       senderUserID,
-		"message_type": message.MessageType,
-		"message":      message.Message,
-	}
-
-	responseJSON, err := json.Marshal(response)
-	if err != nil {
-		log.Printf("Error marshalling message for user %s: %v", message.ReceiverUserID, err)
-		return err
-	}
-
-	err = conn.WriteMessage(websocket.TextMessage, responseJSON)
+	delivered, err := client.deliverLive(message)
 	if err != nil {
 		log.Printf("Error sending message to user %s: %v", message.ReceiverUserID, err)
 		return err
 	}
+	if !delivered {
+		log.Printf("Message %s to user %s queued for replay or already delivered", message.EventID, message.ReceiverUserID)
+		return nil
+	}
 
 	log.Printf("Message sent to user %s: %+v", message.ReceiverUserID, message)
+	h.chatService.MarkMessageDelivered(message)
+	return nil
+}
+
+// NotifyReceipt forwards a delivery or read receipt to the connected sender
+func (h *WebSocketHandler) NotifyReceipt(event models.ChatEvent) error {
+	client, exists := h.client(event.UserID)
+	if !exists {
+		return nil
+	}
+
+	return client.writeFrame(dtos.ReceiptResponseDto{
+		Type:    constants.FrameTypeReceipt,
+		Status:  event.Status,
+		EventID: event.MessageEventID,
+		ChatID:  event.ChatID,
+		UserID:  event.ActorUserID,
+	})
+}
+
+// NotifyModeration tells a connected user about a warning, a mute or a deleted message
+func (h *WebSocketHandler) NotifyModeration(event models.ChatEvent) error {
+	client, exists := h.client(event.UserID)
+	if !exists {
+		return nil
+	}
+
+	frame := dtos.ModerationResponseDto{
+		Type:    constants.FrameTypeModeration,
+		Action:  event.Status,
+		Reason:  event.Reason,
+		EventID: event.MessageEventID,
+		ChatID:  event.ChatID,
+	}
+	if event.Until != nil {
+		frame.Until = event.Until.Format(time.RFC3339)
+	}
+	return client.writeFrame(frame)
+}
+
+// Disconnect closes the socket of a user connected to this server
+func (h *WebSocketHandler) Disconnect(userID string, reason string) error {
+	client, exists := h.client(userID)
+	if !exists {
+		log.Printf("No active WebSocket connection to disconnect for user: %s", userID)
+		return nil
+	}
+
+	client.close(websocket.ClosePolicyViolation, reason)
+	log.Printf("WebSocket connection of user %s force closed: %s", userID, reason)
 	return nil
 }
+
+// Connections lists the sockets held by this server
+func (h *WebSocketHandler) Connections() []models.ConnectionInfo {
+	h.connsMutex.RLock()
+	defer h.connsMutex.RUnlock()
+
+	connections := make([]models.ConnectionInfo, 0, len(h.conns))
+	for _, client := range h.conns {
+		connections = append(connections, client.info())
+	}
+	return connections
+}
+
+func (h *WebSocketHandler) client(userID string) (*socketClient, bool) {
+	h.connsMutex.RLock()
+	defer h.connsMutex.RUnlock()
+	client, exists := h.conns[userID]
+	return client, exists
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"compress/flate"
+	"distributed-chat-system/internal/utils"
+	"errors"
+	"time"
+)
+
+type WebSocketConfig struct {
+	EnableCompression bool          // Negotiate permessage-deflate with clients that offer it
+	CompressionLevel  int           // flate level used for outgoing frames (-2..9)
+	MaxMessageBytes   int64         // Largest inbound message accepted before closing with 1009
+	WriteTimeout      time.Duration // Deadline applied to every frame write
+	PingInterval      time.Duration // How often the server pings each socket, 0 disables heartbeats
+	PongTimeout       time.Duration // How long after a ping is due a socket may stay silent before it is closed
+	IdleTimeout       time.Duration // Sockets sending no frames for this long are closed, 0 keeps them
+}
+
+// DefaultWebSocketConfig provides a default WebSocket configuration
+func DefaultWebSocketConfig() *WebSocketConfig {
+	return &WebSocketConfig{
+		EnableCompression: false,
+		CompressionLevel:  flate.BestSpeed,
+		MaxMessageBytes:   64 * 1024, // 64KB
+		WriteTimeout:      10 * time.Second,
+		PingInterval:      30 * time.Second,
+		PongTimeout:       15 * time.Second,
+		IdleTimeout:       0,
+	}
+}
+
+// WebSocketConfigFromEnv overrides the defaults with WS_* environment variables
+func WebSocketConfigFromEnv() *WebSocketConfig {
+	cfg := DefaultWebSocketConfig()
+	cfg.EnableCompression = utils.GetEnvBool("WS_ENABLE_COMPRESSION", cfg.EnableCompression)
+	cfg.CompressionLevel = utils.GetEnvInt("WS_COMPRESSION_LEVEL", cfg.CompressionLevel)
+	cfg.MaxMessageBytes = int64(utils.GetEnvInt("WS_MAX_MESSAGE_BYTES", int(cfg.MaxMessageBytes)))
+	cfg.WriteTimeout = utils.GetEnvDuration("WS_WRITE_TIMEOUT", cfg.WriteTimeout)
+	cfg.PingInterval = utils.GetEnvDuration("WS_PING_INTERVAL", cfg.PingInterval)
+	cfg.PongTimeout = utils.GetEnvDuration("WS_PONG_TIMEOUT", cfg.PongTimeout)
+	cfg.IdleTimeout = utils.GetEnvDuration("WS_IDLE_TIMEOUT", cfg.IdleTimeout)
+	return cfg
+}
+
+// Validate rejects settings the socket loops can't run with
+func (c *WebSocketConfig) Validate() error {
+	if c.CompressionLevel < flate.HuffmanOnly || c.CompressionLevel > flate.BestCompression {
+		return errors.New("WS_COMPRESSION_LEVEL must be within -2..9")
+	}
+	if c.MaxMessageBytes <= 0 {
+		return errors.New("WS_MAX_MESSAGE_BYTES must be positive")
+	}
+	if c.WriteTimeout <= 0 {
+		return errors.New("WS_WRITE_TIMEOUT must be positive")
+	}
+	if c.PingInterval < 0 || c.IdleTimeout < 0 {
+		return errors.New("WS_PING_INTERVAL and WS_IDLE_TIMEOUT can't be negative")
+	}
+	if c.PingInterval > 0 && (c.PongTimeout <= 0 || c.PongTimeout >= c.PingInterval) {
+		return errors.New("WS_PONG_TIMEOUT must be positive and shorter than WS_PING_INTERVAL")
+	}
+	return nil
+}
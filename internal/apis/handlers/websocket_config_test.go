@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebSocketConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *WebSocketConfig)
+		wantErr bool
+	}{
+		{"defaults", func(c *WebSocketConfig) {}, false},
+		{"heartbeats disabled", func(c *WebSocketConfig) { c.PingInterval, c.PongTimeout = 0, 0 }, false},
+		{"compression level too high", func(c *WebSocketConfig) { c.CompressionLevel = 10 }, true},
+		{"compression level too low", func(c *WebSocketConfig) { c.CompressionLevel = -3 }, true},
+		{"zero message size", func(c *WebSocketConfig) { c.MaxMessageBytes = 0 }, true},
+		{"zero write timeout", func(c *WebSocketConfig) { c.WriteTimeout = 0 }, true},
+		{"negative ping interval", func(c *WebSocketConfig) { c.PingInterval = -time.Second }, true},
+		{"negative idle timeout", func(c *WebSocketConfig) { c.IdleTimeout = -time.Second }, true},
+		{"zero pong timeout", func(c *WebSocketConfig) { c.PongTimeout = 0 }, true},
+		{"pong timeout as long as the ping interval", func(c *WebSocketConfig) { c.PongTimeout = c.PingInterval }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultWebSocketConfig()
+			tt.change(config)
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
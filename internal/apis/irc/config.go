@@ -0,0 +1,25 @@
+package irc
+
+import (
+	"distributed-chat-system/internal/utils"
+	"time"
+)
+
+type Config struct {
+	ListenAddr   string        // e.g. ":6667", empty disables the gateway
+	ServerName   string        // Name announced to clients in replies
+	IdleTimeout  time.Duration // Sessions silent for this long are dropped
+	PingInterval time.Duration // How often registered sessions are pinged and their registry entry renewed
+	MaxLineSize  int           // Longest accepted protocol line in bytes
+}
+
+// ConfigFromEnv builds the gateway configuration from IRC_* environment variables
+func ConfigFromEnv() *Config {
+	return &Config{
+		ListenAddr:   utils.GetEnvString("IRC_LISTEN_ADDR", ""),
+		ServerName:   utils.GetEnvString("IRC_SERVER_NAME", "chat.irc"),
+		IdleTimeout:  utils.GetEnvDuration("IRC_IDLE_TIMEOUT", 5*time.Minute),
+		PingInterval: utils.GetEnvDuration("IRC_PING_INTERVAL", time.Minute),
+		MaxLineSize:  utils.GetEnvInt("IRC_MAX_LINE_SIZE", 4096),
+	}
+}
@@ -0,0 +1,192 @@
+package irc
+
+import (
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/services"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Gateway is an IRC protocol listener for legacy clients. Sessions register in the
+// same user registry as WebSocket users and receive deliveries as a chat consumer,
+// so IRC users are full participants in cross-server routing.
+type Gateway struct {
+	config      *Config
+	chatService *services.ChatMessageService
+	botService  *services.BotService
+	memberships *services.MembershipService
+	authService *services.AuthService
+	rateLimiter *services.RateLimitService
+	sanctions   *services.SanctionService
+	audit       *services.AuditService
+
+	mutex    sync.RWMutex
+	sessions map[string]*session // Registered sessions by nick (user ID)
+}
+
+// NewGateway creates the gateway and subscribes it to the ChatMessageService
+func NewGateway(config *Config, chatService *services.ChatMessageService, botService *services.BotService, memberships *services.MembershipService, authService *services.AuthService, rateLimiter *services.RateLimitService, sanctions *services.SanctionService, audit *services.AuditService) *Gateway {
+	gateway := &Gateway{
+		config:      config,
+		chatService: chatService,
+		botService:  botService,
+		memberships: memberships,
+		authService: authService,
+		rateLimiter: rateLimiter,
+		sanctions:   sanctions,
+		audit:       audit,
+		sessions:    make(map[string]*session),
+	}
+	chatService.AddChatConsumer(gateway)
+	return gateway
+}
+
+// ListenAndServe accepts IRC connections until the listener fails
+func (g *Gateway) ListenAndServe() error {
+	listener, err := net.Listen("tcp", g.config.ListenAddr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	log.Printf("IRC gateway listening on %s", g.config.ListenAddr)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go newSession(g, conn).serve()
+	}
+}
+
+// register claims a nick for a session, failing when another local session holds it
+func (g *Gateway) register(s *session) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if _, taken := g.sessions[s.nick]; taken {
+		return false
+	}
+	g.sessions[s.nick] = s
+	return true
+}
+
+func (g *Gateway) unregister(s *session) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.sessions[s.nick] == s {
+		delete(g.sessions, s.nick)
+	}
+}
+
+func (g *Gateway) session(nick string) (*session, bool) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	s, exists := g.sessions[nick]
+	return s, exists
+}
+
+// Notify delivers a routed message to an IRC session, as a channel message when the
+// session has joined the chat and as a private message otherwise
+func (g *Gateway) Notify(senderUserID string, message models.ChatMessage) error {
+	s, exists := g.session(message.ReceiverUserID)
+	if !exists || message.MessageType == constants.MessageTypeBotInvocation {
+		return nil
+	}
+
+	target := s.nick
+	if s.hasJoined(message.ChatID) {
+		target = channelName(message.ChatID)
+	}
+	// A sender or chat ID that isn't a valid IRC token would let the message forge lines
+	if !validID(senderUserID) || (target != s.nick && !validID(message.ChatID)) {
+		log.Printf("Not delivering message %s over IRC, sender %q or chat %q can't be represented", message.EventID, senderUserID, message.ChatID)
+		return nil
+	}
+
+	// IRC clients can't decrypt, they are only told that an encrypted message arrived
+	if message.MessageType == constants.MessageTypeCiphertext {
+		return s.send(userPrefix(senderUserID, g.config.ServerName), "NOTICE", target, "sent an end-to-end encrypted message this client can't display")
+	}
+
+	// IRC lines can't carry line breaks, so multi-line messages become several PRIVMSGs
+	for _, line := range textLines(message.Message) {
+		if err := s.send(userPrefix(senderUserID, g.config.ServerName), "PRIVMSG", target, line); err != nil {
+			return err
+		}
+	}
+	s.messagesSent.Add(1)
+	g.chatService.MarkMessageDelivered(message)
+	return nil
+}
+
+// NotifyReceipt is a no-op: IRC has no notion of delivery or read receipts
+func (g *Gateway) NotifyReceipt(event models.ChatEvent) error {
+	return nil
+}
+
+// NotifyModeration sends a moderator's action as a server NOTICE. IRC clients can't
+// remove a message they already displayed, they are only told it was deleted.
+func (g *Gateway) NotifyModeration(event models.ChatEvent) error {
+	s, exists := g.session(event.UserID)
+	if !exists {
+		return nil
+	}
+
+	var text string
+	switch event.Status {
+	case constants.ReportActionDeleteMessage:
+		text = "A message you received in " + event.ChatID + " was removed by a moderator"
+	case constants.ReportActionWarn:
+		text = "Warning from the moderators"
+	case constants.ReportActionMute:
+		text = "You are muted"
+		if event.Until != nil {
+			text += " until " + event.Until.Format(time.RFC3339)
+		}
+	default:
+		return nil
+	}
+	if event.Reason != "" {
+		text += ": " + event.Reason
+	}
+	return s.send(g.config.ServerName, "NOTICE", s.nick, text)
+}
+
+// Disconnect closes the IRC session of a user on this server
+func (g *Gateway) Disconnect(userID string, reason string) error {
+	s, exists := g.session(userID)
+	if !exists {
+		return nil
+	}
+	s.close("Closing link: " + reason)
+	return nil
+}
+
+// Connections lists the IRC sessions held by this server
+func (g *Gateway) Connections() []models.ConnectionInfo {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	connections := make([]models.ConnectionInfo, 0, len(g.sessions))
+	for _, s := range g.sessions {
+		connections = append(connections, s.info())
+	}
+	return connections
+}
+
+// channelName maps a group ChatID to an IRC channel and back
+func channelName(chatID string) string {
+	return "#" + chatID
+}
+
+func chatIDFromChannel(channel string) string {
+	return strings.TrimPrefix(channel, "#")
+}
+
+func userPrefix(nick, serverName string) string {
+	return nick + "!" + nick + "@" + serverName
+}
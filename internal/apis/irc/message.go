@@ -0,0 +1,88 @@
+package irc
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Message is one parsed IRC protocol line (RFC 1459 / 2812)
+type Message struct {
+	Prefix  string
+	Command string
+	Params  []string
+}
+
+// ParseMessage parses a line without its trailing CRLF
+func ParseMessage(line string) (Message, bool) {
+	var message Message
+	line = strings.TrimRight(line, "\r\n")
+
+	if strings.HasPrefix(line, ":") {
+		prefix, rest, found := strings.Cut(line[1:], " ")
+		if !found {
+			return message, false
+		}
+		message.Prefix = prefix
+		line = rest
+	}
+
+	// Everything after " :" is a single trailing parameter that may contain spaces
+	line, trailing, hasTrailing := strings.Cut(line, " :")
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return message, false
+	}
+	message.Command = strings.ToUpper(fields[0])
+	message.Params = fields[1:]
+	if hasTrailing {
+		message.Params = append(message.Params, trailing)
+	}
+	return message, true
+}
+
+// Param returns the i-th parameter or an empty string
+func (m Message) Param(i int) string {
+	if i < len(m.Params) {
+		return m.Params[i]
+	}
+	return ""
+}
+
+// validID reports whether a user or chat ID can go into a prefix or target as is: IDs
+// with spaces, ':' or control characters would change how the line parses
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r == ' ' || r == ':' || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// textLines splits message text into IRC lines: CR and LF both end a line, NUL can't
+// be sent at all and empty lines are skipped
+func textLines(text string) []string {
+	text = strings.ReplaceAll(text, "\x00", "")
+	return strings.FieldsFunc(text, func(r rune) bool { return r == '\r' || r == '\n' })
+}
+
+// validLine reports whether a prefix and parameters form exactly one protocol line. Only
+// the last parameter may contain spaces or start with ':', none may contain CR, LF or NUL.
+func validLine(prefix string, params []string) bool {
+	if strings.ContainsAny(prefix, " \r\n\x00") {
+		return false
+	}
+	for i, param := range params {
+		if strings.ContainsAny(param, "\r\n\x00") {
+			return false
+		}
+		if i < len(params)-1 && (param == "" || param[0] == ':' || strings.Contains(param, " ")) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   Message
+		wantOK bool
+	}{
+		{"command only", "PING", Message{Command: "PING", Params: []string{}}, true},
+		{"lowercase command", "nick alice", Message{Command: "NICK", Params: []string{"alice"}}, true},
+		{"crlf trimmed", "NICK alice\r\n", Message{Command: "NICK", Params: []string{"alice"}}, true},
+		{"trailing with spaces", "PRIVMSG #team :hello there", Message{Command: "PRIVMSG", Params: []string{"#team", "hello there"}}, true},
+		{"empty trailing", "TOPIC #team :", Message{Command: "TOPIC", Params: []string{"#team", ""}}, true},
+		{"trailing keeps colons", "PRIVMSG bob :a :b", Message{Command: "PRIVMSG", Params: []string{"bob", "a :b"}}, true},
+		{"prefix", ":alice!alice@chat PRIVMSG bob :hi", Message{Prefix: "alice!alice@chat", Command: "PRIVMSG", Params: []string{"bob", "hi"}}, true},
+		{"extra spaces", "USER  alice   0 *  :Alice A", Message{Command: "USER", Params: []string{"alice", "0", "*", "Alice A"}}, true},
+		{"empty line", "", Message{}, false},
+		{"prefix only", ":alice", Message{}, false},
+		{"prefix without command", ":alice ", Message{Prefix: "alice"}, false},
+		{"only trailing", " :hello", Message{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := ParseMessage(test.line)
+			if ok != test.wantOK {
+				t.Fatalf("ParseMessage(%q) ok = %v, want %v", test.line, ok, test.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseMessage(%q) = %+v, want %+v", test.line, got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"alice", true},
+		{"team-eng_2", true},
+		{"", false},
+		{"alice bob", false},
+		{"dm:alice:bob", false},
+		{":alice", false},
+		{"alice\r\nQUIT", false},
+		{"alice\x00", false},
+		{"alice\x7f", false},
+	}
+	for _, test := range tests {
+		if got := validID(test.id); got != test.want {
+			t.Errorf("validID(%q) = %v, want %v", test.id, got, test.want)
+		}
+	}
+}
+
+func TestTextLines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"hello", []string{"hello"}},
+		{"one\ntwo", []string{"one", "two"}},
+		{"one\r\ntwo\rthree", []string{"one", "two", "three"}},
+		{"a\x00b", []string{"ab"}},
+		{"\n\n", []string{}},
+		{"x\r\nQUIT :bye", []string{"x", "QUIT :bye"}},
+	}
+	for _, test := range tests {
+		if got := textLines(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("textLines(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestValidLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		params []string
+		want   bool
+	}{
+		{"plain", "alice!alice@chat", []string{"bob", "hello there"}, true},
+		{"empty trailing", "chat", []string{"*", "LS", ""}, true},
+		{"trailing starts with colon", "chat", []string{"bob", ":)"}, true},
+		{"space in prefix", "alice bob!x@chat", []string{"bob", "hi"}, false},
+		{"space in middle", "chat", []string{"#a b", "hi"}, false},
+		{"colon starts middle", "chat", []string{":bob", "hi"}, false},
+		{"empty middle", "chat", []string{"", "hi"}, false},
+		{"line break in trailing", "chat", []string{"bob", "hi\r\nQUIT"}, false},
+		{"nul in middle", "chat", []string{"bob\x00", "hi"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := validLine(test.prefix, test.params); got != test.want {
+				t.Errorf("validLine(%q, %q) = %v, want %v", test.prefix, test.params, got, test.want)
+			}
+		})
+	}
+}
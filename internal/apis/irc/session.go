@@ -0,0 +1,511 @@
+package irc
+
+import (
+	"bufio"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/services"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Numeric replies used by the gateway
+const (
+	rplWelcome          = "001"
+	rplYourHost         = "002"
+	rplCreated          = "003"
+	rplMyInfo           = "004"
+	rplNoTopic          = "331"
+	rplNameReply        = "353"
+	rplEndOfNames       = "366"
+	errNoSuchNick       = "401"
+	errCannotSendToChan = "404"
+	errNoRecipient      = "411"
+	errNoTextToSend     = "412"
+	errUnknownCommand   = "421"
+	errNoMotd           = "422"
+	errNoNicknameGiven  = "431"
+	errErroneusNick     = "432"
+	errNicknameInUse    = "433"
+	errNotOnChannel     = "442"
+	errInviteOnlyChan   = "473"
+	errNotRegistered    = "451"
+	errNeedMoreParams   = "461"
+	errPasswdMismatch   = "464"
+	errYoureBannedCreep = "465"
+)
+
+// registryRefreshInterval renews registry entries when pings are turned off
+const registryRefreshInterval = time.Minute
+
+var nickPattern = regexp.MustCompile(`^[A-Za-z0-9_\-\[\]\\^{}|]{1,64}$`)
+
+var errInvalidLine = errors.New("parameters don't fit in one IRC line")
+
+// session is one IRC client connection
+type session struct {
+	gateway      *Gateway
+	conn         net.Conn
+	connectionID string // Keys the per-connection rate limit
+	nick         string
+	username     string
+	password     string   // Token sent with PASS, verified on registration
+	roles        []string // Token roles, used by the send policy
+	tenant       string   // Token tenant, selects moderation rules
+	registered   bool
+	connectedAt  time.Time
+
+	writeMutex  sync.Mutex
+	closeOnce   sync.Once
+	closed      chan struct{} // Closed with the connection, stops the keepalive
+	expiryTimer *time.Timer
+
+	channelsMutex sync.RWMutex
+	channels      map[string]bool // Joined ChatIDs
+
+	messagesReceived atomic.Int64
+	messagesSent     atomic.Int64
+}
+
+func newSession(gateway *Gateway, conn net.Conn) *session {
+	return &session{
+		gateway:      gateway,
+		conn:         conn,
+		connectionID: uuid.NewString(),
+		connectedAt:  time.Now().UTC(),
+		closed:       make(chan struct{}),
+		channels:     make(map[string]bool),
+	}
+}
+
+// serve runs the read loop until the client quits or the connection fails
+func (s *session) serve() {
+	defer s.cleanup()
+
+	reader := bufio.NewReaderSize(s.conn, s.gateway.config.MaxLineSize)
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(s.gateway.config.IdleTimeout))
+		line, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		if isPrefix {
+			s.close("Line too long")
+			return
+		}
+
+		message, ok := ParseMessage(string(line))
+		if !ok {
+			continue
+		}
+		var limitErr *services.RateLimitError
+		if errors.As(s.gateway.rateLimiter.AllowFrame(s.connectionID), &limitErr) {
+			if !s.rejectRateLimited(limitErr) {
+				return
+			}
+			continue
+		}
+		if !s.handle(message) {
+			return
+		}
+	}
+}
+
+// handle executes one command, returning false when the session should end
+func (s *session) handle(message Message) bool {
+	switch message.Command {
+	case "CAP":
+		// No capabilities are offered, but answering LS keeps modern clients from waiting
+		if message.Param(0) == "LS" {
+			s.reply("CAP", "*", "LS", "")
+		}
+	case "PASS":
+		s.password = message.Param(0)
+	case "NICK":
+		s.handleNick(message)
+	case "USER":
+		if len(message.Params) < 4 {
+			s.numeric(errNeedMoreParams, "USER", "Not enough parameters")
+			return true
+		}
+		s.username = message.Param(0)
+		s.tryRegister()
+	case "PING":
+		s.reply("PONG", s.gateway.config.ServerName, message.Param(0))
+	case "PONG":
+		// Read deadline was already refreshed by receiving the line
+	case "QUIT":
+		s.close("Quit: " + message.Param(0))
+		return false
+	default:
+		if !s.registered {
+			s.numeric(errNotRegistered, "You have not registered")
+			return true
+		}
+		s.handleRegistered(message)
+	}
+	return true
+}
+
+func (s *session) handleRegistered(message Message) {
+	switch message.Command {
+	case "JOIN":
+		for _, channel := range strings.Split(message.Param(0), ",") {
+			s.join(channel)
+		}
+	case "PART":
+		for _, channel := range strings.Split(message.Param(0), ",") {
+			s.part(channel, message.Param(1))
+		}
+	case "PRIVMSG", "NOTICE":
+		s.privmsg(message)
+	case "MODE", "WHO", "USERHOST":
+		// Not supported, silently ignored so clients don't complain on connect
+	default:
+		s.numeric(errUnknownCommand, message.Command, "Unknown command")
+	}
+}
+
+func (s *session) handleNick(message Message) {
+	nick := message.Param(0)
+	if nick == "" {
+		s.numeric(errNoNicknameGiven, "No nickname given")
+		return
+	}
+	if !nickPattern.MatchString(nick) {
+		s.numeric(errErroneusNick, nick, "Erroneous nickname")
+		return
+	}
+	if s.registered {
+		// The nick is the user ID, renaming would mean becoming another user
+		s.numeric(errErroneusNick, nick, "Nick changes are not supported")
+		return
+	}
+	if s.gateway.botService.LookupBot(nick) != nil {
+		s.numeric(errNicknameInUse, nick, "Nickname belongs to a bot")
+		return
+	}
+	s.nick = nick
+	s.tryRegister()
+}
+
+// tryRegister completes registration once both NICK and USER were received
+func (s *session) tryRegister() {
+	if s.registered || s.nick == "" || s.username == "" {
+		return
+	}
+	var expiry time.Time
+	if s.gateway.authService.Enabled() {
+		// The token is sent as the server password, its subject must be the nick
+		claims, err := s.gateway.authService.AuthenticateUser(s.password, s.nick)
+		if err != nil {
+			log.Printf("Rejected IRC registration of %s: %v", s.nick, err)
+			s.numeric(errPasswdMismatch, "Password incorrect: "+err.Error())
+			s.close("Bad password")
+			return
+		}
+		expiry = claims.Expiry()
+		s.roles = claims.Roles
+		s.tenant = claims.Tenant
+	}
+	if s.gateway.sanctions.Banned(s.nick) {
+		log.Printf("Rejected IRC registration of banned user %s", s.nick)
+		s.numeric(errYoureBannedCreep, "You are banned from this server")
+		s.close("Banned")
+		return
+	}
+
+	if !s.gateway.register(s) {
+		s.numeric(errNicknameInUse, s.nick, "Nickname is already in use")
+		s.nick = ""
+		return
+	}
+	s.registered = true
+	s.password = ""
+	if !expiry.IsZero() {
+		s.expiryTimer = time.AfterFunc(time.Until(expiry), func() { s.close("Token expired") })
+	}
+
+	serverName := s.gateway.config.ServerName
+	s.numeric(rplWelcome, fmt.Sprintf("Welcome to the chat IRC gateway %s", userPrefix(s.nick, serverName)))
+	s.numeric(rplYourHost, fmt.Sprintf("Your host is %s, running on %s", serverName, os.Getenv("SERVER_ID")))
+	s.numeric(rplCreated, "This server was created "+s.connectedAt.Format(time.RFC1123))
+	s.numeric(rplMyInfo, serverName, "chat-gateway", "o", "o")
+	s.numeric(errNoMotd, "MOTD File is missing")
+
+	s.gateway.chatService.SubscribeUserToChatServer(s.nick)
+	go s.keepAlive()
+	log.Printf("IRC session registered for user: %s", s.nick)
+}
+
+func (s *session) join(channel string) {
+	if !strings.HasPrefix(channel, "#") || len(channel) < 2 {
+		s.numeric(errNoSuchNick, channel, "No such channel")
+		return
+	}
+	chatID := chatIDFromChannel(channel)
+	if !validID(chatID) {
+		s.numeric(errNoSuchNick, channel, "No such channel")
+		return
+	}
+	// Joining creates a chat that has no members yet, existing chats need an invite
+	created, err := s.gateway.memberships.Join(chatID, s.nick)
+	if errors.Is(err, services.ErrNotChatMember) || errors.Is(err, services.ErrDirectChat) || errors.Is(err, services.ErrChatHasTraffic) {
+		s.numeric(errInviteOnlyChan, channel, "Cannot join channel (+i)")
+		return
+	}
+	if err != nil {
+		log.Printf("Error joining user %s to chat %s: %v", s.nick, chatID, err)
+		return
+	}
+	if created {
+		s.recordMembership(constants.AuditChatMemberAdded, chatID)
+	}
+
+	s.channelsMutex.Lock()
+	s.channels[chatID] = true
+	s.channelsMutex.Unlock()
+
+	s.send(userPrefix(s.nick, s.gateway.config.ServerName), "JOIN", channel)
+	s.numeric(rplNoTopic, channel, "No topic is set")
+	members, _ := s.gateway.memberships.Members(chatID)
+	// Names are separated by spaces, members whose ID can't be a nick are left out
+	names := make([]string, 0, len(members))
+	for _, member := range members {
+		if validID(member) {
+			names = append(names, member)
+		}
+	}
+	s.numeric(rplNameReply, "=", channel, strings.Join(names, " "))
+	s.numeric(rplEndOfNames, channel, "End of /NAMES list")
+}
+
+// part leaves a channel, which removes the user from the group chat
+func (s *session) part(channel, reason string) {
+	chatID := chatIDFromChannel(channel)
+	if !s.hasJoined(chatID) {
+		s.numeric(errNotOnChannel, channel, "You're not on that channel")
+		return
+	}
+	if err := s.gateway.memberships.RemoveMember(chatID, s.nick); err != nil {
+		log.Printf("Error removing user %s from chat %s: %v", s.nick, chatID, err)
+	} else {
+		s.recordMembership(constants.AuditChatMemberRemoved, chatID)
+	}
+
+	s.channelsMutex.Lock()
+	delete(s.channels, chatID)
+	s.channelsMutex.Unlock()
+	s.send(userPrefix(s.nick, s.gateway.config.ServerName), "PART", channel, reason)
+}
+
+// recordMembership audits a membership change the user made over IRC, as the REST
+// routes do for theirs
+func (s *session) recordMembership(action, chatID string) {
+	host, _, _ := net.SplitHostPort(s.conn.RemoteAddr().String())
+	s.gateway.audit.Record(models.AuditEntry{
+		Action:   action,
+		Actor:    s.nick,
+		Target:   chatID,
+		SourceIP: host,
+		Details:  map[string]string{"user_id": s.nick, "via": "irc"},
+	})
+}
+
+func (s *session) privmsg(message Message) {
+	// NOTICE must never trigger automatic replies, errors included
+	isNotice := message.Command == "NOTICE"
+	target, text := message.Param(0), message.Param(1)
+	if target == "" {
+		if !isNotice {
+			s.numeric(errNoRecipient, "No recipient given (PRIVMSG)")
+		}
+		return
+	}
+	if text == "" {
+		if !isNotice {
+			s.numeric(errNoTextToSend, "No text to send")
+		}
+		return
+	}
+	s.messagesReceived.Add(1)
+
+	if strings.HasPrefix(target, "#") {
+		chatID := chatIDFromChannel(target)
+		if !s.hasJoined(chatID) {
+			if !isNotice {
+				s.numeric(errNotOnChannel, target, "You're not on that channel")
+			}
+			return
+		}
+		err := s.gateway.chatService.SendMessageToChat(s.sender(), chatID, constants.MessageTypeText, text)
+		var policyErr *services.PolicyError
+		var limitErr *services.RateLimitError
+		if errors.As(err, &limitErr) {
+			s.rejectRateLimited(limitErr)
+		} else if errors.As(err, &policyErr) {
+			if !isNotice {
+				s.numeric(errCannotSendToChan, target, policyErr.Reason)
+			}
+		} else if err != nil {
+			log.Printf("Error sending IRC channel message from %s: %v", s.nick, err)
+		}
+		return
+	}
+
+	_, err := s.gateway.chatService.SendMessageToUser(s.sender(), dtos.ChatMessageDto{
+		ChatID:         models.DirectChatID(s.nick, target),
+		ReceiverUserID: target,
+		MessageType:    constants.MessageTypeText,
+		Message:        text,
+	})
+	var limitErr *services.RateLimitError
+	if errors.As(err, &limitErr) {
+		s.rejectRateLimited(limitErr)
+		return
+	}
+	if err != nil {
+		log.Printf("Error sending IRC private message from %s: %v", s.nick, err)
+		if !isNotice {
+			s.numeric(errNoSuchNick, target, err.Error())
+		}
+	}
+}
+
+// rejectRateLimited tells the client a line was dropped for exceeding a rate limit. It
+// returns false once the user exceeded the limits so often that the session was closed.
+func (s *session) rejectRateLimited(limitErr *services.RateLimitError) bool {
+	nick, offender := s.nick, s.nick
+	if !s.registered {
+		nick, offender = "*", s.connectionID
+	}
+	s.reply("NOTICE", nick, "Message dropped: "+limitErr.Error())
+	if s.gateway.rateLimiter.RecordViolation(offender, limitErr) {
+		log.Printf("Disconnecting IRC user %s for flooding", s.nick)
+		s.close("Excess Flood")
+		return false
+	}
+	return true
+}
+
+func (s *session) sender() services.Sender {
+	return services.Sender{UserID: s.nick, Roles: s.roles, Tenant: s.tenant}
+}
+
+func (s *session) hasJoined(chatID string) bool {
+	s.channelsMutex.RLock()
+	defer s.channelsMutex.RUnlock()
+	return s.channels[chatID]
+}
+
+// send writes one protocol line with the given prefix
+func (s *session) send(prefix, command string, params ...string) error {
+	if !validLine(prefix, params) {
+		return errInvalidLine
+	}
+
+	var line strings.Builder
+	if prefix != "" {
+		line.WriteString(":" + prefix + " ")
+	}
+	line.WriteString(command)
+	for i, param := range params {
+		// The last parameter is always sent as trailing so it may contain spaces
+		if i == len(params)-1 {
+			line.WriteString(" :" + param)
+		} else {
+			line.WriteString(" " + param)
+		}
+	}
+	line.WriteString("\r\n")
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := s.conn.Write([]byte(line.String()))
+	return err
+}
+
+// reply sends a command from the server
+func (s *session) reply(command string, params ...string) error {
+	return s.send(s.gateway.config.ServerName, command, params...)
+}
+
+// numeric sends a numeric reply addressed to the session's nick
+func (s *session) numeric(code string, params ...string) error {
+	nick := s.nick
+	if nick == "" {
+		nick = "*"
+	}
+	return s.reply(code, append([]string{nick}, params...)...)
+}
+
+func (s *session) close(reason string) {
+	s.closeOnce.Do(func() {
+		s.send("", "ERROR", reason)
+		s.conn.Close()
+		close(s.closed)
+	})
+}
+
+// keepAlive pings a registered client every PingInterval, so that clients which never
+// ping still answer within the idle timeout, and renews its registry entry
+func (s *session) keepAlive() {
+	interval, ping := s.gateway.config.PingInterval, true
+	if interval <= 0 {
+		interval, ping = registryRefreshInterval, false
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-ticker.C:
+			if ping {
+				s.reply("PING", s.gateway.config.ServerName)
+			}
+			if err := s.gateway.chatService.RefreshUserChatServer(s.nick); err != nil {
+				log.Printf("Error refreshing registry entry of user %s: %v", s.nick, err)
+			}
+		}
+	}
+}
+
+// cleanup unregisters the session once its read loop ends
+func (s *session) cleanup() {
+	if s.expiryTimer != nil {
+		s.expiryTimer.Stop()
+	}
+	s.close("Connection closed")
+	if !s.registered {
+		return
+	}
+
+	s.gateway.unregister(s)
+	s.gateway.chatService.UnsubscribeUserToChatServer(s.nick)
+	log.Printf("IRC session closed for user: %s", s.nick)
+}
+
+func (s *session) info() models.ConnectionInfo {
+	return models.ConnectionInfo{
+		UserID:           s.nick,
+		ServerID:         os.Getenv("SERVER_ID"),
+		RemoteAddr:       s.conn.RemoteAddr().String(),
+		Subprotocol:      "irc",
+		ConnectedAt:      s.connectedAt,
+		MessagesReceived: s.messagesReceived.Load(),
+		MessagesSent:     s.messagesSent.Load(),
+	}
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAdmin sets up the connection inspection admin routes
+func SetupAdmin(router *gin.RouterGroup) {
+	// Resolve the adminHandler from the DI container
+	var adminHandler *handlers.AdminHandler
+	err := di.Container.Invoke(func(h *handlers.AdminHandler) {
+		adminHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve AdminHandler: %v", err)
+	}
+
+	router.GET("/connections", adminHandler.ListConnections)
+	router.GET("/users/:user_id/server", adminHandler.LookupUserServer)
+	router.POST("/users/:user_id/disconnect", adminHandler.DisconnectUser)
+	router.GET("/metrics", handlers.Metrics)
+}
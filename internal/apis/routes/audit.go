@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAudit sets up the admin routes to query, export and verify the audit log
+func SetupAudit(router *gin.RouterGroup) {
+	// Resolve the auditHandler from the DI container
+	var auditHandler *handlers.AuditHandler
+	err := di.Container.Invoke(func(h *handlers.AuditHandler) {
+		auditHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve AuditHandler: %v", err)
+	}
+
+	router.GET("", auditHandler.ListAudit)
+	router.GET("/export", auditHandler.ExportAudit)
+	router.GET("/verify", auditHandler.VerifyAudit)
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupBlock sets up the block list routes
+func SetupBlock(router *gin.RouterGroup) {
+	// Resolve the blockHandler from the DI container
+	var blockHandler *handlers.BlockHandler
+	err := di.Container.Invoke(func(h *handlers.BlockHandler) {
+		blockHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve BlockHandler: %v", err)
+	}
+
+	router.GET("/:user_id/blocks", blockHandler.ListBlocked)
+	router.PUT("/:user_id/blocks/:blocked_user_id", blockHandler.Block)
+	router.DELETE("/:user_id/blocks/:blocked_user_id", blockHandler.Unblock)
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupBot sets up the bot registry admin routes
+func SetupBot(router *gin.RouterGroup) {
+	// Resolve the botHandler from the DI container
+	var botHandler *handlers.BotHandler
+	err := di.Container.Invoke(func(h *handlers.BotHandler) {
+		botHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve BotHandler: %v", err)
+	}
+
+	router.POST("", botHandler.CreateBot)
+	router.GET("", botHandler.ListBots)
+	router.DELETE("/:id", botHandler.DeleteBot)
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupChat sets up the group chat membership routes
+func SetupChat(router *gin.RouterGroup) {
+	// Resolve the chatHandler from the DI container
+	var chatHandler *handlers.ChatHandler
+	err := di.Container.Invoke(func(h *handlers.ChatHandler) {
+		chatHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve ChatHandler: %v", err)
+	}
+
+	router.GET("/:chat_id/members", chatHandler.ListMembers)
+	router.POST("/:chat_id/members", chatHandler.AddMember)
+	router.DELETE("/:chat_id/members/:user_id", chatHandler.RemoveMember)
+}
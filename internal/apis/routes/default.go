@@ -2,13 +2,62 @@ package routes
 
 import (
 	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/di"
+	"log"
 
 	"github.com/gin-gonic/gin"
 )
 
 func Setup(router *gin.Engine) {
+	// Resolve the originHandler from the DI container
+	var originHandler *handlers.OriginHandler
+	err := di.Container.Invoke(func(h *handlers.OriginHandler) {
+		originHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve OriginHandler: %v", err)
+	}
+	// Registered before any route so it also answers preflights of every path
+	router.Use(originHandler.CORS)
+
 	router.GET("/", handlers.HealthCheck)
 
 	wsGroup := router.Group("/ws")
 	SetupWebSocket(wsGroup)
+
+	// Resolve the authHandler from the DI container
+	var authHandler *handlers.AuthHandler
+	err = di.Container.Invoke(func(h *handlers.AuthHandler) {
+		authHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve AuthHandler: %v", err)
+	}
+
+	SetupPresence(router.Group("/users"))
+	// A user's own settings may only be read and changed with their token
+	selfGroup := router.Group("/users", authHandler.RequireSelf)
+	SetupDigest(selfGroup)
+	SetupPush(selfGroup)
+	SetupBlock(selfGroup)
+	SetupKeys(selfGroup)
+	// Service accounts with the read_history scope may read any user's messages
+	SetupMessageHistory(router.Group("/users", authHandler.RequireSelfOrScope(constants.ScopeReadHistory)))
+	chatGroup := router.Group("/chats", authHandler.RequireUser)
+	SetupChat(chatGroup)
+	SetupMessages(chatGroup)
+	SetupKeyBundles(router.Group("/keys", authHandler.RequireUser))
+	SetupReports(router.Group("/reports", authHandler.RequireUser))
+
+	adminGroup := router.Group("/admin", authHandler.RequireService)
+	SetupAdmin(adminGroup)
+	SetupWebhook(adminGroup.Group("/webhooks"))
+	SetupBot(adminGroup.Group("/bots"))
+	SetupModeration(adminGroup.Group("/moderation"))
+	SetupModerationQueue(adminGroup)
+	SetupServiceAccounts(adminGroup.Group("/service-accounts"))
+	SetupAudit(adminGroup.Group("/audit"))
+	SetupPrivacy(adminGroup.Group("/privacy"))
+	SetupEncryption(adminGroup.Group("/encryption"))
 }
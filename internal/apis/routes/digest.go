@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupDigest sets up the email digest preference routes
+func SetupDigest(router *gin.RouterGroup) {
+	// Resolve the digestHandler from the DI container
+	var digestHandler *handlers.DigestHandler
+	err := di.Container.Invoke(func(h *handlers.DigestHandler) {
+		digestHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve DigestHandler: %v", err)
+	}
+
+	router.GET("/:user_id/digest", digestHandler.GetPreferences)
+	router.PUT("/:user_id/digest", digestHandler.UpdatePreferences)
+}
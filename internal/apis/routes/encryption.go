@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupEncryption sets up the admin routes to rotate and shred the keys of encryption at rest
+func SetupEncryption(router *gin.RouterGroup) {
+	// Resolve the encryptionHandler from the DI container
+	var encryptionHandler *handlers.EncryptionHandler
+	err := di.Container.Invoke(func(h *handlers.EncryptionHandler) {
+		encryptionHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve EncryptionHandler: %v", err)
+	}
+
+	router.POST("/master-key/rotate", encryptionHandler.RotateMasterKey)
+	router.GET("/tenants/:tenant/keys", encryptionHandler.GetKeys)
+	router.POST("/tenants/:tenant/keys/rotate", encryptionHandler.RotateDataKey)
+	router.POST("/tenants/:tenant/reencrypt", encryptionHandler.StartReencryption)
+	router.GET("/tenants/:tenant/reencrypt", encryptionHandler.GetReencryption)
+	router.POST("/tenants/:tenant/shred", encryptionHandler.Shred)
+}
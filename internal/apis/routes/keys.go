@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupKeys sets up the routes devices use to publish their end-to-end encryption keys
+func SetupKeys(router *gin.RouterGroup) {
+	keyHandler := resolveKeyHandler()
+
+	router.PUT("/:user_id/keys/:device_id", keyHandler.PublishKeys)
+	router.GET("/:user_id/keys/:device_id", keyHandler.GetDeviceKeys)
+	router.DELETE("/:user_id/keys/:device_id", keyHandler.RemoveDeviceKeys)
+	router.POST("/:user_id/keys/:device_id/prekeys", keyHandler.UploadPreKeys)
+}
+
+// SetupKeyBundles sets up the route peers fetch prekey bundles from
+func SetupKeyBundles(router *gin.RouterGroup) {
+	keyHandler := resolveKeyHandler()
+
+	router.GET("/:user_id", keyHandler.FetchBundles)
+}
+
+func resolveKeyHandler() *handlers.KeyHandler {
+	// Resolve the keyHandler from the DI container
+	var keyHandler *handlers.KeyHandler
+	err := di.Container.Invoke(func(h *handlers.KeyHandler) {
+		keyHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve KeyHandler: %v", err)
+	}
+	return keyHandler
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupMessages sets up the REST route for sending messages into a chat
+func SetupMessages(router *gin.RouterGroup) {
+	messageHandler := resolveMessageHandler()
+
+	router.POST("/:chat_id/messages", messageHandler.SendMessage)
+}
+
+// SetupMessageHistory sets up the REST route for reading a user's stored messages
+func SetupMessageHistory(router *gin.RouterGroup) {
+	messageHandler := resolveMessageHandler()
+
+	router.GET("/:user_id/messages", messageHandler.ListMessages)
+}
+
+func resolveMessageHandler() *handlers.MessageHandler {
+	// Resolve the messageHandler from the DI container
+	var messageHandler *handlers.MessageHandler
+	err := di.Container.Invoke(func(h *handlers.MessageHandler) {
+		messageHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve MessageHandler: %v", err)
+	}
+	return messageHandler
+}
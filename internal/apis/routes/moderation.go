@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupModeration sets up the admin routes for moderation rules and the hits they record
+func SetupModeration(router *gin.RouterGroup) {
+	// Resolve the moderationHandler from the DI container
+	var moderationHandler *handlers.ModerationHandler
+	err := di.Container.Invoke(func(h *handlers.ModerationHandler) {
+		moderationHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve ModerationHandler: %v", err)
+	}
+
+	router.GET("/tenants/:tenant_id/rules", moderationHandler.GetRules)
+	router.PUT("/tenants/:tenant_id/rules", moderationHandler.SetRules)
+	router.DELETE("/tenants/:tenant_id/rules", moderationHandler.DeleteRules)
+	router.GET("/chats/:chat_id/rules", moderationHandler.GetRules)
+	router.PUT("/chats/:chat_id/rules", moderationHandler.SetRules)
+	router.DELETE("/chats/:chat_id/rules", moderationHandler.DeleteRules)
+	router.GET("/hits", moderationHandler.ListHits)
+}
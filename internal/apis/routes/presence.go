@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPresence sets up the user presence routes
+func SetupPresence(router *gin.RouterGroup) {
+	// Resolve the presenceHandler from the DI container
+	var presenceHandler *handlers.PresenceHandler
+	err := di.Container.Invoke(func(h *handlers.PresenceHandler) {
+		presenceHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve PresenceHandler: %v", err)
+	}
+
+	router.GET("/:user_id/presence", presenceHandler.GetPresence)
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPrivacy sets up the admin routes to export and erase a user's data
+func SetupPrivacy(router *gin.RouterGroup) {
+	// Resolve the privacyHandler from the DI container
+	var privacyHandler *handlers.PrivacyHandler
+	err := di.Container.Invoke(func(h *handlers.PrivacyHandler) {
+		privacyHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve PrivacyHandler: %v", err)
+	}
+
+	router.POST("/users/:user_id/export", privacyHandler.StartExport)
+	router.POST("/users/:user_id/erasure", privacyHandler.StartErasure)
+	router.GET("/jobs/:job_id", privacyHandler.GetJob)
+	router.GET("/jobs/:job_id/archive", privacyHandler.DownloadArchive)
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPush sets up the device registration and notification settings routes
+func SetupPush(router *gin.RouterGroup) {
+	// Resolve the pushHandler from the DI container
+	var pushHandler *handlers.PushHandler
+	err := di.Container.Invoke(func(h *handlers.PushHandler) {
+		pushHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve PushHandler: %v", err)
+	}
+
+	router.POST("/:user_id/devices", pushHandler.RegisterDevice)
+	router.GET("/:user_id/devices", pushHandler.ListDevices)
+	router.DELETE("/:user_id/devices/:token", pushHandler.UnregisterDevice)
+	router.GET("/:user_id/notifications", pushHandler.GetSettings)
+	router.PUT("/:user_id/notifications", pushHandler.UpdateSettings)
+}
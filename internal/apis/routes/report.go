@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupReports sets up the route users file abuse reports with
+func SetupReports(router *gin.RouterGroup) {
+	reportHandler := resolveReportHandler()
+
+	router.POST("", reportHandler.CreateReport)
+}
+
+// SetupModerationQueue sets up the admin routes to review reports and sanction users
+func SetupModerationQueue(router *gin.RouterGroup) {
+	reportHandler := resolveReportHandler()
+
+	router.GET("/reports", reportHandler.ListReports)
+	router.GET("/reports/:report_id", reportHandler.GetReport)
+	router.PATCH("/reports/:report_id", reportHandler.UpdateReport)
+	router.POST("/reports/:report_id/actions", reportHandler.ActOnReport)
+	router.GET("/users/:user_id/sanctions", reportHandler.ListSanctions)
+	router.DELETE("/users/:user_id/sanctions/:kind", reportHandler.LiftSanction)
+}
+
+func resolveReportHandler() *handlers.ReportHandler {
+	// Resolve the reportHandler from the DI container
+	var reportHandler *handlers.ReportHandler
+	err := di.Container.Invoke(func(h *handlers.ReportHandler) {
+		reportHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve ReportHandler: %v", err)
+	}
+	return reportHandler
+}
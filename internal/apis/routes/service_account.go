@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupServiceAccounts sets up the admin routes managing service accounts and their API keys
+func SetupServiceAccounts(router *gin.RouterGroup) {
+	// Resolve the serviceAccountHandler from the DI container
+	var serviceAccountHandler *handlers.ServiceAccountHandler
+	err := di.Container.Invoke(func(h *handlers.ServiceAccountHandler) {
+		serviceAccountHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve ServiceAccountHandler: %v", err)
+	}
+
+	router.POST("", serviceAccountHandler.CreateServiceAccount)
+	router.GET("", serviceAccountHandler.ListServiceAccounts)
+	router.GET("/:account_id", serviceAccountHandler.GetServiceAccount)
+	router.PATCH("/:account_id", serviceAccountHandler.UpdateServiceAccount)
+	router.DELETE("/:account_id", serviceAccountHandler.DeleteServiceAccount)
+	router.POST("/:account_id/keys/rotate", serviceAccountHandler.RotateKey)
+	router.DELETE("/:account_id/keys/:key_id", serviceAccountHandler.RevokeKey)
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/di"
+
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupWebhook sets up the webhook subscription admin routes
+func SetupWebhook(router *gin.RouterGroup) {
+	// Resolve the webhookHandler from the DI container
+	var webhookHandler *handlers.WebhookHandler
+	err := di.Container.Invoke(func(h *handlers.WebhookHandler) {
+		webhookHandler = h
+	})
+	if err != nil {
+		log.Fatalf("Failed to resolve WebhookHandler: %v", err)
+	}
+
+	router.POST("", webhookHandler.CreateSubscription)
+	router.GET("", webhookHandler.ListSubscriptions)
+	router.DELETE("/:id", webhookHandler.DeleteSubscription)
+	router.GET("/dead-letters", webhookHandler.ListDeadLetters)
+}
@@ -21,5 +21,7 @@ func SetupWebSocket(router *gin.RouterGroup) {
 	}
 
 	// Define the WebSocket route
+	router.GET("/user", socketHandler.InitWebSocket)
 	router.GET("/user/:user_id", socketHandler.InitWebSocket)
+	router.GET("/bot/:bot_id", socketHandler.InitBotWebSocket)
 }
@@ -0,0 +1,29 @@
+package constants
+
+// Actions recorded in the audit log
+const (
+	AuditUserDisconnected       = "user.disconnect"
+	AuditReportAction           = "report.action" // Message deletions, warnings, mutes and bans
+	AuditSanctionLifted         = "sanction.lift"
+	AuditServiceAccountCreated  = "service_account.create"
+	AuditServiceAccountUpdated  = "service_account.update"
+	AuditServiceAccountDeleted  = "service_account.delete"
+	AuditAPIKeyRotated          = "api_key.rotate"
+	AuditAPIKeyRevoked          = "api_key.revoke"
+	AuditChatMemberAdded        = "chat.member_add"
+	AuditChatMemberRemoved      = "chat.member_remove"
+	AuditModerationRulesUpdated = "moderation.rules_update"
+	AuditModerationRulesDeleted = "moderation.rules_delete"
+	AuditLogExported            = "audit.export"
+	AuditUserExportRequested    = "user.export"
+	AuditUserExportDownloaded   = "user.export_download"
+	AuditUserErasureRequested   = "user.erase"
+	AuditDataKeyRotated         = "encryption.data_key_rotate"
+	AuditMasterKeyRotated       = "encryption.master_key_rotate"
+	AuditReencryptionStarted    = "encryption.reencrypt"
+	AuditTenantShredded         = "encryption.shred"
+	AuditBotRegistered          = "bot.register"
+	AuditBotDeleted             = "bot.delete"
+	AuditWebhookCreated         = "webhook.create"
+	AuditWebhookDeleted         = "webhook.delete"
+)
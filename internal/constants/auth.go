@@ -0,0 +1,21 @@
+package constants
+
+// Clients that can't set headers on the upgrade request (browsers) may offer the token
+// as an extra subprotocol "bearer.<jwt>" next to the frame encoding
+const AuthSubprotocolPrefix = "bearer."
+
+// CloseTokenExpired is the close code sent when the session's token expires
+const CloseTokenExpired = 4001
+
+// APIKeyPrefix starts every service account API key, so keys can be told apart from JWTs
+const APIKeyPrefix = "dcs_"
+
+// API key scopes of service accounts
+const (
+	ScopeSend        = "send"         // Connect over WebSocket and send messages as the account
+	ScopeReadHistory = "read_history" // Read any user's stored messages
+	ScopeAdmin       = "admin"        // Call the /admin API
+)
+
+// AllScopes lists every scope a service account may be granted
+var AllScopes = []string{ScopeSend, ScopeReadHistory, ScopeAdmin}
@@ -0,0 +1,7 @@
+package constants
+
+// Bot transports
+const (
+	BotTransportWebhook = "webhook" // Invocations are POSTed, the response body carries the reply
+	BotTransportStream  = "stream"  // Bot holds a gRPC stream, or a WebSocket on /ws/bot/:bot_id
+)
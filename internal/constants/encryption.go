@@ -0,0 +1,12 @@
+package constants
+
+// EncryptionDefaultTenant holds the data key for senders whose token names no tenant,
+// the same tenant their moderation rules come from
+const EncryptionDefaultTenant = ModerationDefaultTenant
+
+// Re-encryption job statuses
+const (
+	ReencryptionRunning   = "running"
+	ReencryptionCompleted = "completed"
+	ReencryptionFailed    = "failed"
+)
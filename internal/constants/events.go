@@ -0,0 +1,32 @@
+package constants
+
+// Chat event types carried on the per-server Kafka topic and fanned out to webhooks
+const (
+	EventMessageSent      = "message.sent"
+	EventMessageDelivered = "message.delivered"
+	EventMessageRead      = "message.read"
+	EventUserConnected    = "user.connected"
+	EventUserDisconnected = "user.disconnected"
+)
+
+// AllEventTypes lists every event a webhook subscription may filter on
+var AllEventTypes = []string{
+	EventMessageSent,
+	EventMessageDelivered,
+	EventMessageRead,
+	EventUserConnected,
+	EventUserDisconnected,
+}
+
+// Control events are addressed to the server holding a user's socket and never reach webhooks
+const (
+	EventControlDisconnect = "control.disconnect"
+	EventControlReceipt    = "control.receipt"    // Delivery or read receipt routed to the original sender
+	EventControlModeration = "control.moderation" // A moderator acted on a user or on a message they received
+)
+
+// Receipt statuses
+const (
+	ReceiptStatusDelivered = "delivered"
+	ReceiptStatusRead      = "read"
+)
@@ -0,0 +1,33 @@
+package constants
+
+// Inbound WebSocket frame types, an empty type is treated as a message
+const (
+	FrameTypeMessage  = "message"
+	FrameTypeRead     = "read"
+	FrameTypeBotReply = "bot_reply" // Sent by stream bots, event_id is the invocation being answered
+	FrameTypeAuth     = "auth"      // Carries a fresh token in message, extending the session
+)
+
+// Outbound WebSocket frame types
+const (
+	FrameTypeError      = "error"
+	FrameTypeAck        = "ack"        // Answers a message frame that carried a client_msg_id
+	FrameTypeResumed    = "resumed"    // Replay after a resume handshake is complete, live delivery follows
+	FrameTypeReceipt    = "receipt"    // A message this user sent was delivered or read
+	FrameTypeModeration = "moderation" // A moderator warned or muted this user, or deleted a message they received
+	// FrameTypeBotInvocation carries an invocation on a gRPC bot stream
+	FrameTypeBotInvocation = MessageTypeBotInvocation
+)
+
+// CloseIdleTimeout is the close code sent to sockets that sent no frames for WS_IDLE_TIMEOUT
+const CloseIdleTimeout = 4008
+
+// MessageTypeCiphertext marks an end-to-end encrypted message. The server routes it
+// without reading, storing or logging the message.
+const MessageTypeCiphertext = "ciphertext"
+
+// Message types set by the server
+const (
+	MessageTypeText          = "text"
+	MessageTypeBotInvocation = "bot_invocation" // Message holds a JSON models.BotInvocation
+)
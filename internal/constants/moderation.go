@@ -0,0 +1,25 @@
+package constants
+
+// Moderation actions a filter rule takes on a match
+const (
+	ModerationActionRedact = "redact" // Masks the match and delivers the message
+	ModerationActionFlag   = "flag"   // Delivers the message unchanged and records it for review
+	ModerationActionReject = "reject" // Refuses the message
+)
+
+// Built-in moderation filters
+const (
+	ModerationFilterProfanity = "profanity" // Whole words, case-insensitive
+	ModerationFilterLink      = "link"      // URLs and bare domains on a block list
+	ModerationFilterRegex     = "regex"     // Arbitrary patterns, e.g. for PII
+)
+
+// Moderation rule scopes, chat rules take precedence over tenant rules
+const (
+	ModerationScopeTenant = "tenant"
+	ModerationScopeChat   = "chat"
+)
+
+// ModerationDefaultTenant holds the rules for senders whose token names no tenant, and
+// for tenants without rules of their own
+const ModerationDefaultTenant = "default"
@@ -0,0 +1,28 @@
+package constants
+
+// Actions checked by the authorization policy
+const (
+	ActionSendMessage = "message.send"
+	ActionInvokeBot   = "bot.invoke"
+)
+
+// Roles assigned by the server itself; other roles come from token claims
+const (
+	RoleUser    = "user"
+	RoleBot     = "bot"
+	RoleService = "service" // Service accounts authenticated by API key
+)
+
+// RoleAdmin is the token role that may call the /admin API
+const RoleAdmin = "admin"
+
+// Codes of denied sends, returned in ack and error frames
+const (
+	DenyNotMember         = "not_member"
+	DenyBlocked           = "blocked"
+	DenyForbidden         = "forbidden"
+	DenyPolicyUnavailable = "policy_unavailable"
+	DenyModerated         = "moderated" // Rejected by a moderation filter
+	DenyMuted             = "muted"     // Sender is muted by a moderator
+	DenyBanned            = "banned"    // Sender is banned by a moderator
+)
@@ -0,0 +1,15 @@
+package constants
+
+// Kinds of privacy jobs run for data subject requests
+const (
+	PrivacyJobExport  = "export"  // Collects everything held about a user into an archive
+	PrivacyJobErasure = "erasure" // Deletes everything held about a user
+)
+
+// Privacy job statuses
+const (
+	PrivacyJobPending   = "pending"
+	PrivacyJobRunning   = "running"
+	PrivacyJobCompleted = "completed"
+	PrivacyJobFailed    = "failed"
+)
@@ -0,0 +1,14 @@
+package constants
+
+// Rate limit scopes, each with its own token bucket
+const (
+	RateLimitScopeConnection = "connection" // Every frame read from one socket
+	RateLimitScopeUser       = "user"       // Messages sent by one user across all servers
+	RateLimitScopeChat       = "chat"       // Messages sent into one chat by anyone
+)
+
+// ErrorRateLimited is the code of frames rejected by a rate limit
+const ErrorRateLimited = "rate_limited"
+
+// CloseRateLimited is the close code sent to users who keep exceeding rate limits
+const CloseRateLimited = 4029
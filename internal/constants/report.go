@@ -0,0 +1,23 @@
+package constants
+
+// Abuse report statuses. Open and reviewing reports are in the moderation queue.
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusReviewing = "reviewing"
+	ReportStatusActioned  = "actioned"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Actions a moderator takes on a report
+const (
+	ReportActionDeleteMessage = "delete_message" // Removes the reported message from inboxes and open clients
+	ReportActionWarn          = "warn"           // Tells the reported user, if connected
+	ReportActionMute          = "mute"           // The reported user can't send until the mute ends
+	ReportActionBan           = "ban"            // The reported user is disconnected and can't connect until the ban ends
+)
+
+// Sanctions kept per user, set by the mute and ban actions
+const (
+	SanctionMute = "mute"
+	SanctionBan  = "ban"
+)
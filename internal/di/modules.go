@@ -1,13 +1,24 @@
 package di
 
 import (
+	"crypto/tls"
+	"distributed-chat-system/internal/apis/botgrpc"
 	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/apis/irc"
 	"distributed-chat-system/internal/constants"
 	"distributed-chat-system/internal/services"
+	"distributed-chat-system/internal/utils"
 	"distributed-chat-system/pkg/kafka"
+	"distributed-chat-system/pkg/kms"
+	"distributed-chat-system/pkg/mailer"
+	"distributed-chat-system/pkg/push"
 	"distributed-chat-system/pkg/redis"
+	"distributed-chat-system/pkg/tlsutil"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	redisClient "github.com/redis/go-redis/v9"
 	"go.uber.org/dig"
@@ -29,29 +40,425 @@ func InitializeDependencies() {
 		cfg.Brokers = os.Getenv("KAFKA_URL") // Update as per your environment
 		cfg.Topics = []string{constants.ChatMessageTopic}
 		cfg.GroupID = os.Getenv("CHAT_GROUP_ID") // Use: CHAT_GROUP_ID
+		cfg.TLS = clientTLSConfigFromEnv("KAFKA_TLS")
 		return kafka.NewKafkaClient(cfg), nil
 	})
 	if err != nil {
 		log.Fatalf("Failed to provide KafkaClient: %v", err)
 	}
 
+	// Provide the server's TLS configuration, resolved when TLS_CERT_FILE is set
+	err = Container.Provide(func() (*tls.Config, error) {
+		return tlsutil.ServerConfig(&tlsutil.Config{
+			CertFile:       os.Getenv("TLS_CERT_FILE"),
+			KeyFile:        os.Getenv("TLS_KEY_FILE"),
+			CAFile:         os.Getenv("TLS_CLIENT_CA_FILE"),
+			ClientAuth:     utils.GetEnvString("TLS_CLIENT_AUTH", tlsutil.ClientAuthNone),
+			ReloadInterval: utils.GetEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second),
+		})
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide TLS config: %v", err)
+	}
+
+	// Provide AuthService
+	err = Container.Provide(func() *services.AuthService {
+		service, err := services.NewAuthService(services.AuthConfigFromEnv())
+		if err != nil {
+			log.Fatalf("Failed to initialize authentication: %v", err)
+		}
+		return service
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide AuthService: %v", err)
+	}
+
+	// Provide BotService
+	err = Container.Provide(func() *services.BotService {
+		return services.NewBotService(redisRepo)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide BotService: %v", err)
+	}
+
+	// Provide the KMS stand-in holding the master keys
+	err = Container.Provide(func() (*kms.LocalKMS, error) {
+		path := utils.GetEnvString("KMS_KEYFILE", "kms-keys.json")
+		keyManager, err := kms.NewLocalKMS(path, utils.GetEnvBool("KMS_CREATE_KEYFILE", false))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("KMS keyfile %s not found, set KMS_CREATE_KEYFILE=true to create it: %w", path, err)
+		}
+		return keyManager, err
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide KMS: %v", err)
+	}
+
+	// Provide EncryptionService
+	err = Container.Provide(func(keyManager *kms.LocalKMS) *services.EncryptionService {
+		return services.NewEncryptionService(redisRepo, keyManager, services.EncryptionConfigFromEnv())
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide EncryptionService: %v", err)
+	}
+
+	// Provide InboxService
+	err = Container.Provide(func(encryption *services.EncryptionService) *services.InboxService {
+		return services.NewInboxService(redisRepo, services.InboxConfigFromEnv(), encryption)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide InboxService: %v", err)
+	}
+
+	// Provide MembershipService
+	err = Container.Provide(func() *services.MembershipService {
+		return services.NewMembershipService(redisRepo)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide MembershipService: %v", err)
+	}
+
+	// Provide PushNotifier, logging pushes when no push gateway is configured
+	err = Container.Provide(func() push.PushNotifier {
+		if os.Getenv("PUSH_ENDPOINT") == "" {
+			return push.NewLogNotifier()
+		}
+		return push.NewHTTPNotifier(&push.HTTPConfig{
+			Endpoint: os.Getenv("PUSH_ENDPOINT"),
+			APIKey:   os.Getenv("PUSH_API_KEY"),
+			Timeout:  utils.GetEnvDuration("PUSH_TIMEOUT", 10*time.Second),
+		})
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide PushNotifier: %v", err)
+	}
+
+	// Provide PushService
+	err = Container.Provide(func(notifier push.PushNotifier) *services.PushService {
+		return services.NewPushService(redisRepo, notifier, services.PushConfigFromEnv())
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide PushService: %v", err)
+	}
+
+	// Provide BlockService
+	err = Container.Provide(func() *services.BlockService {
+		return services.NewBlockService(redisRepo)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide BlockService: %v", err)
+	}
+
+	// Provide KeyDirectoryService
+	err = Container.Provide(func() *services.KeyDirectoryService {
+		return services.NewKeyDirectoryService(redisRepo)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide KeyDirectoryService: %v", err)
+	}
+
+	// Provide AuditService
+	err = Container.Provide(func() (*services.AuditService, error) {
+		return services.NewAuditService(redisRepo, services.AuditConfigFromEnv())
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide AuditService: %v", err)
+	}
+
+	// Provide ServiceAccountService
+	err = Container.Provide(func() *services.ServiceAccountService {
+		return services.NewServiceAccountService(redisRepo)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide ServiceAccountService: %v", err)
+	}
+
+	// Provide SanctionService
+	err = Container.Provide(func() *services.SanctionService {
+		return services.NewSanctionService(redisRepo)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide SanctionService: %v", err)
+	}
+
+	// Provide Policy. Replace this provider to plug in another policy engine, or set
+	// POLICY_URL to consult an external one after the built-in rules.
+	err = Container.Provide(func(memberships *services.MembershipService, blockService *services.BlockService, sanctions *services.SanctionService) services.Policy {
+		config := services.PolicyConfigFromEnv()
+		policy := services.PolicyChain{
+			services.NewSanctionPolicy(sanctions),
+			services.NewRolePolicy(config),
+			services.NewMembershipPolicy(memberships),
+			services.NewBlockListPolicy(blockService),
+		}
+		if config.URL != "" {
+			policy = append(policy, services.NewHTTPPolicy(config))
+		}
+		return policy
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide Policy: %v", err)
+	}
+
+	// Provide RateLimitService
+	err = Container.Provide(func() *services.RateLimitService {
+		return services.NewRateLimitService(redisRepo, services.RateLimitConfigFromEnv())
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide RateLimitService: %v", err)
+	}
+
+	// Provide ModerationService
+	err = Container.Provide(func(encryption *services.EncryptionService) *services.ModerationService {
+		return services.NewModerationService(redisRepo, services.ModerationConfigFromEnv(), encryption)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide ModerationService: %v", err)
+	}
+
 	// Provide ChatMessageService
-	err = Container.Provide(func(kafkaClient *kafka.KafkaClient) *services.ChatMessageService {
-		service := services.NewChatMessageService(kafkaClient, redisRepo)
+	err = Container.Provide(func(kafkaClient *kafka.KafkaClient, botService *services.BotService, inboxService *services.InboxService, memberships *services.MembershipService, pushService *services.PushService, policy services.Policy, rateLimiter *services.RateLimitService, moderation *services.ModerationService) *services.ChatMessageService {
+		service := services.NewChatMessageService(kafkaClient, redisRepo, botService, inboxService, memberships, pushService, policy, rateLimiter, moderation)
 		service.StartMessageConsumption()
+		service.StartConnectionReporting(utils.GetEnvDuration("CONNECTION_REPORT_INTERVAL", 15*time.Second))
 		return service
 	})
 	if err != nil {
 		log.Fatalf("Failed to provide ChatMessageService: %v", err)
 	}
 
+	// Provide ReportService
+	err = Container.Provide(func(inboxService *services.InboxService, chatService *services.ChatMessageService, sanctions *services.SanctionService, encryption *services.EncryptionService) *services.ReportService {
+		return services.NewReportService(redisRepo, inboxService, chatService, sanctions, encryption)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide ReportService: %v", err)
+	}
+
+	// Provide PrivacyService
+	err = Container.Provide(func(inboxService *services.InboxService, chatService *services.ChatMessageService, audit *services.AuditService, encryption *services.EncryptionService) *services.PrivacyService {
+		return services.NewPrivacyService(redisRepo, services.PrivacyConfigFromEnv(), inboxService, chatService, audit, encryption)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide PrivacyService: %v", err)
+	}
+
+	// Provide WebhookService
+	err = Container.Provide(func(kafkaClient *kafka.KafkaClient, encryption *services.EncryptionService) (*services.WebhookService, error) {
+		config := services.WebhookConfigFromEnv()
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+		service := services.NewWebhookService(kafkaClient, redisRepo, encryption, config)
+		service.StartDispatching()
+		return service, nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide WebhookService: %v", err)
+	}
+
+	// Provide Mailer, logging mails when no SMTP relay is configured
+	err = Container.Provide(func() mailer.Mailer {
+		if os.Getenv("SMTP_HOST") == "" {
+			return mailer.NewLogMailer()
+		}
+		return mailer.NewSMTPMailer(&mailer.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     utils.GetEnvInt("SMTP_PORT", 587),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     utils.GetEnvString("SMTP_FROM", "chat@localhost"),
+		})
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide Mailer: %v", err)
+	}
+
+	// Provide DigestService
+	err = Container.Provide(func(kafkaClient *kafka.KafkaClient, inboxService *services.InboxService, chatService *services.ChatMessageService, mailer mailer.Mailer) *services.DigestService {
+		service := services.NewDigestService(kafkaClient, redisRepo, inboxService, chatService, mailer, services.DigestConfigFromEnv())
+		service.StartDigests()
+		return service
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide DigestService: %v", err)
+	}
+
+	// Provide WebhookHandler
+	err = Container.Provide(func(webhookService *services.WebhookService, audit *services.AuditService) *handlers.WebhookHandler {
+		return handlers.NewWebhookHandler(webhookService, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide WebhookHandler: %v", err)
+	}
+
+	// Provide BotHandler
+	err = Container.Provide(func(botService *services.BotService, audit *services.AuditService) *handlers.BotHandler {
+		return handlers.NewBotHandler(botService, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide BotHandler: %v", err)
+	}
+
+	// Provide DigestHandler
+	err = Container.Provide(func(digestService *services.DigestService) *handlers.DigestHandler {
+		return handlers.NewDigestHandler(digestService)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide DigestHandler: %v", err)
+	}
+
+	// Provide PushHandler
+	err = Container.Provide(func(pushService *services.PushService) *handlers.PushHandler {
+		return handlers.NewPushHandler(pushService)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide PushHandler: %v", err)
+	}
+
+	// Provide BlockHandler
+	err = Container.Provide(func(blockService *services.BlockService) *handlers.BlockHandler {
+		return handlers.NewBlockHandler(blockService)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide BlockHandler: %v", err)
+	}
+
+	// Provide ChatHandler
+	err = Container.Provide(func(memberships *services.MembershipService, audit *services.AuditService) *handlers.ChatHandler {
+		return handlers.NewChatHandler(memberships, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide ChatHandler: %v", err)
+	}
+
+	// Provide KeyHandler
+	err = Container.Provide(func(keyDirectory *services.KeyDirectoryService) *handlers.KeyHandler {
+		return handlers.NewKeyHandler(keyDirectory)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide KeyHandler: %v", err)
+	}
+
+	// Provide ModerationHandler
+	err = Container.Provide(func(moderation *services.ModerationService, audit *services.AuditService) *handlers.ModerationHandler {
+		return handlers.NewModerationHandler(moderation, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide ModerationHandler: %v", err)
+	}
+
+	// Provide ReportHandler
+	err = Container.Provide(func(reportService *services.ReportService, sanctions *services.SanctionService, audit *services.AuditService) *handlers.ReportHandler {
+		return handlers.NewReportHandler(reportService, sanctions, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide ReportHandler: %v", err)
+	}
+
+	// Provide MessageHandler
+	err = Container.Provide(func(chatService *services.ChatMessageService, inboxService *services.InboxService) *handlers.MessageHandler {
+		return handlers.NewMessageHandler(chatService, inboxService)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide MessageHandler: %v", err)
+	}
+
+	// Provide ServiceAccountHandler
+	err = Container.Provide(func(serviceAccounts *services.ServiceAccountService, chatService *services.ChatMessageService, audit *services.AuditService) *handlers.ServiceAccountHandler {
+		return handlers.NewServiceAccountHandler(serviceAccounts, chatService, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide ServiceAccountHandler: %v", err)
+	}
+
+	// Provide PrivacyHandler
+	err = Container.Provide(func(privacy *services.PrivacyService, audit *services.AuditService) *handlers.PrivacyHandler {
+		return handlers.NewPrivacyHandler(privacy, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide PrivacyHandler: %v", err)
+	}
+
+	// Provide EncryptionHandler
+	err = Container.Provide(func(encryption *services.EncryptionService, audit *services.AuditService) *handlers.EncryptionHandler {
+		return handlers.NewEncryptionHandler(encryption, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide EncryptionHandler: %v", err)
+	}
+
+	// Provide AuditHandler
+	err = Container.Provide(func(audit *services.AuditService) *handlers.AuditHandler {
+		return handlers.NewAuditHandler(audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide AuditHandler: %v", err)
+	}
+
+	// Provide AuthHandler
+	err = Container.Provide(func(authService *services.AuthService, serviceAccounts *services.ServiceAccountService) *handlers.AuthHandler {
+		return handlers.NewAuthHandler(authService, serviceAccounts)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide AuthHandler: %v", err)
+	}
+
+	// Provide PresenceHandler
+	err = Container.Provide(func(chatService *services.ChatMessageService) *handlers.PresenceHandler {
+		return handlers.NewPresenceHandler(chatService)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide PresenceHandler: %v", err)
+	}
+
+	// Provide AdminHandler
+	err = Container.Provide(func(chatService *services.ChatMessageService, audit *services.AuditService) *handlers.AdminHandler {
+		return handlers.NewAdminHandler(chatService, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide AdminHandler: %v", err)
+	}
+
+	// Provide OriginHandler
+	err = Container.Provide(func() (*handlers.OriginHandler, error) {
+		config := handlers.OriginConfigFromEnv()
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+		return handlers.NewOriginHandler(config), nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide OriginHandler: %v", err)
+	}
+
 	// Provide WebSocketHandler
-	err = Container.Provide(func(chatService *services.ChatMessageService) *handlers.WebSocketHandler {
-		return handlers.InitWebSocketHandler(chatService)
+	err = Container.Provide(func(chatService *services.ChatMessageService, botService *services.BotService, authService *services.AuthService, rateLimiter *services.RateLimitService, sanctions *services.SanctionService, serviceAccounts *services.ServiceAccountService, originHandler *handlers.OriginHandler) (*handlers.WebSocketHandler, error) {
+		config := handlers.WebSocketConfigFromEnv()
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+		return handlers.InitWebSocketHandler(chatService, botService, authService, rateLimiter, sanctions, serviceAccounts, originHandler, config), nil
 	})
 	if err != nil {
 		log.Fatalf("Failed to provide WebSocketHandler: %v", err)
 	}
+
+	// Provide IRC Gateway
+	err = Container.Provide(func(chatService *services.ChatMessageService, botService *services.BotService, memberships *services.MembershipService, authService *services.AuthService, rateLimiter *services.RateLimitService, sanctions *services.SanctionService, audit *services.AuditService) *irc.Gateway {
+		return irc.NewGateway(irc.ConfigFromEnv(), chatService, botService, memberships, authService, rateLimiter, sanctions, audit)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide IRC Gateway: %v", err)
+	}
+
+	// Provide gRPC bot stream server
+	err = Container.Provide(func(chatService *services.ChatMessageService, botService *services.BotService, rateLimiter *services.RateLimitService) *botgrpc.Server {
+		return botgrpc.NewServer(botgrpc.ConfigFromEnv(), chatService, botService, rateLimiter)
+	})
+	if err != nil {
+		log.Fatalf("Failed to provide gRPC bot stream server: %v", err)
+	}
 }
 
 // Resolve resolves a dependency from the container
@@ -73,7 +480,7 @@ func initRedis() redis.IRedisRepositories {
 	redisPassword := os.Getenv("REDIS_PASSWORD")
 
 	// Provide Redis client
-	client, err := redis.RedisClient(redisHost, redisPort, redisUsername, redisPassword)
+	client, err := redis.RedisClient(redisHost, redisPort, redisUsername, redisPassword, clientTLSConfigFromEnv("REDIS_TLS"))
 	if err != nil {
 		log.Fatalf("Failed to initialize Redis client: %v", err)
 	}
@@ -91,3 +498,23 @@ func initRedis() redis.IRedisRepositories {
 
 	return redis_repo
 }
+
+// clientTLSConfigFromEnv builds the TLS configuration for connecting to Redis or Kafka
+// from <prefix>_* environment variables, nil unless <prefix>_ENABLED is set
+func clientTLSConfigFromEnv(prefix string) *tls.Config {
+	if !utils.GetEnvBool(prefix+"_ENABLED", false) {
+		return nil
+	}
+	tlsConfig, err := tlsutil.ClientConfig(&tlsutil.Config{
+		CertFile:           os.Getenv(prefix + "_CERT_FILE"),
+		KeyFile:            os.Getenv(prefix + "_KEY_FILE"),
+		CAFile:             os.Getenv(prefix + "_CA_FILE"),
+		ServerName:         os.Getenv(prefix + "_SERVER_NAME"),
+		InsecureSkipVerify: utils.GetEnvBool(prefix+"_INSECURE_SKIP_VERIFY", false),
+		ReloadInterval:     utils.GetEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second),
+	})
+	if err != nil {
+		log.Fatalf("Failed to load %s configuration: %v", prefix, err)
+	}
+	return tlsConfig
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// AuditEntry records who did what to whom, and from where
+type AuditEntry struct {
+	OccurredAt time.Time         `json:"occurred_at"`
+	Action     string            `json:"action"`
+	Actor      string            `json:"actor"`  // Service, service account or user that made the request
+	Target     string            `json:"target"` // User, chat, account or report acted on
+	SourceIP   string            `json:"source_ip,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+}
+
+// AuditRecord is an entry as appended to the log. Each record's hash covers the previous
+// record's, so editing or removing a record breaks the chain from there on.
+type AuditRecord struct {
+	Sequence int64 `json:"sequence"`
+	AuditEntry
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type Bot struct {
+	ID         string    `json:"id"` // Bots are addressed like any other user ID
+	Name       string    `json:"name"`
+	Transport  string    `json:"transport"` // webhook or stream
+	WebhookURL string    `json:"webhook_url,omitempty"`
+	Secret     string    `json:"secret"`
+	Commands   []string  `json:"commands"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BotInvocation is what a bot receives when it is addressed or one of its commands is used
+type BotInvocation struct {
+	InvocationID   string `json:"invocation_id"`
+	BotID          string `json:"bot_id"`
+	ChatID         string `json:"chat_id"`
+	SenderUserID   string `json:"sender_user_id"`
+	ReceiverUserID string `json:"receiver_user_id"` // Other participant of the chat, may be the bot itself
+	Command        string `json:"command,omitempty"`
+	Args           string `json:"args,omitempty"`
+	Message        string `json:"message"`
+	Tenant         string `json:"tenant,omitempty"` // Sender's tenant, replies are stored under it
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ChatEvent is a lifecycle event (delivery, read, presence) published next to chat
+// messages on a server's Kafka topic. Chat delivery ignores it, webhooks consume it.
+type ChatEvent struct {
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	UserID         string     `json:"user_id"`
+	ServerID       string     `json:"server_id,omitempty"`
+	ChatID         string     `json:"chat_id,omitempty"`
+	MessageEventID string     `json:"message_event_id,omitempty"`
+	Reason         string     `json:"reason,omitempty"`
+	ActorUserID    string     `json:"actor_user_id,omitempty"` // Receipts: the user who received or read the message
+	Status         string     `json:"status,omitempty"`        // Receipts: delivered or read. Moderation: the action
+	Until          *time.Time `json:"until,omitempty"`         // Moderation: when a mute ends
+	OccurredAt     time.Time  `json:"occurred_at"`
+}
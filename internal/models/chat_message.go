@@ -1,10 +1,49 @@
 package models
 
+import (
+	"distributed-chat-system/internal/constants"
+	"fmt"
+	"strings"
+)
+
+// directChatPrefix is the namespace of direct chats, whose IDs the server derives
+const directChatPrefix = "dm:"
+
+var directChatEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
 type ChatMessage struct {
 	EventID        string `json:"event_id"`
+	EventType      string `json:"event_type,omitempty"` // Empty on payloads from older servers, treated as message.sent
 	ChatID         string `json:"chat_id"`
 	SenderUserID   string `json:"sender_user_id"`
 	ReceiverUserID string `json:"receiver_user_id"`
 	MessageType    string `json:"message_type"`
 	Message        string `json:"message"`
+	Sequence       int64  `json:"sequence,omitempty"`         // Position in the receiver's inbox, used to resume
+	Tenant         string `json:"tenant,omitempty"`           // Sender's tenant, whose data key encrypts the stored copies
+	GroupMessageID string `json:"group_message_id,omitempty"` // Shared by the copies of one message fanned out to several receivers
+}
+
+// String keeps end-to-end encrypted payloads out of logs
+func (m ChatMessage) String() string {
+	message := m.Message
+	if m.MessageType == constants.MessageTypeCiphertext {
+		message = fmt.Sprintf("<ciphertext, %d bytes>", len(message))
+	}
+	return fmt.Sprintf("{EventID:%s EventType:%s ChatID:%s SenderUserID:%s ReceiverUserID:%s MessageType:%s Message:%s Sequence:%d}",
+		m.EventID, m.EventType, m.ChatID, m.SenderUserID, m.ReceiverUserID, m.MessageType, message, m.Sequence)
+}
+
+// DirectChatID is the ID of the direct chat between two users: "dm:" followed by both
+// IDs in order, with '%' and ':' percent-encoded so that no other pair has the same ID
+func DirectChatID(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return directChatPrefix + directChatEscaper.Replace(a) + ":" + directChatEscaper.Replace(b)
+}
+
+// IsDirectChat reports whether a chat ID is in the direct chat namespace
+func IsDirectChat(chatID string) bool {
+	return strings.HasPrefix(chatID, directChatPrefix)
 }
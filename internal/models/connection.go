@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ConnectionInfo describes one live socket on a chat server
+type ConnectionInfo struct {
+	UserID           string    `json:"user_id"`
+	ServerID         string    `json:"server_id"`
+	RemoteAddr       string    `json:"remote_addr"`
+	Subprotocol      string    `json:"subprotocol"`
+	IsBot            bool      `json:"is_bot"`
+	ConnectedAt      time.Time `json:"connected_at"`
+	MessagesReceived int64     `json:"messages_received"` // Frames read from the client
+	MessagesSent     int64     `json:"messages_sent"`     // Messages delivered to the client
+}
+
+// ServerConnections is the snapshot each server periodically publishes of its sockets
+type ServerConnections struct {
+	ServerID    string           `json:"server_id"`
+	ReportedAt  time.Time        `json:"reported_at"`
+	Connections []ConnectionInfo `json:"connections"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// DigestPreferences are a user's email digest settings
+type DigestPreferences struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	OptOut    bool      `json:"opt_out"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DigestState tracks what the last digest of a user covered
+type DigestState struct {
+	LastSequence int64     `json:"last_sequence"` // Highest inbox sequence included in a digest
+	LastSentAt   time.Time `json:"last_sent_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// SealedData is a value encrypted at rest with a version of its tenant's data key
+type SealedData struct {
+	Tenant     string `json:"tenant"`
+	Generation string `json:"generation,omitempty"` // Key ring it was sealed with, versions restart with every ring
+	KeyVersion int    `json:"key_version"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// DataKey is one version of a tenant's data key, as wrapped by a KMS master key
+type DataKey struct {
+	Version     int       `json:"version"`
+	MasterKeyID string    `json:"master_key_id"`
+	WrappedKey  []byte    `json:"wrapped_key"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TenantKeyRing holds the data key versions that can still open a tenant's data.
+// New data is sealed with the active version.
+type TenantKeyRing struct {
+	Tenant        string           `json:"tenant"`
+	Generation    string           `json:"generation,omitempty"` // New for every ring created after a shred
+	ActiveVersion int              `json:"active_version"`
+	Keys          map[int]*DataKey `json:"keys"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// ReencryptionJob tracks moving a tenant's stored data to its active data key
+type ReencryptionJob struct {
+	Tenant      string     `json:"tenant"`
+	Status      string     `json:"status"` // running, completed or failed
+	KeyVersion  int        `json:"key_version"`
+	Scanned     int        `json:"scanned"`
+	Resealed    int        `json:"resealed"`
+	Retired     []int      `json:"retired,omitempty"` // Key versions dropped once nothing used them
+	Error       string     `json:"error,omitempty"`
+	Actor       string     `json:"actor,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
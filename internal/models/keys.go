@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// PreKey is a public prekey of a device. Keys are base64 and opaque to the server.
+type PreKey struct {
+	KeyID     int64  `json:"key_id"`
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature,omitempty"` // Signed prekeys only, made with the identity key
+}
+
+// DeviceKeys is what a device publishes to the key directory
+type DeviceKeys struct {
+	UserID         string    `json:"user_id"`
+	DeviceID       string    `json:"device_id"`
+	IdentityKey    string    `json:"identity_key"`
+	SignedPreKey   PreKey    `json:"signed_pre_key"`
+	OneTimePreKeys int64     `json:"one_time_pre_keys"` // One-time prekeys left, devices should upload more when low
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// PreKeyBundle is what a peer fetches to start an encrypted session with a device.
+// Every fetch consumes a one-time prekey; without any left the bundle has none.
+type PreKeyBundle struct {
+	UserID        string  `json:"user_id"`
+	DeviceID      string  `json:"device_id"`
+	IdentityKey   string  `json:"identity_key"`
+	SignedPreKey  PreKey  `json:"signed_pre_key"`
+	OneTimePreKey *PreKey `json:"one_time_pre_key,omitempty"`
+}
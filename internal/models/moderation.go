@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// ModerationRule configures one filter of a moderation pipeline
+type ModerationRule struct {
+	Name     string   `json:"name,omitempty"`     // Label recorded with hits
+	Filter   string   `json:"filter"`             // profanity, link, regex or a registered custom filter
+	Action   string   `json:"action"`             // redact, flag or reject
+	Words    []string `json:"words,omitempty"`    // profanity: blocked words
+	Domains  []string `json:"domains,omitempty"`  // link: blocked domains, subdomains included
+	Patterns []string `json:"patterns,omitempty"` // regex: Go regular expressions
+}
+
+// ModerationRules is the ordered pipeline of a tenant or chat
+type ModerationRules struct {
+	Scope            string           `json:"scope"` // tenant or chat
+	ID               string           `json:"id"`
+	Rules            []ModerationRule `json:"rules"`
+	RejectCiphertext bool             `json:"reject_ciphertext,omitempty"` // Refuse messages the filters can't read
+	UpdatedAt        time.Time        `json:"updated_at"`
+}
+
+// ModerationHit records a message that matched a rule. Matched text isn't kept, so
+// hits of PII filters don't store the PII.
+type ModerationHit struct {
+	ID             string    `json:"id"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	Scope          string    `json:"scope"` // Rules that matched: tenant or chat
+	ScopeID        string    `json:"scope_id"`
+	Rule           string    `json:"rule"`
+	Filter         string    `json:"filter"`
+	Action         string    `json:"action"`
+	Matches        int       `json:"matches"`
+	SenderUserID   string    `json:"sender_user_id"`
+	ChatID         string    `json:"chat_id"`
+	ReceiverUserID string    `json:"receiver_user_id,omitempty"`
+	Tenant         string    `json:"tenant,omitempty"`  // Sender's tenant
+	Message        string    `json:"message,omitempty"` // Flagged messages only, as delivered, for reviewers
+
+	SealedMessage *SealedData `json:"sealed_message,omitempty"` // Message as stored, encrypted
+}
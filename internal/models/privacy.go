@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PrivacyJob is a data export or erasure of one user, run in the background.
+// Progress advances one step at a time; Items counts what each step found or removed.
+type PrivacyJob struct {
+	ID               string         `json:"id"`
+	Kind             string         `json:"kind"` // export or erasure
+	UserID           string         `json:"user_id"`
+	Status           string         `json:"status"` // pending, running, completed or failed
+	Step             string         `json:"step,omitempty"`
+	StepsDone        int            `json:"steps_done"`
+	StepsTotal       int            `json:"steps_total"`
+	Items            map[string]int `json:"items"`
+	Error            string         `json:"error,omitempty"`
+	Actor            string         `json:"actor,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	CompletedAt      *time.Time     `json:"completed_at,omitempty"`
+	ArchiveExpiresAt *time.Time     `json:"archive_expires_at,omitempty"` // Exports only
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Device is a mobile device registered for push notifications
+type Device struct {
+	UserID       string    `json:"user_id"`
+	Token        string    `json:"token"`
+	Platform     string    `json:"platform"` // android or ios
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// NotificationSettings decide which offline messages produce a push
+type NotificationSettings struct {
+	UserID            string     `json:"user_id"`
+	MutedChats        []string   `json:"muted_chats"`
+	DoNotDisturb      bool       `json:"do_not_disturb"`                 // Suppresses every push until turned off
+	DoNotDisturbUntil *time.Time `json:"do_not_disturb_until,omitempty"` // Suppresses every push until this time
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// AbuseReport is a user's report of a message they received, or of another user
+type AbuseReport struct {
+	ID             string         `json:"id"`
+	ReporterUserID string         `json:"reporter_user_id"`
+	ReportedUserID string         `json:"reported_user_id"`
+	EventID        string         `json:"event_id,omitempty"`
+	ChatID         string         `json:"chat_id,omitempty"`
+	Message        *ChatMessage   `json:"message,omitempty"`        // The reported message as the reporter received it
+	SealedMessage  *SealedData    `json:"sealed_message,omitempty"` // Message as stored, encrypted
+	Reason         string         `json:"reason"`
+	Details        string         `json:"details,omitempty"`
+	Status         string         `json:"status"` // open, reviewing, actioned or dismissed
+	Note           string         `json:"note,omitempty"`
+	Actions        []ReportAction `json:"actions,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// ReportAction is an enforcement a moderator applied while handling a report
+type ReportAction struct {
+	Action    string     `json:"action"` // delete_message, warn, mute or ban
+	Reason    string     `json:"reason,omitempty"`
+	Until     *time.Time `json:"until,omitempty"` // Mutes and bans, nil when permanent
+	Actor     string     `json:"actor,omitempty"` // Service or service account that applied it, "anonymous" without one
+	AppliedAt time.Time  `json:"applied_at"`
+}
+
+// Sanction is an active mute or ban of a user
+type Sanction struct {
+	UserID    string     `json:"user_id"`
+	Kind      string     `json:"kind"` // mute or ban
+	Reason    string     `json:"reason,omitempty"`
+	ReportID  string     `json:"report_id,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ServiceAccount is a non-human identity for backend integrations. It sends messages
+// under its ID like any user and authenticates with API keys.
+type ServiceAccount struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`           // send, read_history and/or admin
+	Tenant    string    `json:"tenant,omitempty"` // Selects moderation rules for its messages
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey describes a key of a service account. The secret is only shown when it is created.
+type APIKey struct {
+	ID         string     `json:"id"`
+	AccountID  string     `json:"account_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // Set on keys replaced by a rotation
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"` // Empty means every event
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookPayload is the signed JSON body POSTed to subscribers
+type WebhookPayload struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookDeadLetter records a delivery that exhausted its retries
+type WebhookDeadLetter struct {
+	SubscriptionID string         `json:"subscription_id"`
+	URL            string         `json:"url"`
+	Payload        WebhookPayload `json:"payload"`
+	Attempts       int            `json:"attempts"`
+	LastError      string         `json:"last_error"`
+	FailedAt       time.Time      `json:"failed_at"`
+
+	SealedMessage *SealedData `json:"sealed_message,omitempty"` // Text of a message.sent payload as stored, encrypted
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/redis"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	auditLogKey      = "audit:log" // Sorted set of records scored by sequence
+	auditSequenceKey = "audit:sequence"
+	auditHeadKey     = "audit:head"     // Hash of the last record
+	auditHeadMACKey  = "audit:head_mac" // HMAC over the sequence and the head
+
+	auditPageSize         = 500
+	auditAppendAttempts   = 10
+	minAuditHMACKeyLength = 32
+)
+
+var errAuditContention = errors.New("audit log head kept moving")
+
+// auditHeadScript reads the sequence in KEYS[1] and the hash in KEYS[2] of the last record,
+// and their HMAC in KEYS[3]
+const auditHeadScript = `
+return {redis.call('GET', KEYS[1]) or '0', redis.call('GET', KEYS[2]) or '', redis.call('GET', KEYS[3]) or ''}
+`
+
+// appendAuditScript appends the record in ARGV[3] with the hash in ARGV[4] to the log in
+// KEYS[1], provided the sequence in KEYS[2] and the head in KEYS[3] are still ARGV[1] and
+// ARGV[2], and stores the new head's HMAC in ARGV[5] to KEYS[4]. Returns 0 when another
+// server appended first, so servers appending concurrently keep the chain intact.
+const appendAuditScript = `
+if (redis.call('GET', KEYS[2]) or '0') ~= ARGV[1] or (redis.call('GET', KEYS[3]) or '') ~= ARGV[2] then
+	return 0
+end
+local sequence = tonumber(ARGV[1]) + 1
+redis.call('ZADD', KEYS[1], sequence, ARGV[3])
+redis.call('SET', KEYS[2], sequence)
+redis.call('SET', KEYS[3], ARGV[4])
+redis.call('SET', KEYS[4], ARGV[5])
+return sequence
+`
+
+type AuditConfig struct {
+	// HMAC-SHA256 key of the hash chain. It stays out of Redis, so whoever can write there
+	// can't recompute the chain after editing it.
+	HMACKey string
+}
+
+// AuditConfigFromEnv builds the audit configuration from AUDIT_* environment variables
+func AuditConfigFromEnv() *AuditConfig {
+	return &AuditConfig{
+		HMACKey: os.Getenv("AUDIT_HMAC_KEY"),
+	}
+}
+
+// storedAuditRecord is a record as the append script writes it, with the entry kept as
+// the exact bytes that were hashed
+type storedAuditRecord struct {
+	Sequence int64           `json:"sequence"`
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash"`
+	Entry    json.RawMessage `json:"entry"`
+}
+
+// AuditFilter selects audit records; empty fields match everything
+type AuditFilter struct {
+	Action string
+	Actor  string
+	Target string
+	Since  time.Time
+	Until  time.Time
+	After  int64 // Only records with a higher sequence, to page through results
+	Limit  int   // At most this many records, 0 for all
+}
+
+func (f AuditFilter) matches(record models.AuditRecord) bool {
+	switch {
+	case f.Action != "" && record.Action != f.Action,
+		f.Actor != "" && record.Actor != f.Actor,
+		f.Target != "" && record.Target != f.Target,
+		!f.Since.IsZero() && record.OccurredAt.Before(f.Since),
+		!f.Until.IsZero() && record.OccurredAt.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// AuditService keeps the audit trail of administrative and security-relevant actions.
+// The log is only ever appended to; records are hash-chained so tampering is detectable.
+type AuditService struct {
+	redisRepo redis.IRedisRepositories
+	key       []byte
+}
+
+func NewAuditService(redisRepo redis.IRedisRepositories, config *AuditConfig) (*AuditService, error) {
+	if len(config.HMACKey) < minAuditHMACKeyLength {
+		return nil, fmt.Errorf("AUDIT_HMAC_KEY must be at least %d characters", minAuditHMACKeyLength)
+	}
+	return &AuditService{redisRepo: redisRepo, key: []byte(config.HMACKey)}, nil
+}
+
+// Record appends an entry. A failure is logged with the entry, so the action is still
+// traceable, and does not undo the action.
+func (s *AuditService) Record(entry models.AuditEntry) {
+	if entry.OccurredAt.IsZero() {
+		entry.OccurredAt = time.Now().UTC()
+	}
+	entryJson, err := json.Marshal(entry)
+	if err != nil {
+		log.Println("Error encoding audit entry:", err)
+		return
+	}
+	if err := s.append(entryJson); err != nil {
+		log.Printf("Error appending audit entry %s: %v", entryJson, err)
+	}
+}
+
+// append chains an entry to the current head, starting over when another server
+// appended in between
+func (s *AuditService) append(entryJson []byte) error {
+	keys := []string{auditLogKey, auditSequenceKey, auditHeadKey, auditHeadMACKey}
+	for attempt := 0; attempt < auditAppendAttempts; attempt++ {
+		sequence, head, _, err := s.head()
+		if err != nil {
+			return err
+		}
+		stored := storedAuditRecord{Sequence: sequence + 1, PrevHash: head, Entry: entryJson}
+		stored.Hash = s.mac(stored)
+		recordJson, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+
+		args := []interface{}{strconv.FormatInt(sequence, 10), head, string(recordJson), stored.Hash, s.headMAC(stored.Sequence, stored.Hash)}
+		appended, err := s.redisRepo.Eval(appendAuditScript, keys, args, context.Background())
+		if err != nil {
+			return err
+		}
+		if n, _ := appended.(int64); n != 0 {
+			return nil
+		}
+	}
+	return errAuditContention
+}
+
+// head returns the sequence and hash of the last record appended, and whether their
+// HMAC matches. An empty log has no HMAC.
+func (s *AuditService) head() (int64, string, bool, error) {
+	result, err := s.redisRepo.Eval(auditHeadScript, []string{auditSequenceKey, auditHeadKey, auditHeadMACKey}, nil, context.Background())
+	if err != nil {
+		return 0, "", false, err
+	}
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 3 {
+		return 0, "", false, fmt.Errorf("unexpected audit head %v", result)
+	}
+	sequenceValue, _ := values[0].(string)
+	head, _ := values[1].(string)
+	headMAC, _ := values[2].(string)
+	sequence, err := strconv.ParseInt(sequenceValue, 10, 64)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("corrupt audit sequence %q: %w", sequenceValue, err)
+	}
+	valid := hmac.Equal([]byte(headMAC), []byte(s.headMAC(sequence, head))) || sequence == 0 && head == "" && headMAC == ""
+	return sequence, head, valid, nil
+}
+
+// Query returns the records matching the filter, oldest first
+func (s *AuditService) Query(filter AuditFilter) ([]models.AuditRecord, error) {
+	records := make([]models.AuditRecord, 0)
+	err := s.scan(filter.After, func(record models.AuditRecord, _ storedAuditRecord) bool {
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+		return filter.Limit <= 0 || len(records) < filter.Limit
+	})
+	return records, err
+}
+
+// Export passes every record matching the filter to write, oldest first, without
+// holding the whole log in memory
+func (s *AuditService) Export(filter AuditFilter, write func(record models.AuditRecord) error) error {
+	var writeErr error
+	exported := 0
+	err := s.scan(filter.After, func(record models.AuditRecord, _ storedAuditRecord) bool {
+		if !filter.matches(record) {
+			return true
+		}
+		if writeErr = write(record); writeErr != nil {
+			return false
+		}
+		exported++
+		return filter.Limit <= 0 || exported < filter.Limit
+	})
+	if err != nil {
+		return err
+	}
+	return writeErr
+}
+
+// Verify walks the whole chain and returns how many records it checked, and the
+// sequence of the first record that was altered, or follows a removed one. 0 means intact.
+// The chain has to reach the head recorded when Verify started, whose HMAC must match,
+// so records cut off the end are caught too. Records appended since are not checked.
+// A head put back from an earlier copy of Redis can't be told apart from the real one.
+func (s *AuditService) Verify() (int64, int64, error) {
+	headSequence, head, valid, err := s.head()
+	if err != nil {
+		return 0, 0, err
+	}
+	if !valid {
+		// The chain is still walked to report the first broken record, if any
+		headSequence = math.MaxInt64
+	}
+
+	var checked, brokenAt int64
+	var prev storedAuditRecord
+	err = s.scan(0, func(record models.AuditRecord, stored storedAuditRecord) bool {
+		if record.Sequence > headSequence {
+			return false
+		}
+		checked++
+		if stored.Sequence != record.Sequence || stored.Sequence != prev.Sequence+1 || stored.PrevHash != prev.Hash ||
+			!hmac.Equal([]byte(stored.Hash), []byte(s.mac(stored))) || stored.Sequence == headSequence && stored.Hash != head {
+			brokenAt = record.Sequence
+			return false
+		}
+		prev = stored
+		return true
+	})
+	if err == nil && brokenAt == 0 && (!valid || prev.Sequence < headSequence) {
+		brokenAt = prev.Sequence + 1
+	}
+	return checked, brokenAt, err
+}
+
+// scan visits records after a sequence in order, a page at a time, until visit returns false
+func (s *AuditService) scan(after int64, visit func(record models.AuditRecord, stored storedAuditRecord) bool) error {
+	for {
+		members, err := s.redisRepo.ZRangeByScoreWithScores(auditLogKey, "("+strconv.FormatInt(after, 10), "+inf", 0, auditPageSize, context.Background())
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			// Paged by score, which only the append script sets, so an edited record can't
+			// send the scan back
+			after = int64(member.Score)
+			var stored storedAuditRecord
+			if err := json.Unmarshal([]byte(member.Member), &stored); err != nil {
+				return fmt.Errorf("corrupt audit record %d: %w", after, err)
+			}
+
+			record := models.AuditRecord{Sequence: after, PrevHash: stored.PrevHash, Hash: stored.Hash}
+			if err := json.Unmarshal(stored.Entry, &record.AuditEntry); err != nil {
+				return fmt.Errorf("corrupt audit record %d: %w", after, err)
+			}
+			if !visit(record, stored) {
+				return nil
+			}
+		}
+		if len(members) < auditPageSize {
+			return nil
+		}
+	}
+}
+
+// mac computes a record's hash, an HMAC over the previous hash, the sequence and the entry
+func (s *AuditService) mac(stored storedAuditRecord) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(stored.PrevHash + "\n" + strconv.FormatInt(stored.Sequence, 10) + "\n" + string(stored.Entry)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// headMAC authenticates the head, so it can't be moved back to an earlier record
+func (s *AuditService) headMAC(sequence int64, head string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte("head\n" + strconv.FormatInt(sequence, 10) + "\n" + head))
+	return hex.EncodeToString(mac.Sum(nil))
+}
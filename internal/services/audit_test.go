@@ -0,0 +1,142 @@
+package services
+
+import (
+	"distributed-chat-system/internal/models"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// editAuditRecord rewrites the stored record with a sequence in place, keeping its score
+func editAuditRecord(t *testing.T, mini *miniredis.Miniredis, sequence int, edit func(stored *storedAuditRecord)) {
+	t.Helper()
+	member := auditMember(t, mini, sequence)
+	var stored storedAuditRecord
+	if err := json.Unmarshal([]byte(member), &stored); err != nil {
+		t.Fatal(err)
+	}
+	edit(&stored)
+	edited, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mini.ZRem(auditLogKey, member)
+	mini.ZAdd(auditLogKey, float64(sequence), string(edited))
+}
+
+func auditMember(t *testing.T, mini *miniredis.Miniredis, sequence int) string {
+	t.Helper()
+	members, err := mini.ZMembers(auditLogKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range members {
+		if score, _ := mini.ZScore(auditLogKey, member); int(score) == sequence {
+			return member
+		}
+	}
+	t.Fatalf("no audit record %d", sequence)
+	return ""
+}
+
+func TestAuditVerify(t *testing.T) {
+	tests := []struct {
+		name         string
+		tamper       func(t *testing.T, mini *miniredis.Miniredis)
+		verifyKey    string // Key Verify runs with, the writer's when empty
+		wantChecked  int64
+		wantBrokenAt int64
+	}{
+		{name: "intact", tamper: func(*testing.T, *miniredis.Miniredis) {}, wantChecked: 3},
+		{
+			name: "tampered record",
+			tamper: func(t *testing.T, mini *miniredis.Miniredis) {
+				editAuditRecord(t, mini, 2, func(stored *storedAuditRecord) {
+					stored.Entry = json.RawMessage(`{"action":"user.disconnect","actor":"mallory"}`)
+				})
+			},
+			wantChecked:  2,
+			wantBrokenAt: 2,
+		},
+		{
+			name:         "removed record",
+			tamper:       func(t *testing.T, mini *miniredis.Miniredis) { mini.ZRem(auditLogKey, auditMember(t, mini, 2)) },
+			wantChecked:  2,
+			wantBrokenAt: 3,
+		},
+		{
+			name:         "truncated tail",
+			tamper:       func(t *testing.T, mini *miniredis.Miniredis) { mini.ZRem(auditLogKey, auditMember(t, mini, 3)) },
+			wantChecked:  2,
+			wantBrokenAt: 3,
+		},
+		{
+			name: "truncated tail with the head moved back",
+			tamper: func(t *testing.T, mini *miniredis.Miniredis) {
+				var stored storedAuditRecord
+				json.Unmarshal([]byte(auditMember(t, mini, 2)), &stored)
+				mini.ZRem(auditLogKey, auditMember(t, mini, 3))
+				mini.Set(auditSequenceKey, "2")
+				mini.Set(auditHeadKey, stored.Hash)
+			},
+			wantChecked:  2,
+			wantBrokenAt: 3,
+		},
+		{
+			name: "sequence rewritten to loop the scan",
+			tamper: func(t *testing.T, mini *miniredis.Miniredis) {
+				editAuditRecord(t, mini, 3, func(stored *storedAuditRecord) { stored.Sequence = 1 })
+			},
+			wantChecked:  3,
+			wantBrokenAt: 3,
+		},
+		{
+			name:         "other key",
+			tamper:       func(*testing.T, *miniredis.Miniredis) {},
+			verifyKey:    strings.Repeat("z", minAuditHMACKeyLength),
+			wantChecked:  1,
+			wantBrokenAt: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mini, redisRepo := testRedis(t)
+			key := strings.Repeat("k", minAuditHMACKeyLength)
+			audit, err := NewAuditService(redisRepo, &AuditConfig{HMACKey: key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, action := range []string{"user.disconnect", "sanction.lift", "user.erase"} {
+				audit.Record(models.AuditEntry{Action: action, Actor: "admin", Target: "alice"})
+			}
+
+			test.tamper(t, mini)
+			if test.verifyKey != "" {
+				if audit, err = NewAuditService(redisRepo, &AuditConfig{HMACKey: test.verifyKey}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			checked, brokenAt, err := audit.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if checked != test.wantChecked || brokenAt != test.wantBrokenAt {
+				t.Errorf("Verify() = %d checked, broken at %d, want %d, %d", checked, brokenAt, test.wantChecked, test.wantBrokenAt)
+			}
+			if _, err := audit.Query(AuditFilter{}); err != nil {
+				t.Errorf("Query() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestNewAuditServiceShortKey(t *testing.T) {
+	_, redisRepo := testRedis(t)
+	if _, err := NewAuditService(redisRepo, &AuditConfig{HMACKey: "short"}); err == nil {
+		t.Error("NewAuditService() accepted a short key")
+	}
+}
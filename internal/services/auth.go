@@ -0,0 +1,184 @@
+package services
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"distributed-chat-system/internal/utils"
+	"distributed-chat-system/pkg/jwt"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingToken  = errors.New("authentication token is required")
+	ErrTokenMismatch = errors.New("token does not belong to this user")
+	ErrReservedUser  = errors.New("token subject is reserved for service accounts")
+)
+
+// minBootstrapKeyLength keeps guessable bootstrap keys out
+const minBootstrapKeyLength = 32
+
+type AuthConfig struct {
+	Disabled      bool   // Trusts the user id of the request, for local development only
+	Secret        string // HS256 shared secret
+	PublicKeyFile string // PEM RSA public key for RS256 tokens of an external identity provider
+	Issuer        string
+	Audience      string
+	Leeway        time.Duration
+	// Client certificate subjects (URI SAN, common name or full subject) mapped to service
+	// names. Empty accepts every verified certificate as the service named by its common name.
+	ServiceIdentities    map[string]string
+	AdminRequiresService bool // Only services with a client certificate may call /admin
+	// Admits /admin callers presenting it as X-API-Key, to create the first admin service
+	// account. Meant to be unset once one exists.
+	AdminBootstrapKey string
+}
+
+// AuthConfigFromEnv builds the authentication configuration from AUTH_*, JWT_* and service identity environment variables
+func AuthConfigFromEnv() *AuthConfig {
+	return &AuthConfig{
+		Disabled:      utils.GetEnvBool("AUTH_DISABLED", false),
+		Secret:        os.Getenv("JWT_SECRET"),
+		PublicKeyFile: os.Getenv("JWT_PUBLIC_KEY_FILE"),
+		Issuer:        os.Getenv("JWT_ISSUER"),
+		Audience:      os.Getenv("JWT_AUDIENCE"),
+		Leeway:        utils.GetEnvDuration("JWT_LEEWAY", 30*time.Second),
+
+		ServiceIdentities:    parseServiceIdentities(os.Getenv("TLS_SERVICE_IDENTITIES")),
+		AdminRequiresService: utils.GetEnvBool("ADMIN_REQUIRE_SERVICE", false),
+		AdminBootstrapKey:    os.Getenv("ADMIN_BOOTSTRAP_KEY"),
+	}
+}
+
+// parseServiceIdentities reads "subject=service;subject=service". Subjects may contain
+// "=" themselves, as in "CN=billing,O=Acme=billing", so entries split at the last one.
+func parseServiceIdentities(value string) map[string]string {
+	identities := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		separator := strings.LastIndex(entry, "=")
+		if separator <= 0 || separator == len(entry)-1 {
+			if entry != "" {
+				log.Printf("Ignoring malformed service identity %q", entry)
+			}
+			continue
+		}
+		identities[entry[:separator]] = entry[separator+1:]
+	}
+	return identities
+}
+
+// AuthService verifies the signed tokens clients present when connecting
+type AuthService struct {
+	config   *AuthConfig
+	verifier *jwt.Verifier
+}
+
+func NewAuthService(config *AuthConfig) (*AuthService, error) {
+	if config.AdminBootstrapKey != "" {
+		if len(config.AdminBootstrapKey) < minBootstrapKeyLength {
+			return nil, fmt.Errorf("ADMIN_BOOTSTRAP_KEY must be at least %d characters", minBootstrapKeyLength)
+		}
+		log.Println("WARNING: ADMIN_BOOTSTRAP_KEY is set, unset it once an admin service account exists")
+	}
+	if config.Disabled {
+		log.Println("WARNING: authentication is disabled, clients may connect as any user")
+		return &AuthService{config: config}, nil
+	}
+
+	verifierConfig := &jwt.VerifierConfig{
+		Secret:   []byte(config.Secret),
+		Issuer:   config.Issuer,
+		Audience: config.Audience,
+		Leeway:   config.Leeway,
+	}
+	if config.PublicKeyFile != "" {
+		keyData, err := os.ReadFile(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if verifierConfig.PublicKey, err = jwt.ParseRSAPublicKey(keyData); err != nil {
+			return nil, err
+		}
+	}
+	if len(verifierConfig.Secret) == 0 && verifierConfig.PublicKey == nil {
+		return nil, errors.New("set JWT_SECRET or JWT_PUBLIC_KEY_FILE, or AUTH_DISABLED=true for development")
+	}
+
+	return &AuthService{
+		config:   config,
+		verifier: jwt.NewVerifier(verifierConfig),
+	}, nil
+}
+
+// Enabled reports whether clients have to present a token
+func (s *AuthService) Enabled() bool {
+	return !s.config.Disabled
+}
+
+// Authenticate verifies a token and returns its claims; the subject is the user id.
+// Subjects naming a service account are refused so that users can't pass for one.
+func (s *AuthService) Authenticate(token string) (*jwt.Claims, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	claims, err := s.verifier.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if IsServiceAccountActor(claims.Subject) {
+		return nil, ErrReservedUser
+	}
+	return claims, nil
+}
+
+// AuthenticateUser verifies a token and checks that it was issued to the given user
+func (s *AuthService) AuthenticateUser(token, userID string) (*jwt.Claims, error) {
+	claims, err := s.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != userID {
+		return nil, ErrTokenMismatch
+	}
+	return claims, nil
+}
+
+// ServiceIdentity maps a verified client certificate to the calling service
+func (s *AuthService) ServiceIdentity(certificate *x509.Certificate) (string, bool) {
+	if len(s.config.ServiceIdentities) == 0 {
+		if certificate.Subject.CommonName != "" {
+			return certificate.Subject.CommonName, true
+		}
+		if len(certificate.URIs) > 0 {
+			return certificate.URIs[0].String(), true
+		}
+		return "", false
+	}
+
+	var subjects []string
+	for _, uri := range certificate.URIs {
+		subjects = append(subjects, uri.String())
+	}
+	subjects = append(subjects, certificate.Subject.CommonName, certificate.Subject.String())
+	for _, subject := range subjects {
+		if service, ok := s.config.ServiceIdentities[subject]; ok && subject != "" {
+			return service, true
+		}
+	}
+	return "", false
+}
+
+// BootstrapAdmin reports whether a key is the configured admin bootstrap key
+func (s *AuthService) BootstrapAdmin(key string) bool {
+	return s.config.AdminBootstrapKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.config.AdminBootstrapKey)) == 1
+}
+
+// AdminRequiresService reports whether admin routes are reserved for services
+func (s *AuthService) AdminRequiresService() bool {
+	return s.config.AdminRequiresService
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/pkg/redis"
+	"log"
+)
+
+const (
+	blockListPrefix = "blocks:"
+	blockedByPrefix = "blocked-by:" // Users who blocked a user, so erasure finds their lists
+)
+
+// BlockService keeps per-user lists of users they don't want messages from
+type BlockService struct {
+	redisRepo redis.IRedisRepositories
+}
+
+func NewBlockService(redisRepo redis.IRedisRepositories) *BlockService {
+	return &BlockService{redisRepo: redisRepo}
+}
+
+func (s *BlockService) Block(userID, blockedUserID string) error {
+	ctx := context.Background()
+	if err := s.redisRepo.SAdd(blockListPrefix+userID, blockedUserID, ctx); err != nil {
+		return err
+	}
+	if err := s.redisRepo.SAdd(blockedByPrefix+blockedUserID, userID, ctx); err != nil {
+		return err
+	}
+	log.Printf("User %s blocked user %s", userID, blockedUserID)
+	return nil
+}
+
+func (s *BlockService) Unblock(userID, blockedUserID string) error {
+	ctx := context.Background()
+	if err := s.redisRepo.SRem(blockListPrefix+userID, blockedUserID, ctx); err != nil {
+		return err
+	}
+	if err := s.redisRepo.SRem(blockedByPrefix+blockedUserID, userID, ctx); err != nil {
+		return err
+	}
+	log.Printf("User %s unblocked user %s", userID, blockedUserID)
+	return nil
+}
+
+// Blocked lists the users blocked by a user
+func (s *BlockService) Blocked(userID string) ([]string, error) {
+	return s.redisRepo.SMembers(blockListPrefix+userID, context.Background())
+}
+
+// IsBlocked reports whether userID has blocked otherUserID
+func (s *BlockService) IsBlocked(userID, otherUserID string) (bool, error) {
+	return s.redisRepo.SIsMember(blockListPrefix+userID, otherUserID, context.Background())
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/redis"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	botPrefix           = "bot:"
+	botCommandPrefix    = "bot:command:"
+	botInvocationPrefix = "bot:invocation:"
+	botInvocationTTL    = 10 * time.Minute
+)
+
+var (
+	ErrBotNotFound       = errors.New("bot not found")
+	ErrBotInvocationGone = errors.New("bot invocation not found or expired")
+	ErrCiphertextToBot   = errors.New("bots can't receive encrypted messages")
+	commandNamePattern   = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+	// Bot IDs follow the command rules; without a ':' they can't name bot:command: or
+	// bot:invocation: keys
+	botIDPattern          = commandNamePattern
+	botWebhookHTTPTimeout = 10 * time.Second
+)
+
+// BotCommand is a parsed "/command args" message
+type BotCommand struct {
+	Name string
+	Args string
+}
+
+// ParseCommand extracts a slash command from a message body
+func ParseCommand(message string) (*BotCommand, bool) {
+	if !strings.HasPrefix(message, "/") {
+		return nil, false
+	}
+
+	name, args, _ := strings.Cut(strings.TrimPrefix(message, "/"), " ")
+	name = strings.ToLower(name)
+	if !commandNamePattern.MatchString(name) {
+		return nil, false
+	}
+	return &BotCommand{Name: name, Args: strings.TrimSpace(args)}, true
+}
+
+// BotService keeps the bot registry and talks to webhook bots
+type BotService struct {
+	redisRepo  redis.IRedisRepositories
+	httpClient *http.Client
+}
+
+func NewBotService(redisRepo redis.IRedisRepositories) *BotService {
+	return &BotService{
+		redisRepo:  redisRepo,
+		httpClient: &http.Client{Timeout: botWebhookHTTPTimeout},
+	}
+}
+
+// RegisterBot creates a bot account and claims its commands. The returned bot carries
+// the generated secret, which is used for stream authentication and webhook signatures.
+func (s *BotService) RegisterBot(request dtos.CreateBotDto) (*models.Bot, error) {
+	ctx := context.Background()
+	if !botIDPattern.MatchString(request.ID) {
+		return nil, fmt.Errorf("invalid bot ID: %s", request.ID)
+	}
+	if _, err := s.redisRepo.Get(botPrefix+request.ID, ctx); err == nil {
+		return nil, fmt.Errorf("bot %s already exists", request.ID)
+	}
+
+	commands := make([]string, 0, len(request.Commands))
+	for _, command := range request.Commands {
+		command = strings.ToLower(strings.TrimPrefix(command, "/"))
+		if !commandNamePattern.MatchString(command) {
+			return nil, fmt.Errorf("invalid command name: %s", command)
+		}
+		if owner := s.commandOwner(command); owner != "" {
+			return nil, fmt.Errorf("command /%s is already owned by bot %s", command, owner)
+		}
+		commands = append(commands, command)
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	bot := &models.Bot{
+		ID:         request.ID,
+		Name:       request.Name,
+		Transport:  request.Transport,
+		WebhookURL: request.WebhookURL,
+		Secret:     secret,
+		Commands:   commands,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	botJson, err := json.Marshal(bot)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.Set(botPrefix+bot.ID, botJson, 0, ctx); err != nil {
+		return nil, err
+	}
+	for _, command := range commands {
+		s.redisRepo.Set(botCommandPrefix+command, []byte(bot.ID), 0, ctx)
+	}
+
+	log.Printf("Bot registered: %s (commands: %v)", bot.ID, commands)
+	return bot, nil
+}
+
+// ListBots returns every registered bot
+func (s *BotService) ListBots() ([]models.Bot, error) {
+	keys, err := s.redisRepo.Keys(botPrefix+"*", context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	bots := make([]models.Bot, 0, len(keys))
+	for _, key := range keys {
+		// Command index and invocation keys share the prefix
+		if strings.HasPrefix(key, botCommandPrefix) || strings.HasPrefix(key, botInvocationPrefix) {
+			continue
+		}
+		if bot := s.LookupBot(strings.TrimPrefix(key, botPrefix)); bot != nil {
+			bots = append(bots, *bot)
+		}
+	}
+	return bots, nil
+}
+
+// DeleteBot removes a bot and releases its commands
+func (s *BotService) DeleteBot(botID string) error {
+	bot := s.LookupBot(botID)
+	if bot == nil {
+		return ErrBotNotFound
+	}
+
+	ctx := context.Background()
+	for _, command := range bot.Commands {
+		s.redisRepo.Del(botCommandPrefix+command, ctx)
+	}
+	if err := s.redisRepo.Del(botPrefix+botID, ctx); err != nil {
+		return err
+	}
+	log.Println("Bot deleted: ", botID)
+	return nil
+}
+
+// LookupBot returns the bot registered under a user ID, or nil for regular users
+func (s *BotService) LookupBot(userID string) *models.Bot {
+	if !botIDPattern.MatchString(userID) {
+		return nil
+	}
+	data, err := s.redisRepo.Get(botPrefix+userID, context.Background())
+	if err != nil {
+		return nil
+	}
+
+	var bot models.Bot
+	if err := json.Unmarshal([]byte(data), &bot); err != nil {
+		log.Printf("Malformed bot record %s: %v", userID, err)
+		return nil
+	}
+	return &bot
+}
+
+// LookupCommand returns the bot owning a slash command, or nil when nobody claimed it
+func (s *BotService) LookupCommand(command string) *models.Bot {
+	owner := s.commandOwner(command)
+	if owner == "" {
+		return nil
+	}
+	return s.LookupBot(owner)
+}
+
+// AuthenticateBot checks the secret a stream bot presents when connecting
+func (s *BotService) AuthenticateBot(botID, secret string) (*models.Bot, bool) {
+	bot := s.LookupBot(botID)
+	if bot == nil || secret == "" {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(bot.Secret), []byte(secret)) != 1 {
+		return nil, false
+	}
+	return bot, true
+}
+
+// SaveInvocation remembers an invocation handed to a stream bot so its reply can be routed back
+func (s *BotService) SaveInvocation(invocation models.BotInvocation) error {
+	data, err := json.Marshal(invocation)
+	if err != nil {
+		return err
+	}
+	return s.redisRepo.Set(botInvocationPrefix+invocation.InvocationID, data, botInvocationTTL, context.Background())
+}
+
+// LookupInvocation fetches an outstanding invocation addressed to the given bot
+func (s *BotService) LookupInvocation(botID, invocationID string) (*models.BotInvocation, error) {
+	data, err := s.redisRepo.Get(botInvocationPrefix+invocationID, context.Background())
+	if err != nil {
+		return nil, ErrBotInvocationGone
+	}
+
+	var invocation models.BotInvocation
+	if err := json.Unmarshal([]byte(data), &invocation); err != nil {
+		return nil, err
+	}
+	if invocation.BotID != botID {
+		return nil, ErrBotInvocationGone
+	}
+	return &invocation, nil
+}
+
+// CallWebhookBot POSTs a signed invocation to a webhook bot and returns its reply, if any
+func (s *BotService) CallWebhookBot(bot *models.Bot, invocation models.BotInvocation) (string, error) {
+	if bot.Transport != constants.BotTransportWebhook {
+		return "", fmt.Errorf("bot %s does not use webhook transport", bot.ID)
+	}
+
+	body, err := json.Marshal(invocation)
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, bot.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Chat-Timestamp", timestamp)
+	request.Header.Set("X-Chat-Signature", "sha256="+SignWebhookPayload(bot.Secret, timestamp, body))
+
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return "", fmt.Errorf("bot %s answered with status %d", bot.ID, response.StatusCode)
+	}
+
+	responseBody, err := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+	if err != nil || len(bytes.TrimSpace(responseBody)) == 0 {
+		return "", err
+	}
+
+	var reply dtos.BotReplyDto
+	if err := json.Unmarshal(responseBody, &reply); err != nil {
+		return "", fmt.Errorf("invalid reply from bot %s: %w", bot.ID, err)
+	}
+	return reply.Reply, nil
+}
+
+func (s *BotService) commandOwner(command string) string {
+	owner, err := s.redisRepo.Get(botCommandPrefix+command, context.Background())
+	if err != nil {
+		return ""
+	}
+	return owner
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"testing"
+)
+
+func TestRegisterBotValidatesID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{name: "plain ID", id: "helper"},
+		{name: "command key", id: "command:echo", wantErr: true},
+		{name: "invocation key", id: "invocation:abc", wantErr: true},
+		{name: "uppercase", id: "Helper", wantErr: true},
+		{name: "empty", id: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, redisRepo := testRedis(t)
+			bots := NewBotService(redisRepo)
+			_, err := bots.RegisterBot(dtos.CreateBotDto{ID: test.id, Name: "Helper", Transport: constants.BotTransportStream})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RegisterBot(%q) error = %v, wantErr %v", test.id, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookupBotIgnoresCommandKeys(t *testing.T) {
+	_, redisRepo := testRedis(t)
+	bots := NewBotService(redisRepo)
+	if _, err := bots.RegisterBot(dtos.CreateBotDto{ID: "helper", Name: "Helper", Transport: constants.BotTransportStream, Commands: []string{"echo"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if bot := bots.LookupBot("command:echo"); bot != nil {
+		t.Errorf("LookupBot(command:echo) = %+v, want nil", bot)
+	}
+	if bot := bots.LookupBot("helper"); bot == nil {
+		t.Error("LookupBot(helper) = nil, want the bot")
+	}
+}
@@ -3,12 +3,13 @@ package services
 import (
 	"context"
 	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
 	"distributed-chat-system/internal/models"
 	"distributed-chat-system/internal/utils"
 	"distributed-chat-system/pkg/kafka"
 	"distributed-chat-system/pkg/redis"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"os"
 	"sync"
@@ -19,21 +20,43 @@ import (
 
 type ChatConsumerInterface interface {
 	Notify(senderUserID string, message models.ChatMessage) error
+	// NotifyReceipt tells a connected sender that their message was delivered or read
+	NotifyReceipt(event models.ChatEvent) error
+	// NotifyModeration tells a connected user that a moderator acted on them or on a message they received
+	NotifyModeration(event models.ChatEvent) error
+	// Disconnect closes a user's socket on this server, if it holds one
+	Disconnect(userID string, reason string) error
+	// Connections lists the sockets held by this server
+	Connections() []models.ConnectionInfo
 }
 
 type ChatMessageService struct {
 	// Mutex to ensure thread-safe operations
-	kafkaClient   *kafka.KafkaClient
+	kafkaClient   kafka.IKafkaClient
 	mutex         sync.RWMutex
-	chatConsumers *ChatConsumerInterface
+	chatConsumers []ChatConsumerInterface
 	redisRepo     redis.IRedisRepositories
+	botService    *BotService
+	inboxService  *InboxService
+	memberships   *MembershipService
+	pushService   *PushService
+	policy        Policy
+	rateLimiter   *RateLimitService
+	moderation    *ModerationService
 }
 
-func NewChatMessageService(kafkaClient *kafka.KafkaClient, redisRepo redis.IRedisRepositories) *ChatMessageService {
+func NewChatMessageService(kafkaClient kafka.IKafkaClient, redisRepo redis.IRedisRepositories, botService *BotService, inboxService *InboxService, memberships *MembershipService, pushService *PushService, policy Policy, rateLimiter *RateLimitService, moderation *ModerationService) *ChatMessageService {
 	return &ChatMessageService{
 		kafkaClient:   kafkaClient,
 		chatConsumers: nil,
 		redisRepo:     redisRepo,
+		botService:    botService,
+		inboxService:  inboxService,
+		memberships:   memberships,
+		pushService:   pushService,
+		policy:        policy,
+		rateLimiter:   rateLimiter,
+		moderation:    moderation,
 	}
 }
 
@@ -44,7 +67,6 @@ func (s *ChatMessageService) StartMessageConsumption() {
 
 // Handles a single chat message from from Kafka consumer & routes to chat consumers
 func (s *ChatMessageService) consumeChatMessage(message string) {
-	log.Println("Received chat message: ", message)
 	// Unmarshal the message to models.ChatMessage
 	var chatMessage *models.ChatMessage
 	err := json.Unmarshal([]byte(message), &chatMessage)
@@ -53,35 +75,110 @@ func (s *ChatMessageService) consumeChatMessage(message string) {
 		return
 	}
 
+	switch chatMessage.EventType {
+	case constants.EventControlDisconnect, constants.EventControlReceipt, constants.EventControlModeration:
+		s.consumeControlEvent(message)
+		return
+	}
+
+	// Lifecycle events share the topic but are only meant for webhooks
+	if chatMessage.EventType != "" && chatMessage.EventType != constants.EventMessageSent {
+		return
+	}
+
 	log.Println("Unmarshaled chat message: ", chatMessage)
-	// Notify all registered chat consumers
-	if s.chatConsumers != nil {
-		(*s.chatConsumers).Notify(chatMessage.SenderUserID, *chatMessage)
+	// Notify all registered chat consumers, each delivers only to users it holds
+	for _, consumer := range s.consumers() {
+		consumer.Notify(chatMessage.SenderUserID, *chatMessage)
 	}
 }
 
-// SubscribeUserToChatServer adds a consumer user to service registry lookup store
+// userRegistryTTL bounds how long a registry entry outlives a server that died without
+// cleaning up. Live sessions refresh their entry well within it.
+const userRegistryTTL = 5 * time.Minute
+
+var ErrRegistryEntryLost = errors.New("registry entry expired or was taken over by another server")
+
+// unregisterUserScript deletes the registry entry in KEYS[1] only while it still names
+// this server (ARGV[1]), returning -1 when it names another one
+const unregisterUserScript = `
+local current = redis.call('GET', KEYS[1])
+if current == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return 1
+end
+if current then return -1 end
+return 0
+`
+
+// refreshUserScript renews the registry entry in KEYS[1] for ARGV[2] milliseconds only
+// while it still names this server (ARGV[1]), returning 0 when it doesn't
+const refreshUserScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`
+
+// SubscribeUserToChatServer adds a consumer user to service registry lookup store,
+// taking over an entry an older session of the user left on another server
 func (s *ChatMessageService) SubscribeUserToChatServer(userId string) {
-	jsonData := map[string]interface{}{
-		"server_id": os.Getenv("SERVER_ID"),
+	entry, err := registryEntry()
+	if err == nil {
+		err = s.redisRepo.Set(userId, entry, userRegistryTTL, context.Background())
 	}
-
-	jsonString, err := json.Marshal(jsonData)
 	if err != nil {
-		log.Printf("Error marshalling user to chat server")
+		log.Printf("Error adding user %s to service registry lookup store: %v", userId, err)
 		return
 	}
-	s.redisRepo.Set(userId, jsonString, time.Minute*5, context.Background())
 	log.Println("User added to service registry lookup store: ", userId)
+	s.PublishChatEvent(constants.EventUserConnected, userId, "", "")
 }
 
-// SubscribeToChatMessage adds a consumer user to service registry lookup store
+// RefreshUserChatServer renews a connected user's registry entry, so it doesn't expire
+// while the session is alive. An entry a newer session wrote on another server is left
+// alone, and so is one that is gone.
+func (s *ChatMessageService) RefreshUserChatServer(userId string) error {
+	entry, err := registryEntry()
+	if err != nil {
+		return err
+	}
+	args := []interface{}{string(entry), userRegistryTTL.Milliseconds()}
+	result, err := s.redisRepo.Eval(refreshUserScript, []string{userId}, args, context.Background())
+	if err != nil {
+		return err
+	}
+	if refreshed, _ := result.(int64); refreshed == 0 {
+		return ErrRegistryEntryLost
+	}
+	return nil
+}
+
+// UnsubscribeUserToChatServer removes a user from the service registry lookup store.
+// An entry another server wrote since, because the user reconnected there, is kept.
 func (s *ChatMessageService) UnsubscribeUserToChatServer(userId string) {
-	err := s.redisRepo.Del(userId, context.Background())
+	entry, err := registryEntry()
+	if err != nil {
+		return
+	}
+	result, err := s.redisRepo.Eval(unregisterUserScript, []string{userId}, []interface{}{string(entry)}, context.Background())
 	if err != nil {
+		log.Printf("Error removing user %s from service registry lookup store: %v", userId, err)
+		return
+	}
+	if removed, _ := result.(int64); removed < 0 {
+		log.Printf("User %s is registered on another server, leaving the registry entry", userId)
 		return
 	}
 	log.Println("User removed from service registry lookup store: ", userId)
+	s.PublishChatEvent(constants.EventUserDisconnected, userId, "", "")
+}
+
+// registryEntry is the registry value naming this server
+func registryEntry() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"server_id": os.Getenv("SERVER_ID"),
+	})
 }
 
 // LookupUserChatServer finds which server is the user currently connected to
@@ -99,47 +196,399 @@ func (s *ChatMessageService) LookupUserChatServer(userId string) *string {
 	}
 	return utils.StringPointer(lookupData["server_id"].(string))
 }
-func (s *ChatMessageService) SetChatConsumer(consumer ChatConsumerInterface) {
+
+// AddChatConsumer registers a front end (WebSocket, IRC, ...) that holds user sessions
+func (s *ChatMessageService) AddChatConsumer(consumer ChatConsumerInterface) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.chatConsumers = &consumer
+	s.chatConsumers = append(s.chatConsumers, consumer)
 	log.Println("Consumer subscribed")
 }
 
-func (s *ChatMessageService) UnsetChatConsumer() {
+func (s *ChatMessageService) UnsetChatConsumers() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.chatConsumers = nil
 }
 
-// Publishes message to Kafka
-func (s *ChatMessageService) SendMessageToUser(senderUserID string, message dtos.ChatMessageDto) error {
+func (s *ChatMessageService) consumers() []ChatConsumerInterface {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.chatConsumers
+}
+
+// Publishes message to Kafka, unless it is a slash command or addressed to a webhook bot.
+// Returns the event id of the message, or the invocation id when a bot took it.
+// A send the policy or moderation denies returns a *PolicyError, one over a rate limit a *RateLimitError.
+func (s *ChatMessageService) SendMessageToUser(sender Sender, message dtos.ChatMessageDto) (string, error) {
+	if err := s.rateLimiter.AllowMessage(sender.UserID, message.ChatID); err != nil {
+		return "", err
+	}
+	text, err := s.moderation.Moderate(sender, message.ChatID, message.ReceiverUserID, message.MessageType, message.Message)
+	if err != nil {
+		return "", err
+	}
+	message.Message = text
+	eventID, err := s.sendMessageToUser(sender, message, "")
+	if err == nil {
+		s.recordTraffic(message.ChatID)
+	}
+	return eventID, err
+}
+
+// sendMessageToUser authorizes and routes one copy of a message. groupMessageID is set on
+// the copies of a fan-out, empty otherwise.
+func (s *ChatMessageService) sendMessageToUser(sender Sender, message dtos.ChatMessageDto, groupMessageID string) (string, error) {
+	request := SendRequest{
+		Sender:         sender,
+		Action:         constants.ActionSendMessage,
+		ChatID:         message.ChatID,
+		ReceiverUserID: message.ReceiverUserID,
+		MessageType:    message.MessageType,
+	}
+
+	// Ciphertext is opaque: it is never parsed for commands and bots can't read it
+	if message.MessageType == constants.MessageTypeCiphertext {
+		if s.botService.LookupBot(message.ReceiverUserID) != nil {
+			return "", ErrCiphertextToBot
+		}
+		if err := s.authorize(request); err != nil {
+			return "", err
+		}
+		return s.publishChatMessage(sender, message, groupMessageID)
+	}
+
+	// Commands are parsed before routing so they reach the owning bot rather than the receiver
+	if command, ok := ParseCommand(message.Message); ok {
+		if bot := s.botService.LookupCommand(command.Name); bot != nil {
+			request.Action = constants.ActionInvokeBot
+			if err := s.authorize(request); err != nil {
+				return "", err
+			}
+			return s.invokeBot(bot, sender, message, command)
+		}
+	}
+
+	if err := s.authorize(request); err != nil {
+		return "", err
+	}
+
+	if bot := s.botService.LookupBot(message.ReceiverUserID); bot != nil && bot.Transport == constants.BotTransportWebhook {
+		return s.invokeBot(bot, sender, message, nil)
+	}
+
+	return s.publishChatMessage(sender, message, groupMessageID)
+}
+
+// SendMessageToChat fans a message out to every other member of a group chat.
+// A bot command is handed to its bot once rather than once per member. Members
+// the policy denies individually (e.g. who blocked the sender) are skipped. The fan-out
+// counts as a single message against the rate limits and is moderated once.
+func (s *ChatMessageService) SendMessageToChat(sender Sender, chatID, messageType, text string) error {
+	if err := s.rateLimiter.AllowMessage(sender.UserID, chatID); err != nil {
+		return err
+	}
+	// Moderated before commands are parsed, so bots only get what members would see
+	text, err := s.moderation.Moderate(sender, chatID, "", messageType, text)
+	if err != nil {
+		return err
+	}
+
+	request := SendRequest{Sender: sender, Action: constants.ActionSendMessage, ChatID: chatID, MessageType: messageType}
+
+	if command, ok := ParseCommand(text); ok && messageType != constants.MessageTypeCiphertext {
+		if bot := s.botService.LookupCommand(command.Name); bot != nil {
+			request.Action = constants.ActionInvokeBot
+			if err := s.authorize(request); err != nil {
+				return err
+			}
+			_, err := s.invokeBot(bot, sender, dtos.ChatMessageDto{ChatID: chatID, MessageType: messageType, Message: text}, command)
+			return err
+		}
+	}
+
+	if err := s.authorize(request); err != nil {
+		return err
+	}
+	members, err := s.memberships.Members(chatID)
+	if err != nil {
+		return err
+	}
+
+	// The copies get their own event IDs and share a group message ID, so moderators
+	// can delete all of them
+	groupMessageID := uuid.New().String()
+	var lastErr error
+	for _, member := range members {
+		if member == sender.UserID {
+			continue
+		}
+		_, err := s.sendMessageToUser(sender, dtos.ChatMessageDto{
+			ChatID:         chatID,
+			ReceiverUserID: member,
+			MessageType:    messageType,
+			Message:        text,
+		}, groupMessageID)
+		var policyErr *PolicyError
+		if errors.As(err, &policyErr) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error sending chat %s message to member %s: %v", chatID, member, err)
+			lastErr = err
+		}
+	}
+	s.recordTraffic(chatID)
+	return lastErr
+}
+
+// recordTraffic marks a group chat as used, see MembershipService.RecordTraffic
+func (s *ChatMessageService) recordTraffic(chatID string) {
+	if err := s.memberships.RecordTraffic(chatID); err != nil {
+		log.Printf("Error recording traffic of chat %s: %v", chatID, err)
+	}
+}
+
+// authorize asks the policy about a send. Errors other than a denial are logged and
+// reported to the client as an unavailable policy, so internals don't leak.
+func (s *ChatMessageService) authorize(request SendRequest) error {
+	err := s.policy.AuthorizeSend(request)
+	if err == nil {
+		return nil
+	}
+
+	var policyErr *PolicyError
+	if errors.As(err, &policyErr) {
+		log.Printf("Denied %s by %s in chat %s: %s", request.Action, request.Sender.UserID, request.ChatID, policyErr.Code)
+		return policyErr
+	}
+	log.Printf("Error authorizing %s by %s in chat %s: %v", request.Action, request.Sender.UserID, request.ChatID, err)
+	return deny(constants.DenyPolicyUnavailable, "authorization is temporarily unavailable")
+}
+
+// publishChatMessage publishes a message to the Kafka topic of the server holding the receiver
+func (s *ChatMessageService) publishChatMessage(sender Sender, message dtos.ChatMessageDto, groupMessageID string) (string, error) {
 	// Here convert the message to string and publish to topic: chat-message
 	chatMessage := &models.ChatMessage{
 		EventID:        uuid.New().String(), // (Optional) For tracing purpose.
-		SenderUserID:   senderUserID,
+		EventType:      constants.EventMessageSent,
+		SenderUserID:   sender.UserID,
 		ChatID:         message.ChatID,
 		ReceiverUserID: message.ReceiverUserID,
 		MessageType:    message.MessageType,
 		Message:        message.Message,
+		Tenant:         sender.Tenant,
+		GroupMessageID: groupMessageID,
+	}
+
+	// Store first, so a receiver who is offline or reconnecting can replay it on resume.
+	// Ciphertext is never stored, so it only reaches receivers who are connected.
+	encrypted := chatMessage.MessageType == constants.MessageTypeCiphertext
+	if !encrypted {
+		if err := s.inboxService.Append(chatMessage); err != nil {
+			return "", err
+		}
 	}
 
 	messageJson, err := json.Marshal(chatMessage)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	serverLookupId := s.LookupUserChatServer(chatMessage.ReceiverUserID)
+	if serverLookupId == nil && encrypted {
+		return "", ErrUserNotConnected
+	}
 	if serverLookupId == nil {
-		return fmt.Errorf("user not connected to any server")
+		log.Printf("Receiver %s is offline, message %s kept in inbox", chatMessage.ReceiverUserID, chatMessage.EventID)
+		go s.pushService.NotifyOffline(*chatMessage)
+		// Still published to this server's topic, so its webhook consumers see message.sent
+		if err := s.kafkaClient.PublishMessage(os.Getenv("SERVER_ID"), chatMessage.ChatID, string(messageJson)); err != nil {
+			log.Printf("Error publishing message %s for webhooks: %v", chatMessage.EventID, err)
+		}
+		return chatMessage.EventID, nil
 	}
 
 	log.Println("receiver user is connected to server: ", *serverLookupId)
 	// Publish message to topic: chat-message
 	err = s.kafkaClient.PublishMessage(*serverLookupId, chatMessage.ChatID, string(messageJson))
 	if err != nil {
-		return err
+		return "", err
 	}
 	log.Println("Message published successfully with event id", chatMessage.EventID)
+	return chatMessage.EventID, nil
+}
+
+// invokeBot hands a message to a bot. Webhook bots answer inline, stream bots get the
+// invocation routed to their socket like any user and answer with a bot_reply frame.
+func (s *ChatMessageService) invokeBot(bot *models.Bot, sender Sender, message dtos.ChatMessageDto, command *BotCommand) (string, error) {
+	invocation := models.BotInvocation{
+		InvocationID:   uuid.New().String(),
+		BotID:          bot.ID,
+		ChatID:         message.ChatID,
+		SenderUserID:   sender.UserID,
+		ReceiverUserID: message.ReceiverUserID,
+		Message:        message.Message,
+		Tenant:         sender.Tenant,
+	}
+	if command != nil {
+		invocation.Command = command.Name
+		invocation.Args = command.Args
+	}
+	log.Printf("Invoking bot %s for user %s (command: %q)", bot.ID, sender.UserID, invocation.Command)
+
+	if bot.Transport == constants.BotTransportWebhook {
+		// Called in the background so a slow bot never stalls the sender's read loop
+		go func() {
+			reply, err := s.botService.CallWebhookBot(bot, invocation)
+			if err != nil {
+				log.Printf("Error invoking bot %s: %v", bot.ID, err)
+				return
+			}
+			if reply != "" {
+				s.PostBotReply(bot.ID, invocation, reply)
+			}
+		}()
+		return invocation.InvocationID, nil
+	}
+
+	if err := s.botService.SaveInvocation(invocation); err != nil {
+		return "", err
+	}
+	invocationJson, err := json.Marshal(invocation)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.publishChatMessage(sender, dtos.ChatMessageDto{
+		ChatID:         invocation.ChatID,
+		ReceiverUserID: bot.ID,
+		MessageType:    constants.MessageTypeBotInvocation,
+		Message:        string(invocationJson),
+	}, ""); err != nil {
+		return "", err
+	}
+	return invocation.InvocationID, nil
+}
+
+// ReplyToBotInvocation posts a stream bot's answer to an outstanding invocation back into its chat
+func (s *ChatMessageService) ReplyToBotInvocation(botID, invocationID, reply string) error {
+	invocation, err := s.botService.LookupInvocation(botID, invocationID)
+	if err != nil {
+		return err
+	}
+	s.PostBotReply(botID, *invocation, reply)
 	return nil
 }
+
+// PostBotReply sends a bot's reply to every human participant of the invoking chat: the
+// sender and receiver of a direct message, or every member of a group chat. The reply is
+// stored under the invoking sender's tenant.
+func (s *ChatMessageService) PostBotReply(botID string, invocation models.BotInvocation, reply string) {
+	bot := Sender{UserID: botID, Roles: []string{constants.RoleBot}, Tenant: invocation.Tenant}
+	participants := []string{invocation.SenderUserID}
+	if invocation.ReceiverUserID == "" {
+		members, err := s.memberships.Members(invocation.ChatID)
+		if err != nil {
+			log.Printf("Error listing members of chat %s for the reply of bot %s: %v", invocation.ChatID, botID, err)
+		}
+		for _, member := range members {
+			if member != botID && member != invocation.SenderUserID {
+				participants = append(participants, member)
+			}
+		}
+	} else if invocation.ReceiverUserID != botID && invocation.ReceiverUserID != invocation.SenderUserID {
+		participants = append(participants, invocation.ReceiverUserID)
+	}
+
+	groupMessageID := uuid.New().String()
+	for _, participant := range participants {
+		_, err := s.publishChatMessage(bot, dtos.ChatMessageDto{
+			ChatID:         invocation.ChatID,
+			ReceiverUserID: participant,
+			MessageType:    constants.MessageTypeText,
+			Message:        reply,
+		}, groupMessageID)
+		if err != nil {
+			log.Printf("Error posting reply of bot %s to user %s: %v", botID, participant, err)
+		}
+	}
+}
+
+// MissedMessages returns the inbox messages a reconnecting client has not seen yet
+func (s *ChatMessageService) MissedMessages(userID string, resume ResumeRequest) ([]models.ChatMessage, error) {
+	return s.inboxService.Missed(userID, resume)
+}
+
+// MarkMessageDelivered records that a message reached its receiver and tells the sender
+func (s *ChatMessageService) MarkMessageDelivered(message models.ChatMessage) {
+	s.PublishChatEvent(constants.EventMessageDelivered, message.ReceiverUserID, message.ChatID, message.EventID)
+	s.sendReceipt(constants.ReceiptStatusDelivered, message.ReceiverUserID, message.SenderUserID, message.ChatID, message.EventID)
+}
+
+// MarkMessageRead records that a user has read a message they received and sends its
+// sender a read receipt. The message must still be in the reader's inbox; its stored
+// sender and chat are used, so clients can't aim receipts at anyone else.
+func (s *ChatMessageService) MarkMessageRead(userID, messageEventID string) {
+	message, err := s.inboxService.Find(userID, messageEventID)
+	if err != nil {
+		log.Printf("Dropping read frame of user %s for event %s: %v", userID, messageEventID, err)
+		return
+	}
+	s.PublishChatEvent(constants.EventMessageRead, userID, message.ChatID, message.EventID)
+	s.sendReceipt(constants.ReceiptStatusRead, userID, message.SenderUserID, message.ChatID, message.EventID)
+}
+
+// sendReceipt routes a receipt to the server holding the sender's socket. Receipts are
+// best effort: senders who are offline don't get them.
+func (s *ChatMessageService) sendReceipt(status, actorUserID, senderUserID, chatID, messageEventID string) {
+	go func() {
+		serverID := s.LookupUserChatServer(senderUserID)
+		if serverID == nil {
+			return
+		}
+
+		event := models.ChatEvent{
+			EventID:        uuid.New().String(),
+			EventType:      constants.EventControlReceipt,
+			UserID:         senderUserID,
+			ServerID:       *serverID,
+			ChatID:         chatID,
+			MessageEventID: messageEventID,
+			ActorUserID:    actorUserID,
+			Status:         status,
+			OccurredAt:     time.Now().UTC(),
+		}
+		if err := s.publishChatEvent(*serverID, event); err != nil {
+			log.Printf("Error sending %s receipt for %s to user %s: %v", status, messageEventID, senderUserID, err)
+		}
+	}()
+}
+
+// PublishChatEvent publishes a lifecycle event to this server's topic in the background,
+// so the caller (often the delivery path) never waits on Kafka
+func (s *ChatMessageService) PublishChatEvent(eventType, userID, chatID, messageEventID string) {
+	event := models.ChatEvent{
+		EventID:        uuid.New().String(),
+		EventType:      eventType,
+		UserID:         userID,
+		ServerID:       os.Getenv("SERVER_ID"),
+		ChatID:         chatID,
+		MessageEventID: messageEventID,
+		OccurredAt:     time.Now().UTC(),
+	}
+
+	go func() {
+		if err := s.publishChatEvent(event.ServerID, event); err != nil {
+			log.Printf("Error publishing chat event %s for user %s: %v", eventType, userID, err)
+		}
+	}()
+}
+
+// publishChatEvent publishes an event to the topic of the given server
+func (s *ChatMessageService) publishChatEvent(serverID string, event models.ChatEvent) error {
+	eventJson, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.kafkaClient.PublishMessage(serverID, event.UserID, string(eventJson))
+}
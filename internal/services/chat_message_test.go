@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/kms"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// recordingKafka keeps what is published by topic, standing in for the brokers
+type recordingKafka struct {
+	mutex     sync.Mutex
+	published map[string][]string
+}
+
+func (k *recordingKafka) PublishMessage(topic, receiverID, message string) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if k.published == nil {
+		k.published = make(map[string][]string)
+	}
+	k.published[topic] = append(k.published[topic], message)
+	return nil
+}
+
+func (k *recordingKafka) ConsumeMessages(ctx context.Context, topic string, handler func(message string)) error {
+	return nil
+}
+
+func (k *recordingKafka) ConsumeMessagesWithGroup(ctx context.Context, topic, groupID string, handler func(message string)) error {
+	return nil
+}
+
+// testRedis is a Redis repository over an in-memory server
+func testRedis(t *testing.T) (*miniredis.Miniredis, redis.IRedisRepositories) {
+	t.Helper()
+	mini := miniredis.RunT(t)
+	return mini, redis.NewRedisRepositories(goredis.NewClient(&goredis.Options{Addr: mini.Addr()}))
+}
+
+// testEncryption is an EncryptionService with a fresh keyfile
+func testEncryption(t *testing.T, redisRepo redis.IRedisRepositories) *EncryptionService {
+	t.Helper()
+	keys, err := kms.NewLocalKMS(filepath.Join(t.TempDir(), "kms-keys.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEncryptionService(redisRepo, keys, EncryptionConfigFromEnv())
+}
+
+type testChat struct {
+	*ChatMessageService
+	redisRepo   redis.IRedisRepositories
+	kafka       *recordingKafka
+	inbox       *InboxService
+	memberships *MembershipService
+	bots        *BotService
+	moderation  *ModerationService
+}
+
+// newTestChat wires a ChatMessageService the way the server does, minus Kafka consumers
+func newTestChat(t *testing.T) *testChat {
+	t.Helper()
+	t.Setenv("SERVER_ID", "test-server")
+	_, redisRepo := testRedis(t)
+	encryption := testEncryption(t, redisRepo)
+	chat := &testChat{
+		redisRepo:   redisRepo,
+		kafka:       &recordingKafka{},
+		inbox:       NewInboxService(redisRepo, InboxConfigFromEnv(), encryption),
+		memberships: NewMembershipService(redisRepo),
+		bots:        NewBotService(redisRepo),
+		moderation:  NewModerationService(redisRepo, ModerationConfigFromEnv(), encryption),
+	}
+	policy := PolicyChain{NewMembershipPolicy(chat.memberships)}
+	chat.ChatMessageService = NewChatMessageService(chat.kafka, redisRepo, chat.bots, chat.inbox, chat.memberships,
+		NewPushService(redisRepo, nil, PushConfigFromEnv()), policy, NewRateLimitService(redisRepo, RateLimitConfigFromEnv()), chat.moderation)
+	return chat
+}
+
+// inboxOf returns the messages stored for a user, oldest first
+func (c *testChat) inboxOf(t *testing.T, userID string) []models.ChatMessage {
+	t.Helper()
+	messages, err := c.inbox.Since(userID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestPostBotReply(t *testing.T) {
+	tests := []struct {
+		name       string
+		invocation models.BotInvocation
+		members    []string
+		want       []string // Users whose inbox gets the reply
+		notWant    []string
+	}{
+		{
+			name:       "group command reaches every member",
+			invocation: models.BotInvocation{ChatID: "team", SenderUserID: "alice"},
+			members:    []string{"alice", "bob", "carol", "helper"},
+			want:       []string{"alice", "bob", "carol"},
+			notWant:    []string{"helper"},
+		},
+		{
+			name:       "direct message reaches sender and receiver",
+			invocation: models.BotInvocation{ChatID: models.DirectChatID("alice", "bob"), SenderUserID: "alice", ReceiverUserID: "bob"},
+			want:       []string{"alice", "bob"},
+		},
+		{
+			name:       "message to the bot reaches the sender only",
+			invocation: models.BotInvocation{ChatID: models.DirectChatID("alice", "helper"), SenderUserID: "alice", ReceiverUserID: "helper"},
+			want:       []string{"alice"},
+			notWant:    []string{"helper"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chat := newTestChat(t)
+			for _, member := range test.members {
+				if err := chat.memberships.AddMember(test.invocation.ChatID, member); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			chat.PostBotReply("helper", test.invocation, "pong")
+
+			groupMessageID := ""
+			for _, user := range test.want {
+				messages := chat.inboxOf(t, user)
+				if len(messages) != 1 || messages[0].Message != "pong" || messages[0].SenderUserID != "helper" || messages[0].ChatID != test.invocation.ChatID {
+					t.Fatalf("inbox of %s = %+v, want the reply", user, messages)
+				}
+				if groupMessageID == "" {
+					groupMessageID = messages[0].GroupMessageID
+				}
+				if messages[0].GroupMessageID == "" || messages[0].GroupMessageID != groupMessageID {
+					t.Errorf("copies don't share a group message ID: %q and %q", messages[0].GroupMessageID, groupMessageID)
+				}
+			}
+			for _, user := range test.notWant {
+				if messages := chat.inboxOf(t, user); len(messages) != 0 {
+					t.Errorf("inbox of %s = %+v, want none", user, messages)
+				}
+			}
+		})
+	}
+}
+
+func TestSendMessageToChatModeratesCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		wantErr bool
+		want    string // Message the bot gets, empty when it isn't invoked
+	}{
+		{name: "redacted command reaches the bot redacted", action: constants.ModerationActionRedact, want: "/echo ****"},
+		{name: "rejected command never reaches the bot", action: constants.ModerationActionReject, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chat := newTestChat(t)
+			for _, member := range []string{"alice", "bob"} {
+				if err := chat.memberships.AddMember("team", member); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := chat.bots.RegisterBot(dtos.CreateBotDto{ID: "helper", Name: "Helper", Transport: constants.BotTransportStream, Commands: []string{"echo"}}); err != nil {
+				t.Fatal(err)
+			}
+			rules := dtos.ModerationRulesDto{Rules: []models.ModerationRule{{Filter: constants.ModerationFilterProfanity, Action: test.action, Words: []string{"darn"}}}}
+			if _, err := chat.moderation.SetRules(constants.ModerationScopeChat, "team", rules); err != nil {
+				t.Fatal(err)
+			}
+
+			err := chat.SendMessageToChat(Sender{UserID: "alice"}, "team", constants.MessageTypeText, "/echo darn")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("SendMessageToChat() error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			messages := chat.inboxOf(t, "helper")
+			if test.want == "" {
+				if len(messages) != 0 {
+					t.Fatalf("bot inbox = %+v, want none", messages)
+				}
+				return
+			}
+			if len(messages) != 1 || messages[0].MessageType != constants.MessageTypeBotInvocation {
+				t.Fatalf("bot inbox = %+v, want one invocation", messages)
+			}
+			var invocation models.BotInvocation
+			if err := json.Unmarshal([]byte(messages[0].Message), &invocation); err != nil {
+				t.Fatal(err)
+			}
+			if invocation.Message != test.want || strings.Contains(invocation.Args, "darn") {
+				t.Errorf("invocation = %+v, want message %q", invocation, test.want)
+			}
+		})
+	}
+}
+
+func TestSendMessagePublishesForWebhooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		receiver  string // Server the receiver is connected to, empty when offline
+		wantTopic string
+	}{
+		{name: "online receiver", receiver: "other-server", wantTopic: "other-server"},
+		{name: "offline receiver", wantTopic: "test-server"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chat := newTestChat(t)
+			if test.receiver != "" {
+				entry, _ := json.Marshal(map[string]string{"server_id": test.receiver})
+				if err := chat.redisRepo.Set("bob", entry, 0, context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			eventID, err := chat.SendMessageToUser(Sender{UserID: "alice"}, dtos.ChatMessageDto{
+				ChatID: models.DirectChatID("alice", "bob"), ReceiverUserID: "bob", MessageType: constants.MessageTypeText, Message: "hi",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			published := chat.kafka.published[test.wantTopic]
+			if len(published) != 1 {
+				t.Fatalf("topic %s got %v, want the message", test.wantTopic, chat.kafka.published)
+			}
+			payload, err := toWebhookPayload(published[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payload.Type != constants.EventMessageSent || payload.ID != eventID {
+				t.Errorf("webhook payload = %+v, want message.sent for %s", payload, eventID)
+			}
+		})
+	}
+}
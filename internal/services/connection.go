@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const connectionSnapshotPrefix = "connections:"
+
+var ErrUserNotConnected = errors.New("user not connected to any server")
+
+// StartConnectionReporting periodically publishes this server's sockets to Redis,
+// so admin endpoints on any server can list connections cluster-wide
+func (s *ChatMessageService) StartConnectionReporting(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.reportConnections(interval * 3)
+		}
+	}()
+}
+
+func (s *ChatMessageService) reportConnections(ttl time.Duration) {
+	snapshot := models.ServerConnections{
+		ServerID:    os.Getenv("SERVER_ID"),
+		ReportedAt:  time.Now().UTC(),
+		Connections: []models.ConnectionInfo{},
+	}
+	for _, consumer := range s.consumers() {
+		snapshot.Connections = append(snapshot.Connections, consumer.Connections()...)
+	}
+	snapshotJson, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Println("Error marshalling connection snapshot:", err)
+		return
+	}
+
+	err = s.redisRepo.Set(connectionSnapshotPrefix+snapshot.ServerID, snapshotJson, ttl, context.Background())
+	if err != nil {
+		log.Println("Error publishing connection snapshot:", err)
+	}
+}
+
+// ListConnections returns the latest connection snapshot of one server, or of all servers when serverID is empty
+func (s *ChatMessageService) ListConnections(serverID string) ([]models.ServerConnections, error) {
+	pattern := connectionSnapshotPrefix + "*"
+	if serverID != "" {
+		pattern = connectionSnapshotPrefix + serverID
+	}
+
+	keys, err := s.redisRepo.Keys(pattern, context.Background())
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	snapshots := make([]models.ServerConnections, 0, len(keys))
+	for _, key := range keys {
+		data, err := s.redisRepo.Get(key, context.Background())
+		if err != nil {
+			continue // Expired between SCAN and GET
+		}
+		var snapshot models.ServerConnections
+		if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+			log.Printf("Skipping malformed connection snapshot %s: %v", strings.TrimPrefix(key, connectionSnapshotPrefix), err)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// ForceDisconnect asks the server holding the user's socket to close it
+func (s *ChatMessageService) ForceDisconnect(userID, reason string) (string, error) {
+	serverID := s.LookupUserChatServer(userID)
+	if serverID == nil {
+		return "", ErrUserNotConnected
+	}
+
+	event := models.ChatEvent{
+		EventID:    uuid.New().String(),
+		EventType:  constants.EventControlDisconnect,
+		UserID:     userID,
+		ServerID:   *serverID,
+		Reason:     reason,
+		OccurredAt: time.Now().UTC(),
+	}
+	if err := s.publishChatEvent(*serverID, event); err != nil {
+		return "", err
+	}
+
+	log.Printf("Disconnect of user %s requested on server %s", userID, *serverID)
+	return *serverID, nil
+}
+
+// Handles a control event addressed to this server
+func (s *ChatMessageService) consumeControlEvent(message string) {
+	var event models.ChatEvent
+	if err := json.Unmarshal([]byte(message), &event); err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, consumer := range s.consumers() {
+		switch event.EventType {
+		case constants.EventControlDisconnect:
+			if err := consumer.Disconnect(event.UserID, event.Reason); err != nil {
+				log.Printf("Error disconnecting user %s: %v", event.UserID, err)
+			}
+		case constants.EventControlReceipt:
+			if err := consumer.NotifyReceipt(event); err != nil {
+				log.Printf("Error sending receipt to user %s: %v", event.UserID, err)
+			}
+		case constants.EventControlModeration:
+			if err := consumer.NotifyModeration(event); err != nil {
+				log.Printf("Error sending %s notice to user %s: %v", event.Status, event.UserID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/utils"
+	"distributed-chat-system/pkg/kafka"
+	"distributed-chat-system/pkg/mailer"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	digestPreferencesPrefix = "digest:preferences:"
+	digestStatePrefix       = "digest:state:"
+	digestOfflinePrefix     = "digest:offline:"
+	digestLockPrefix        = "digest:lock:"
+
+	// Users offline for longer than this get no further digests
+	digestOfflineMarkerTTL = 7 * 24 * time.Hour
+)
+
+type DigestConfig struct {
+	Interval           time.Duration // How often each server looks for users due a digest
+	OfflineAfter       time.Duration // How long a user must be offline before a digest is sent
+	QuietPeriod        time.Duration // Minimum time between two digests to the same user
+	MaxMessagesPerChat int           // Newest messages quoted per conversation
+}
+
+// DigestConfigFromEnv builds the digest configuration from DIGEST_* environment variables
+func DigestConfigFromEnv() *DigestConfig {
+	return &DigestConfig{
+		Interval:           utils.GetEnvDuration("DIGEST_INTERVAL", 15*time.Minute),
+		OfflineAfter:       utils.GetEnvDuration("DIGEST_OFFLINE_AFTER", 4*time.Hour),
+		QuietPeriod:        utils.GetEnvDuration("DIGEST_QUIET_PERIOD", 24*time.Hour),
+		MaxMessagesPerChat: utils.GetEnvInt("DIGEST_MAX_MESSAGES_PER_CHAT", 5),
+	}
+}
+
+// DigestService emails users who have been offline a summary of their unread
+// conversations. It learns about presence and reads from the lifecycle events on
+// the server topic, under its own consumer group like webhooks.
+type DigestService struct {
+	kafkaClient  kafka.IKafkaClient
+	redisRepo    redis.IRedisRepositories
+	inboxService *InboxService
+	chatService  *ChatMessageService
+	mailer       mailer.Mailer
+	config       *DigestConfig
+}
+
+func NewDigestService(kafkaClient kafka.IKafkaClient, redisRepo redis.IRedisRepositories, inboxService *InboxService, chatService *ChatMessageService, mailer mailer.Mailer, config *DigestConfig) *DigestService {
+	return &DigestService{
+		kafkaClient:  kafkaClient,
+		redisRepo:    redisRepo,
+		inboxService: inboxService,
+		chatService:  chatService,
+		mailer:       mailer,
+		config:       config,
+	}
+}
+
+// StartDigests consumes this server's lifecycle events and runs the digest job periodically
+func (s *DigestService) StartDigests() {
+	groupID := os.Getenv("CHAT_GROUP_ID") + "-digest"
+	s.kafkaClient.ConsumeMessagesWithGroup(context.Background(), os.Getenv("SERVER_ID"), groupID, s.consumeEvent)
+
+	go func() {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.sendDueDigests()
+		}
+	}()
+}
+
+// Handles a lifecycle event, tracking when users go offline and what they have read
+func (s *DigestService) consumeEvent(message string) {
+	var event models.ChatEvent
+	if err := json.Unmarshal([]byte(message), &event); err != nil {
+		return
+	}
+
+	ctx := context.Background()
+	switch event.EventType {
+	case constants.EventUserDisconnected:
+		offlineSince, _ := event.OccurredAt.MarshalText()
+		s.redisRepo.Set(digestOfflinePrefix+event.UserID, offlineSince, digestOfflineMarkerTTL, ctx)
+	case constants.EventUserConnected:
+		s.redisRepo.Del(digestOfflinePrefix+event.UserID, ctx)
+	case constants.EventMessageRead:
+		if err := s.inboxService.MarkRead(event.UserID, event.MessageEventID); err != nil {
+			log.Printf("Error recording read of %s by user %s: %v", event.MessageEventID, event.UserID, err)
+		}
+	}
+}
+
+// GetPreferences returns a user's digest settings, empty when never set
+func (s *DigestService) GetPreferences(userID string) (*models.DigestPreferences, error) {
+	data, err := s.redisRepo.Get(digestPreferencesPrefix+userID, context.Background())
+	if err != nil {
+		return &models.DigestPreferences{UserID: userID}, nil
+	}
+	var preferences models.DigestPreferences
+	if err := json.Unmarshal([]byte(data), &preferences); err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// UpdatePreferences stores a user's digest address and opt-out
+func (s *DigestService) UpdatePreferences(userID string, request dtos.UpdateDigestPreferencesDto) (*models.DigestPreferences, error) {
+	preferences := &models.DigestPreferences{
+		UserID:    userID,
+		Email:     request.Email,
+		OptOut:    request.OptOut,
+		UpdatedAt: time.Now().UTC(),
+	}
+	preferencesJson, err := json.Marshal(preferences)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.Set(digestPreferencesPrefix+userID, preferencesJson, 0, context.Background()); err != nil {
+		return nil, err
+	}
+	log.Printf("Digest preferences updated for user %s (opt-out: %t)", userID, preferences.OptOut)
+	return preferences, nil
+}
+
+// sendDueDigests sends a digest to every offline user who is due one
+func (s *DigestService) sendDueDigests() {
+	keys, err := s.redisRepo.Keys(digestOfflinePrefix+"*", context.Background())
+	if err != nil {
+		log.Println("Error listing offline users for digests:", err)
+		return
+	}
+	for _, key := range keys {
+		userID := strings.TrimPrefix(key, digestOfflinePrefix)
+		if err := s.sendDigest(userID); err != nil {
+			log.Printf("Error sending digest to user %s: %v", userID, err)
+		}
+	}
+}
+
+func (s *DigestService) sendDigest(userID string) error {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	offlineSince, err := s.redisRepo.Get(digestOfflinePrefix+userID, ctx)
+	if err != nil {
+		return nil // Came back online meanwhile
+	}
+	var since time.Time
+	if err := since.UnmarshalText([]byte(offlineSince)); err != nil || now.Sub(since) < s.config.OfflineAfter {
+		return nil
+	}
+	if s.chatService.LookupUserChatServer(userID) != nil {
+		return nil
+	}
+
+	preferences, err := s.GetPreferences(userID)
+	if err != nil || preferences.Email == "" || preferences.OptOut {
+		return err
+	}
+
+	state := s.state(userID)
+	if now.Sub(state.LastSentAt) < s.config.QuietPeriod {
+		return nil
+	}
+
+	// Every server runs the job, the lock makes sure only one of them mails the user
+	locked, err := s.redisRepo.SetNX(digestLockPrefix+userID, []byte(os.Getenv("SERVER_ID")), s.config.Interval, ctx)
+	if err != nil || !locked {
+		return err
+	}
+
+	messages, err := s.inboxService.Unread(userID, state.LastSequence)
+	if err != nil {
+		return err
+	}
+	digest := newDigest(userID, messages, s.config.MaxMessagesPerChat)
+	if digest.Total == 0 {
+		return nil
+	}
+
+	mail, err := renderDigest(preferences.Email, digest)
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(mail); err != nil {
+		return err
+	}
+
+	state.LastSequence = messages[len(messages)-1].Sequence
+	state.LastSentAt = now
+	stateJson, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	log.Printf("Digest of %d unread messages sent to user %s", digest.Total, userID)
+	return s.redisRepo.Set(digestStatePrefix+userID, stateJson, 0, ctx)
+}
+
+func (s *DigestService) state(userID string) models.DigestState {
+	var state models.DigestState
+	data, err := s.redisRepo.Get(digestStatePrefix+userID, context.Background())
+	if err == nil {
+		json.Unmarshal([]byte(data), &state)
+	}
+	return state
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bytes"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/mailer"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	texttemplate "text/template"
+)
+
+// Longest message excerpt quoted in a digest, in characters
+const digestExcerptLength = 200
+
+type digest struct {
+	UserID string
+	Total  int
+	Chats  []digestChat
+}
+
+type digestChat struct {
+	ChatID   string
+	Unread   int
+	Messages []digestMessage // The newest messages, oldest first
+}
+
+type digestMessage struct {
+	Sender string
+	Text   string
+}
+
+// newDigest groups unread messages by conversation, most active first
+func newDigest(userID string, messages []models.ChatMessage, maxPerChat int) digest {
+	result := digest{UserID: userID}
+	chats := make(map[string]*digestChat)
+	for _, message := range messages {
+		// Bot invocations are machine traffic, not something to remind a person of
+		if message.MessageType == constants.MessageTypeBotInvocation {
+			continue
+		}
+		chat, exists := chats[message.ChatID]
+		if !exists {
+			chat = &digestChat{ChatID: message.ChatID}
+			chats[message.ChatID] = chat
+		}
+		chat.Unread++
+		chat.Messages = append(chat.Messages, digestMessage{Sender: message.SenderUserID, Text: excerpt(message.Message)})
+		if len(chat.Messages) > maxPerChat {
+			chat.Messages = chat.Messages[1:]
+		}
+		result.Total++
+	}
+
+	for _, chat := range chats {
+		result.Chats = append(result.Chats, *chat)
+	}
+	sort.Slice(result.Chats, func(i, j int) bool {
+		if result.Chats[i].Unread != result.Chats[j].Unread {
+			return result.Chats[i].Unread > result.Chats[j].Unread
+		}
+		return result.Chats[i].ChatID < result.Chats[j].ChatID
+	})
+	return result
+}
+
+func excerpt(text string) string {
+	runes := []rune(text)
+	if len(runes) <= digestExcerptLength {
+		return text
+	}
+	return string(runes[:digestExcerptLength]) + "…"
+}
+
+var digestTextTemplate = texttemplate.Must(texttemplate.New("digest").Parse(`Hi {{.UserID}},
+
+you have {{.Total}} unread message{{if ne .Total 1}}s{{end}} in {{len .Chats}} conversation{{if ne (len .Chats) 1}}s{{end}}.
+{{range .Chats}}
+{{.ChatID}} ({{.Unread}} unread)
+{{range .Messages}}  {{.Sender}}: {{.Text}}
+{{end}}{{end}}
+You receive this digest because you were offline. Turn it off in your notification settings.
+`))
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<p>Hi {{.UserID}},</p>
+<p>you have <strong>{{.Total}}</strong> unread message{{if ne .Total 1}}s{{end}} in {{len .Chats}} conversation{{if ne (len .Chats) 1}}s{{end}}.</p>
+{{range .Chats}}
+<h3>{{.ChatID}} <small>({{.Unread}} unread)</small></h3>
+<ul>
+{{range .Messages}}<li><strong>{{.Sender}}</strong>: {{.Text}}</li>
+{{end}}</ul>
+{{end}}
+<p style="color: #888">You receive this digest because you were offline. Turn it off in your notification settings.</p>
+</body>
+</html>
+`))
+
+// renderDigest renders the text and HTML versions of a digest email
+func renderDigest(to string, digest digest) (mailer.Mail, error) {
+	var text, html bytes.Buffer
+	if err := digestTextTemplate.Execute(&text, digest); err != nil {
+		return mailer.Mail{}, err
+	}
+	if err := digestHTMLTemplate.Execute(&html, digest); err != nil {
+		return mailer.Mail{}, err
+	}
+
+	subject := fmt.Sprintf("You have %d unread messages", digest.Total)
+	if digest.Total == 1 {
+		subject = "You have 1 unread message"
+	}
+	return mailer.Mail{
+		To:       to,
+		Subject:  subject,
+		TextBody: text.String(),
+		HTMLBody: html.String(),
+	}, nil
+}
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/utils"
+	"distributed-chat-system/pkg/kms"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	encryptionKeyRingPrefix = "encryption:tenant:"
+	encryptionLockPrefix    = "encryption:lock:"
+	reencryptionJobPrefix   = "encryption:reencrypt:"
+
+	dataKeySize           = 32 // AES-256
+	encryptionLockTimeout = 30 * time.Second
+)
+
+var (
+	ErrDataKeyNotFound     = errors.New("data key not found, it may have been shredded")
+	ErrKeyRingBusy         = errors.New("the tenant's keys are being changed, try again")
+	ErrReencryptionRunning = errors.New("a re-encryption of this tenant is already running")
+	ErrNoReencryptionJob   = errors.New("no re-encryption has been run for this tenant")
+)
+
+type EncryptionConfig struct {
+	KeyCacheTTL time.Duration // How long servers keep unwrapped data keys, and how late opening sees a shred
+	JobTTL      time.Duration // How long finished re-encryption jobs are kept
+}
+
+// EncryptionConfigFromEnv builds the encryption configuration from ENCRYPTION_* environment variables
+func EncryptionConfigFromEnv() *EncryptionConfig {
+	return &EncryptionConfig{
+		KeyCacheTTL: utils.GetEnvDuration("ENCRYPTION_KEY_CACHE_TTL", 5*time.Minute),
+		JobTTL:      utils.GetEnvDuration("ENCRYPTION_JOB_TTL", 7*24*time.Hour),
+	}
+}
+
+// cachedKeyRing is a tenant's key ring with the data keys this server has unwrapped
+type cachedKeyRing struct {
+	ring     *models.TenantKeyRing
+	keys     map[int]cipher.AEAD
+	loadedAt time.Time
+}
+
+// EncryptionService encrypts stored data with envelope encryption. Every tenant has its
+// own data key, kept in Redis only wrapped by a KMS master key. Rotating a data key adds
+// a version; data sealed with older versions stays readable until it is re-encrypted.
+// Shredding deletes a tenant's data keys, which leaves everything sealed with them unreadable.
+type EncryptionService struct {
+	redisRepo redis.IRedisRepositories
+	kms       *kms.LocalKMS
+	config    *EncryptionConfig
+
+	mutex sync.Mutex
+	cache map[string]*cachedKeyRing
+}
+
+func NewEncryptionService(redisRepo redis.IRedisRepositories, kms *kms.LocalKMS, config *EncryptionConfig) *EncryptionService {
+	return &EncryptionService{
+		redisRepo: redisRepo,
+		kms:       kms,
+		config:    config,
+		cache:     make(map[string]*cachedKeyRing),
+	}
+}
+
+// Seal encrypts plaintext with the tenant's active data key, creating the tenant's first
+// key when it has none. aad binds the result to where it is stored: opening it with a
+// different aad fails. The stored ring is read every time, so nothing is sealed with a
+// key that was rotated out or shredded on another server.
+func (s *EncryptionService) Seal(tenant, aad string, plaintext []byte) (*models.SealedData, error) {
+	tenant = tenantOrDefault(tenant)
+	cached, err := s.keyRing(tenant, true, true)
+	if err != nil {
+		return nil, err
+	}
+	version := cached.ring.ActiveVersion
+	gcm, err := s.dataKey(cached, version)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &models.SealedData{
+		Tenant:     tenant,
+		Generation: cached.ring.Generation,
+		KeyVersion: version,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, []byte(aad)),
+	}, nil
+}
+
+// Open decrypts sealed data. ErrDataKeyNotFound means the key version is gone: the
+// tenant was shredded, or the version retired after a re-encryption missed this value.
+func (s *EncryptionService) Open(sealed *models.SealedData, aad string) ([]byte, error) {
+	cached, err := s.keyRing(sealed.Tenant, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cached.ring.Keys[sealed.KeyVersion]; !ok || cached.ring.Generation != sealed.Generation {
+		// Rotated or shredded on another server since the ring was cached
+		if cached, err = s.keyRing(sealed.Tenant, false, true); err != nil {
+			return nil, err
+		}
+	}
+	if cached.ring.Generation != sealed.Generation {
+		// Sealed with a ring that was shredded, its versions mean nothing to this one
+		return nil, fmt.Errorf("%w: tenant %s generation %s", ErrDataKeyNotFound, sealed.Tenant, sealed.Generation)
+	}
+	gcm, err := s.dataKey(cached, sealed.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, sealed.Nonce, sealed.Ciphertext, []byte(aad))
+}
+
+// SealJson encodes a value to JSON and seals it
+func (s *EncryptionService) SealJson(tenant, aad string, value interface{}) (*models.SealedData, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return s.Seal(tenant, aad, data)
+}
+
+// OpenJson opens sealed data and decodes the JSON inside into value
+func (s *EncryptionService) OpenJson(sealed *models.SealedData, aad string, value interface{}) error {
+	data, err := s.Open(sealed, aad)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// KeyRing returns a tenant's key ring as stored, with its keys still wrapped
+func (s *EncryptionService) KeyRing(tenant string) (*models.TenantKeyRing, error) {
+	return s.loadKeyRing(tenantOrDefault(tenant))
+}
+
+// RotateDataKey adds a new version of the tenant's data key and makes it active.
+// Every server seals with it from then on.
+func (s *EncryptionService) RotateDataKey(tenant string) (*models.TenantKeyRing, error) {
+	tenant = tenantOrDefault(tenant)
+	var ring *models.TenantKeyRing
+	err := s.withLock(tenant, func() error {
+		var err error
+		ring, err = s.loadKeyRing(tenant)
+		if errors.Is(err, ErrDataKeyNotFound) {
+			ring, err = &models.TenantKeyRing{Tenant: tenant, Generation: uuid.NewString(), Keys: make(map[int]*models.DataKey)}, nil
+		}
+		if err != nil {
+			return err
+		}
+		version := 1
+		for existing := range ring.Keys {
+			version = max(version, existing+1)
+		}
+		if ring.Keys[version], err = s.newDataKey(version); err != nil {
+			return err
+		}
+		ring.ActiveVersion = version
+		return s.saveKeyRing(ring)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Rotated data key of tenant %s to version %d", tenant, ring.ActiveVersion)
+	return ring, nil
+}
+
+// RotateMasterKey makes a new KMS master key active and rewraps every tenant's data
+// keys with it. The data keys themselves, and so the stored data, are unchanged.
+// Returns the new master key id and how many key rings were rewrapped.
+func (s *EncryptionService) RotateMasterKey() (string, int, error) {
+	masterKeyID, err := s.kms.RotateMasterKey()
+	if err != nil {
+		return "", 0, err
+	}
+	keys, err := s.redisRepo.Keys(encryptionKeyRingPrefix+"*", context.Background())
+	if err != nil {
+		return masterKeyID, 0, err
+	}
+
+	rewrapped := 0
+	for _, key := range keys {
+		tenant := strings.TrimPrefix(key, encryptionKeyRingPrefix)
+		err := s.withLock(tenant, func() error {
+			ring, err := s.loadKeyRing(tenant)
+			if err != nil {
+				return err
+			}
+			for _, dataKey := range ring.Keys {
+				if dataKey.MasterKeyID == masterKeyID {
+					continue
+				}
+				plain, err := s.kms.Unwrap(dataKey.MasterKeyID, dataKey.WrappedKey)
+				if err != nil {
+					return fmt.Errorf("unwrapping version %d: %w", dataKey.Version, err)
+				}
+				if dataKey.MasterKeyID, dataKey.WrappedKey, err = s.kms.Wrap(plain); err != nil {
+					return err
+				}
+			}
+			return s.saveKeyRing(ring)
+		})
+		if errors.Is(err, ErrDataKeyNotFound) {
+			continue // Shredded meanwhile
+		}
+		if err != nil {
+			return masterKeyID, rewrapped, fmt.Errorf("rewrapping keys of tenant %s: %w", tenant, err)
+		}
+		rewrapped++
+	}
+	log.Printf("Rewrapped the data keys of %d tenants with master key %s", rewrapped, masterKeyID)
+	return masterKeyID, rewrapped, nil
+}
+
+// Shred deletes every version of a tenant's data key. Data sealed with them can no
+// longer be read by anyone; other servers drop their cached copy within KeyCacheTTL,
+// and stop sealing with it at once. Data the tenant's users send afterwards is sealed
+// with a new ring of another generation.
+func (s *EncryptionService) Shred(tenant string) error {
+	tenant = tenantOrDefault(tenant)
+	err := s.withLock(tenant, func() error {
+		if _, err := s.loadKeyRing(tenant); err != nil {
+			return err
+		}
+		return s.redisRepo.Del(encryptionKeyRingPrefix+tenant, context.Background())
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Shredded the data keys of tenant %s", tenant)
+	return nil
+}
+
+// keyRing returns the tenant's cached key ring, loading it when missing or stale. With
+// fresh the stored ring is read in any case, keeping the keys already unwrapped while it
+// is the same generation. With create, a tenant without keys gets its first version.
+func (s *EncryptionService) keyRing(tenant string, create, fresh bool) (*cachedKeyRing, error) {
+	s.mutex.Lock()
+	cached, ok := s.cache[tenant]
+	s.mutex.Unlock()
+	ok = ok && time.Since(cached.loadedAt) < s.config.KeyCacheTTL
+	if ok && !fresh {
+		return cached, nil
+	}
+
+	ring, err := s.loadKeyRing(tenant)
+	if errors.Is(err, ErrDataKeyNotFound) && create {
+		ring, err = s.createKeyRing(tenant)
+	}
+	if err != nil {
+		s.forget(tenant)
+		return nil, err
+	}
+	reloaded := &cachedKeyRing{ring: ring, keys: make(map[int]cipher.AEAD), loadedAt: time.Now()}
+	s.mutex.Lock()
+	if ok && cached.ring.Generation == ring.Generation {
+		for version, gcm := range cached.keys {
+			if _, kept := ring.Keys[version]; kept {
+				reloaded.keys[version] = gcm
+			}
+		}
+		reloaded.loadedAt = cached.loadedAt
+	}
+	s.cache[tenant] = reloaded
+	s.mutex.Unlock()
+	return reloaded, nil
+}
+
+// createKeyRing stores version 1 of a tenant's data key. When servers race, the ring
+// stored first wins and the others use it.
+func (s *EncryptionService) createKeyRing(tenant string) (*models.TenantKeyRing, error) {
+	dataKey, err := s.newDataKey(1)
+	if err != nil {
+		return nil, err
+	}
+	ring := &models.TenantKeyRing{
+		Tenant:        tenant,
+		Generation:    uuid.NewString(),
+		ActiveVersion: 1,
+		Keys:          map[int]*models.DataKey{1: dataKey},
+		UpdatedAt:     time.Now().UTC(),
+	}
+	ringJson, err := json.Marshal(ring)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.redisRepo.SetNX(encryptionKeyRingPrefix+tenant, ringJson, 0, context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return s.loadKeyRing(tenant)
+	}
+	log.Printf("Created data key of tenant %s", tenant)
+	return ring, nil
+}
+
+// dataKey returns the cipher for a version of the ring, unwrapping the key on first use
+func (s *EncryptionService) dataKey(cached *cachedKeyRing, version int) (cipher.AEAD, error) {
+	s.mutex.Lock()
+	gcm, ok := cached.keys[version]
+	s.mutex.Unlock()
+	if ok {
+		return gcm, nil
+	}
+
+	dataKey, ok := cached.ring.Keys[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: tenant %s version %d", ErrDataKeyNotFound, cached.ring.Tenant, version)
+	}
+	plain, err := s.kms.Unwrap(dataKey.MasterKeyID, dataKey.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(plain)
+	if err != nil {
+		return nil, err
+	}
+	if gcm, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	cached.keys[version] = gcm
+	s.mutex.Unlock()
+	return gcm, nil
+}
+
+func (s *EncryptionService) newDataKey(version int) (*models.DataKey, error) {
+	plain := make([]byte, dataKeySize)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, err
+	}
+	masterKeyID, wrapped, err := s.kms.Wrap(plain)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DataKey{Version: version, MasterKeyID: masterKeyID, WrappedKey: wrapped, CreatedAt: time.Now().UTC()}, nil
+}
+
+func (s *EncryptionService) loadKeyRing(tenant string) (*models.TenantKeyRing, error) {
+	data, err := s.redisRepo.Get(encryptionKeyRingPrefix+tenant, context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("%w: tenant %s", ErrDataKeyNotFound, tenant)
+	}
+	var ring models.TenantKeyRing
+	if err := json.Unmarshal([]byte(data), &ring); err != nil {
+		return nil, err
+	}
+	return &ring, nil
+}
+
+// saveKeyRing stores a changed ring and drops this server's cached copy
+func (s *EncryptionService) saveKeyRing(ring *models.TenantKeyRing) error {
+	ring.UpdatedAt = time.Now().UTC()
+	ringJson, err := json.Marshal(ring)
+	if err != nil {
+		return err
+	}
+	if err := s.redisRepo.Set(encryptionKeyRingPrefix+ring.Tenant, ringJson, 0, context.Background()); err != nil {
+		return err
+	}
+	s.forget(ring.Tenant)
+	return nil
+}
+
+func (s *EncryptionService) forget(tenant string) {
+	s.mutex.Lock()
+	delete(s.cache, tenant)
+	s.mutex.Unlock()
+}
+
+// withLock runs a change to a tenant's key ring while no other server changes it
+func (s *EncryptionService) withLock(tenant string, change func() error) error {
+	ctx := context.Background()
+	locked, err := s.redisRepo.SetNX(encryptionLockPrefix+tenant, []byte("1"), encryptionLockTimeout, ctx)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return ErrKeyRingBusy
+	}
+	defer s.redisRepo.Del(encryptionLockPrefix+tenant, ctx)
+	defer s.forget(tenant)
+	return change()
+}
+
+func tenantOrDefault(tenant string) string {
+	if tenant == "" {
+		return constants.EncryptionDefaultTenant
+	}
+	return tenant
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	reencryptionLockPrefix  = "encryption:reencrypt:lock:"
+	reencryptionLockTimeout = 10 * time.Minute // Refreshed as the job progresses, frees the tenant if its server dies
+)
+
+// Each script swaps a stored value only while it is still the one that was read, so an
+// entry deleted or changed during re-encryption is left alone.
+const (
+	replaceSortedSetMemberScript = `
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score then return 0 end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('ZADD', KEYS[1], score, ARGV[2])
+return 1
+`
+	replaceListEntryScript = `
+local index = redis.call('LPOS', KEYS[1], ARGV[1])
+if not index then return 0 end
+redis.call('LSET', KEYS[1], index, ARGV[2])
+return 1
+`
+	replaceValueScript = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then return 0 end
+redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+return 1
+`
+)
+
+// reencryption walks a tenant's stored data, resealing what is not sealed with the
+// active key version. Versions it could not move data off are kept.
+type reencryption struct {
+	service *EncryptionService
+	job     *models.ReencryptionJob
+	stuck   map[int]bool
+}
+
+// Reencrypt starts moving everything a tenant has stored onto its active data key, then
+// retires the older versions. It runs in the background; Reencryption reports progress.
+// Data stored before encryption at rest was enabled is sealed along the way.
+func (s *EncryptionService) Reencrypt(tenant, actor string) (*models.ReencryptionJob, error) {
+	tenant = tenantOrDefault(tenant)
+	ring, err := s.loadKeyRing(tenant)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := s.redisRepo.SetNX(reencryptionLockPrefix+tenant, []byte(actor), s.config.KeyCacheTTL+reencryptionLockTimeout, context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrReencryptionRunning
+	}
+
+	job := &models.ReencryptionJob{
+		Tenant:     tenant,
+		Status:     constants.ReencryptionRunning,
+		KeyVersion: ring.ActiveVersion,
+		Actor:      actor,
+		StartedAt:  time.Now().UTC(),
+	}
+	if err := s.saveReencryption(job); err != nil {
+		s.redisRepo.Del(reencryptionLockPrefix+tenant, context.Background())
+		return nil, err
+	}
+	snapshot := *job
+
+	run := &reencryption{service: s, job: job, stuck: make(map[int]bool)}
+	go run.run(ring)
+	return &snapshot, nil
+}
+
+// Reencryption returns the last re-encryption job of a tenant
+func (s *EncryptionService) Reencryption(tenant string) (*models.ReencryptionJob, error) {
+	data, err := s.redisRepo.Get(reencryptionJobPrefix+tenantOrDefault(tenant), context.Background())
+	if err != nil {
+		return nil, ErrNoReencryptionJob
+	}
+	var job models.ReencryptionJob
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *EncryptionService) saveReencryption(job *models.ReencryptionJob) error {
+	jobJson, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.redisRepo.Set(reencryptionJobPrefix+job.Tenant, jobJson, s.config.JobTTL, context.Background())
+}
+
+func (r *reencryption) run(ring *models.TenantKeyRing) {
+	s, job := r.service, r.job
+	defer s.redisRepo.Del(reencryptionLockPrefix+job.Tenant, context.Background())
+
+	// A server that read the ring just before the rotation may still be sealing with the
+	// previous version; give such writes time to land so nothing is sealed behind the walk
+	if wait := time.Until(ring.UpdatedAt.Add(s.config.KeyCacheTTL)); wait > 0 {
+		log.Printf("Re-encryption of tenant %s starts in %s, once every server uses key version %d", job.Tenant, wait.Round(time.Second), job.KeyVersion)
+		time.Sleep(wait)
+	}
+
+	err := r.walk()
+	if err == nil {
+		err = r.retire()
+	}
+	now := time.Now().UTC()
+	job.CompletedAt = &now
+	job.Status = constants.ReencryptionCompleted
+	if err != nil {
+		log.Printf("Re-encryption of tenant %s failed: %v", job.Tenant, err)
+		job.Status = constants.ReencryptionFailed
+		job.Error = err.Error()
+	} else {
+		log.Printf("Re-encrypted tenant %s: %d of %d values resealed, retired key versions %v", job.Tenant, job.Resealed, job.Scanned, job.Retired)
+	}
+	if err := s.saveReencryption(job); err != nil {
+		log.Printf("Error saving re-encryption job of tenant %s: %v", job.Tenant, err)
+	}
+}
+
+// walk reseals the tenant's inbox entries, report copies, flagged moderation hits and
+// dead-lettered webhook messages
+func (r *reencryption) walk() error {
+	s := r.service
+	ctx := context.Background()
+
+	inboxes, err := s.redisRepo.Keys(inboxPrefix+"*", ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range inboxes {
+		owner, ok := inboxOwner(key)
+		if !ok {
+			continue
+		}
+		entries, err := s.redisRepo.ZRangeByScore(key, "-inf", "+inf", ctx)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			message, sealed, err := openInboxEntry(s, owner, entry)
+			if !r.needsResealing(message.Tenant, sealed, err) {
+				continue
+			}
+			resealed, sealErr := sealInboxEntry(s, message)
+			if err := r.replace(replaceSortedSetMemberScript, key, entry, resealed, sealed, sealErr); err != nil {
+				return err
+			}
+		}
+		if err := r.progress(); err != nil {
+			return err
+		}
+	}
+
+	reports, err := s.redisRepo.Keys(reportPrefix+"*", ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range reports {
+		data, err := s.redisRepo.Get(key, ctx)
+		if err != nil {
+			continue
+		}
+		var report models.AbuseReport
+		if err := json.Unmarshal([]byte(data), &report); err != nil || (report.Message == nil && report.SealedMessage == nil) {
+			continue
+		}
+		sealed := report.SealedMessage
+		var message models.ChatMessage
+		if sealed != nil {
+			err = s.OpenJson(sealed, reportPrefix+report.ID, &message)
+		} else {
+			message = *report.Message
+		}
+		if !r.needsResealing(message.Tenant, sealed, err) {
+			continue
+		}
+		var sealErr error
+		report.Message = nil
+		report.SealedMessage, sealErr = s.SealJson(message.Tenant, reportPrefix+report.ID, message)
+		resealed, _ := json.Marshal(report)
+		if err := r.replace(replaceValueScript, key, data, resealed, sealed, sealErr); err != nil {
+			return err
+		}
+	}
+	if err := r.progress(); err != nil {
+		return err
+	}
+
+	hits, err := s.redisRepo.LRange(moderationHitsKey, 0, -1, ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range hits {
+		var hit models.ModerationHit
+		if err := json.Unmarshal([]byte(entry), &hit); err != nil || (hit.Message == "" && hit.SealedMessage == nil) {
+			continue
+		}
+		sealed := hit.SealedMessage
+		err := openHitMessage(s, &hit)
+		if !r.needsResealing(hit.Tenant, sealed, err) {
+			continue
+		}
+		sealErr := sealHitMessage(s, &hit)
+		resealed, _ := json.Marshal(hit)
+		if err := r.replace(replaceListEntryScript, moderationHitsKey, entry, resealed, sealed, sealErr); err != nil {
+			return err
+		}
+	}
+	if err := r.progress(); err != nil {
+		return err
+	}
+
+	deadLetters, err := s.redisRepo.LRange(webhookDeadLetterKey, 0, -1, ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range deadLetters {
+		var deadLetter models.WebhookDeadLetter
+		if err := json.Unmarshal([]byte(entry), &deadLetter); err != nil {
+			continue
+		}
+		message, ok := deadLetterMessage(&deadLetter)
+		if !ok || (message.Message == "" && deadLetter.SealedMessage == nil) {
+			continue
+		}
+		sealed := deadLetter.SealedMessage
+		err := openDeadLetterMessage(s, &deadLetter)
+		if !r.needsResealing(message.Tenant, sealed, err) {
+			continue
+		}
+		sealErr := sealDeadLetterMessage(s, &deadLetter)
+		resealed, _ := json.Marshal(deadLetter)
+		if err := r.replace(replaceListEntryScript, webhookDeadLetterKey, entry, resealed, sealed, sealErr); err != nil {
+			return err
+		}
+	}
+	return r.progress()
+}
+
+// progress records the job's counts and holds on to the tenant's lock
+func (r *reencryption) progress() error {
+	r.service.redisRepo.Expire(reencryptionLockPrefix+r.job.Tenant, reencryptionLockTimeout, context.Background())
+	return r.service.saveReencryption(r.job)
+}
+
+// needsResealing tells whether a value of the tenant is on an older key version, or
+// still stored in plain. Values that can't be opened keep their version from retiring,
+// unless their key is gone already.
+func (r *reencryption) needsResealing(tenant string, sealed *models.SealedData, openErr error) bool {
+	if sealed != nil {
+		tenant = sealed.Tenant
+	}
+	if tenantOrDefault(tenant) != r.job.Tenant {
+		return false
+	}
+	r.job.Scanned++
+	if openErr != nil {
+		// Values of a shredded generation name versions of a ring that no longer exists
+		if sealed != nil && !errors.Is(openErr, ErrDataKeyNotFound) {
+			r.stuck[sealed.KeyVersion] = true
+		}
+		return false
+	}
+	return sealed == nil || sealed.KeyVersion != r.job.KeyVersion
+}
+
+// replace swaps the stored value for its resealed form, unless it changed meanwhile
+func (r *reencryption) replace(script, key, stored string, resealed []byte, sealed *models.SealedData, sealErr error) error {
+	if sealErr != nil {
+		if sealed != nil {
+			r.stuck[sealed.KeyVersion] = true
+		}
+		log.Printf("Error resealing a value of %s: %v", key, sealErr)
+		return nil
+	}
+	if _, err := r.service.redisRepo.Eval(script, []string{key}, []interface{}{stored, string(resealed)}, context.Background()); err != nil {
+		return err
+	}
+	r.job.Resealed++
+	return nil
+}
+
+// retire drops the key versions older than the job's, unless data is still stuck on them
+func (r *reencryption) retire() error {
+	s, job := r.service, r.job
+	err := s.withLock(job.Tenant, func() error {
+		ring, err := s.loadKeyRing(job.Tenant)
+		if err != nil {
+			return err
+		}
+		for version := range ring.Keys {
+			if version < job.KeyVersion && !r.stuck[version] {
+				delete(ring.Keys, version)
+				job.Retired = append(job.Retired, version)
+			}
+		}
+		if len(job.Retired) == 0 {
+			return nil
+		}
+		return s.saveKeyRing(ring)
+	})
+	if errors.Is(err, ErrDataKeyNotFound) {
+		return nil // Shredded meanwhile, nothing left to retire
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/kms"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncryptionOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		// seal runs against two servers sharing Redis and the keyfile, returning a value
+		// for the second server to open
+		seal    func(t *testing.T, first, second *EncryptionService) *models.SealedData
+		aad     string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "sealed on another server",
+			seal: func(t *testing.T, first, _ *EncryptionService) *models.SealedData {
+				return mustSeal(t, first, "hello")
+			},
+			want: "hello",
+		},
+		{
+			name: "older version after a rotation",
+			seal: func(t *testing.T, first, second *EncryptionService) *models.SealedData {
+				sealed := mustSeal(t, first, "hello")
+				if _, err := first.RotateDataKey("acme"); err != nil {
+					t.Fatal(err)
+				}
+				if rotated := mustSeal(t, second, "newer"); rotated.KeyVersion != sealed.KeyVersion+1 {
+					t.Errorf("sealed with version %d after the rotation, want %d", rotated.KeyVersion, sealed.KeyVersion+1)
+				}
+				return sealed
+			},
+			want: "hello",
+		},
+		{
+			name: "shredded tenant",
+			seal: func(t *testing.T, first, _ *EncryptionService) *models.SealedData {
+				sealed := mustSeal(t, first, "hello")
+				if err := first.Shred("acme"); err != nil {
+					t.Fatal(err)
+				}
+				return sealed
+			},
+			wantErr: ErrDataKeyNotFound,
+		},
+		{
+			name: "shredded tenant, still cached within the key cache TTL",
+			seal: func(t *testing.T, first, second *EncryptionService) *models.SealedData {
+				sealed := mustSeal(t, first, "hello")
+				if _, err := second.Open(sealed, "test"); err != nil {
+					t.Fatal(err)
+				}
+				if err := first.Shred("acme"); err != nil {
+					t.Fatal(err)
+				}
+				return sealed
+			},
+			want: "hello",
+		},
+		{
+			name: "old generation after a reshred",
+			seal: func(t *testing.T, first, second *EncryptionService) *models.SealedData {
+				sealed := mustSeal(t, first, "hello")
+				if err := first.Shred("acme"); err != nil {
+					t.Fatal(err)
+				}
+				// The new ring starts over at version 1, which the old value names too
+				if fresh := mustSeal(t, second, "newer"); fresh.Generation == sealed.Generation || fresh.KeyVersion != sealed.KeyVersion {
+					t.Fatalf("sealed with generation %s version %d after the shred", fresh.Generation, fresh.KeyVersion)
+				}
+				return sealed
+			},
+			wantErr: ErrDataKeyNotFound,
+		},
+		{
+			name: "new generation after a reshred",
+			seal: func(t *testing.T, first, second *EncryptionService) *models.SealedData {
+				mustSeal(t, second, "hello")
+				if err := first.Shred("acme"); err != nil {
+					t.Fatal(err)
+				}
+				return mustSeal(t, first, "newer")
+			},
+			want: "newer",
+		},
+		{
+			name: "other associated data",
+			seal: func(t *testing.T, first, _ *EncryptionService) *models.SealedData {
+				return mustSeal(t, first, "hello")
+			},
+			aad:     "elsewhere",
+			wantErr: errors.New("any"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, redisRepo := testRedis(t)
+			keys, err := kms.NewLocalKMS(filepath.Join(t.TempDir(), "kms-keys.json"), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := &EncryptionConfig{KeyCacheTTL: time.Hour}
+			first, second := NewEncryptionService(redisRepo, keys, config), NewEncryptionService(redisRepo, keys, config)
+
+			sealed := test.seal(t, first, second)
+			aad := test.aad
+			if aad == "" {
+				aad = "test"
+			}
+			plaintext, err := second.Open(sealed, aad)
+			switch {
+			case test.wantErr == nil && err != nil:
+				t.Fatalf("Open() error = %v", err)
+			case test.wantErr != nil && err == nil:
+				t.Fatalf("Open() = %q, want an error", plaintext)
+			case errors.Is(test.wantErr, ErrDataKeyNotFound) && !errors.Is(err, ErrDataKeyNotFound):
+				t.Fatalf("Open() error = %v, want %v", err, ErrDataKeyNotFound)
+			}
+			if string(plaintext) != test.want {
+				t.Errorf("Open() = %q, want %q", plaintext, test.want)
+			}
+		})
+	}
+}
+
+func mustSeal(t *testing.T, encryption *EncryptionService, plaintext string) *models.SealedData {
+	t.Helper()
+	sealed, err := encryption.Seal("acme", "test", []byte(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
+
+func TestReencrypt(t *testing.T) {
+	_, redisRepo := testRedis(t)
+	keys, err := kms.NewLocalKMS(filepath.Join(t.TempDir(), "kms-keys.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryption := NewEncryptionService(redisRepo, keys, &EncryptionConfig{KeyCacheTTL: time.Millisecond, JobTTL: time.Hour})
+	inbox := NewInboxService(redisRepo, InboxConfigFromEnv(), encryption)
+	webhooks := NewWebhookService(&recordingKafka{}, redisRepo, encryption, WebhookConfigFromEnv())
+
+	// A message of a shredded generation can't be resealed, but mustn't keep its version alive
+	shredded := &models.ChatMessage{EventID: "event-0", SenderUserID: "alice", ReceiverUserID: "bob", Tenant: "acme", Message: "gone"}
+	if err := inbox.Append(shredded); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryption.Shred("acme"); err != nil {
+		t.Fatal(err)
+	}
+	message := &models.ChatMessage{EventID: "event-1", SenderUserID: "alice", ReceiverUserID: "bob", Tenant: "acme", Message: "hello"}
+	if err := inbox.Append(message); err != nil {
+		t.Fatal(err)
+	}
+	webhooks.deadLetter(models.WebhookDeadLetter{SubscriptionID: "sub-1", Payload: models.WebhookPayload{ID: "event-1", Type: constants.EventMessageSent, Data: *message}})
+	if _, err := encryption.RotateDataKey("acme"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := encryption.Reencrypt("acme", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	var job *models.ReencryptionJob
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if job, err = encryption.Reencryption("acme"); err == nil && job.CompletedAt != nil {
+			break
+		}
+	}
+	if job == nil || job.Status != constants.ReencryptionCompleted || job.Resealed != 2 || len(job.Retired) != 1 || job.Retired[0] != 1 {
+		t.Fatalf("re-encryption job = %+v, want two values resealed and version 1 retired", job)
+	}
+
+	messages, err := inbox.Since("bob", 0)
+	if err != nil || len(messages) != 1 || messages[0].Message != "hello" {
+		t.Fatalf("inbox after re-encryption = %+v, %v", messages, err)
+	}
+	deadLetters, err := webhooks.ListDeadLetters(10)
+	if err != nil || len(deadLetters) != 1 {
+		t.Fatalf("dead letters after re-encryption = %+v, %v", deadLetters, err)
+	}
+	if message, _ := deadLetterMessage(&deadLetters[0]); message.Message != "hello" {
+		t.Errorf("dead letter message after re-encryption = %q", message.Message)
+	}
+}
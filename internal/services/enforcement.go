@@ -0,0 +1,74 @@
+package services
+
+import (
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeleteMessage removes a message from its receivers' inboxes and tells their connected
+// clients to drop it. Every member of a group chat gets a copy with its own event id,
+// so copies are matched by their shared group message id. Returns how many copies were removed.
+func (s *ChatMessageService) DeleteMessage(message models.ChatMessage, reason string) (int, error) {
+	receivers := []string{message.ReceiverUserID}
+	members, err := s.memberships.Members(message.ChatID)
+	if err != nil {
+		return 0, err
+	}
+	if len(members) > 0 {
+		receivers = members
+	}
+
+	removed := 0
+	for _, receiver := range receivers {
+		if receiver == message.SenderUserID {
+			continue
+		}
+		copies, err := s.inboxService.Remove(receiver, func(stored models.ChatMessage) bool {
+			if stored.EventID == message.EventID {
+				return true
+			}
+			return message.GroupMessageID != "" && stored.GroupMessageID == message.GroupMessageID
+		})
+		if err != nil {
+			return removed, err
+		}
+		for _, removedCopy := range copies {
+			s.NotifyModeration(receiver, constants.ReportActionDeleteMessage, reason, removedCopy.ChatID, removedCopy.EventID, nil)
+		}
+		removed += len(copies)
+	}
+
+	log.Printf("Deleted %d copies of message %s by %s in chat %s", removed, message.EventID, message.SenderUserID, message.ChatID)
+	return removed, nil
+}
+
+// NotifyModeration tells a user's connected clients about a moderator's action. Like
+// receipts this is best effort: users who are offline don't get it.
+func (s *ChatMessageService) NotifyModeration(userID, action, reason, chatID, messageEventID string, until *time.Time) {
+	go func() {
+		serverID := s.LookupUserChatServer(userID)
+		if serverID == nil {
+			return
+		}
+
+		event := models.ChatEvent{
+			EventID:        uuid.New().String(),
+			EventType:      constants.EventControlModeration,
+			UserID:         userID,
+			ServerID:       *serverID,
+			ChatID:         chatID,
+			MessageEventID: messageEventID,
+			Reason:         reason,
+			Status:         action,
+			Until:          until,
+			OccurredAt:     time.Now().UTC(),
+		}
+		if err := s.publishChatEvent(*serverID, event); err != nil {
+			log.Printf("Error sending %s notice to user %s: %v", action, userID, err)
+		}
+	}()
+}
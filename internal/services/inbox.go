@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/utils"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	inboxPrefix         = "inbox:"
+	inboxSequencePrefix = "inbox:seq:"
+	inboxReadPrefix     = "inbox:read:"
+	// Hash of event id to sequence, so a single message is found without reading the inbox
+	inboxIndexPrefix = "inbox:index:"
+	// Receivers a sender's messages were stored for, so erasure finds the copies
+	// without reading every inbox. Kept without expiry, since copies may outlive it.
+	inboxReceiversPrefix = "inbox:receivers:"
+)
+
+var ErrMessageNotFound = errors.New("message not found in inbox")
+
+// indexInboxScript records an event's sequence in the inbox index. Once the index holds
+// twice the retained messages, fields of sequences trimmed from the inbox are dropped.
+// KEYS[1] index, ARGV event id, sequence, max messages, TTL in seconds.
+const indexInboxScript = `
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+local max = tonumber(ARGV[3])
+if redis.call('HLEN', KEYS[1]) > 2 * max then
+	local floor = tonumber(ARGV[2]) - max
+	local fields = redis.call('HGETALL', KEYS[1])
+	for i = 1, #fields, 2 do
+		if tonumber(fields[i + 1]) <= floor then
+			redis.call('HDEL', KEYS[1], fields[i])
+		end
+	end
+end
+return 1
+`
+
+type InboxConfig struct {
+	MaxMessages int64         // Messages retained per user for replay
+	TTL         time.Duration // Inboxes of users who receive nothing expire after this
+}
+
+// InboxConfigFromEnv builds the inbox configuration from INBOX_* environment variables
+func InboxConfigFromEnv() *InboxConfig {
+	return &InboxConfig{
+		MaxMessages: int64(utils.GetEnvInt("INBOX_MAX_MESSAGES", 1000)),
+		TTL:         utils.GetEnvDuration("INBOX_TTL", 72*time.Hour),
+	}
+}
+
+// ResumeRequest is what a reconnecting client presents to pick up where it left off
+type ResumeRequest struct {
+	LastSequence int64  // Highest inbox sequence the client has seen
+	LastEventID  string // Alternative to LastSequence for clients that only track event ids
+}
+
+// storedInboxEntry is an inbox entry as stored: a message sealed with its sender's tenant
+// key, or a plain message stored before encryption at rest
+type storedInboxEntry struct {
+	*models.ChatMessage
+	Sealed *models.SealedData `json:"sealed,omitempty"`
+}
+
+// InboxService keeps a bounded per-user log of routed messages. Every message gets a
+// per-receiver sequence number, so a reconnecting client can ask for what it missed.
+// Messages are stored encrypted.
+type InboxService struct {
+	redisRepo  redis.IRedisRepositories
+	config     *InboxConfig
+	encryption *EncryptionService
+}
+
+func NewInboxService(redisRepo redis.IRedisRepositories, config *InboxConfig, encryption *EncryptionService) *InboxService {
+	return &InboxService{
+		redisRepo:  redisRepo,
+		config:     config,
+		encryption: encryption,
+	}
+}
+
+// Append assigns the next sequence of the receiver's inbox to the message and stores it
+func (s *InboxService) Append(message *models.ChatMessage) error {
+	ctx := context.Background()
+	sequence, err := s.redisRepo.Incr(inboxSequencePrefix+message.ReceiverUserID, ctx)
+	if err != nil {
+		return err
+	}
+	message.Sequence = sequence
+
+	entry, err := sealInboxEntry(s.encryption, *message)
+	if err != nil {
+		return err
+	}
+
+	key := inboxPrefix + message.ReceiverUserID
+	if err := s.redisRepo.ZAdd(key, float64(sequence), entry, ctx); err != nil {
+		return err
+	}
+	if err := s.redisRepo.SAdd(inboxReceiversPrefix+message.SenderUserID, message.ReceiverUserID, ctx); err != nil {
+		return err
+	}
+	args := []interface{}{message.EventID, sequence, s.config.MaxMessages, int64(s.config.TTL / time.Second)}
+	if _, err := s.redisRepo.Eval(indexInboxScript, []string{inboxIndexPrefix + message.ReceiverUserID}, args, ctx); err != nil {
+		return err
+	}
+	// Keep only the newest MaxMessages entries
+	s.redisRepo.ZRemRangeByRank(key, 0, -s.config.MaxMessages-1, ctx)
+	s.redisRepo.Expire(key, s.config.TTL, ctx)
+	s.redisRepo.Expire(inboxSequencePrefix+message.ReceiverUserID, s.config.TTL, ctx)
+	return nil
+}
+
+// Since returns the messages stored after the given sequence, oldest first. Messages
+// whose data key was shredded are left out.
+func (s *InboxService) Since(userID string, sequence int64) ([]models.ChatMessage, error) {
+	entries, err := s.redisRepo.ZRangeByScore(inboxPrefix+userID, "("+strconv.FormatInt(sequence, 10), "+inf", context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]models.ChatMessage, 0, len(entries))
+	for _, entry := range entries {
+		message, _, err := openInboxEntry(s.encryption, userID, entry)
+		if err != nil {
+			log.Printf("Skipping unreadable inbox entry for user %s: %v", userID, err)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Missed resolves a resume request into the messages the client has not seen yet.
+// An event id that has already fallen out of the inbox replays everything retained.
+func (s *InboxService) Missed(userID string, resume ResumeRequest) ([]models.ChatMessage, error) {
+	if resume.LastEventID == "" {
+		return s.Since(userID, resume.LastSequence)
+	}
+
+	if sequence, err := s.sequenceOf(userID, resume.LastEventID); err == nil {
+		return s.Since(userID, sequence)
+	}
+
+	messages, err := s.Since(userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Event %s no longer in inbox of user %s, replaying %d retained messages", resume.LastEventID, userID, len(messages))
+	return messages, nil
+}
+
+// Find returns a message still stored in a user's inbox
+func (s *InboxService) Find(userID, eventID string) (*models.ChatMessage, error) {
+	sequence, err := s.sequenceOf(userID, eventID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	bound := strconv.FormatInt(sequence, 10)
+	entries, err := s.redisRepo.ZRangeByScore(inboxPrefix+userID, bound, bound, context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		message, _, err := openInboxEntry(s.encryption, userID, entry)
+		if err != nil {
+			log.Printf("Skipping unreadable inbox entry for user %s: %v", userID, err)
+			continue
+		}
+		if message.EventID == eventID {
+			return &message, nil
+		}
+	}
+	return nil, ErrMessageNotFound
+}
+
+// sequenceOf looks up the inbox sequence of an event. The entry itself may have been
+// trimmed since.
+func (s *InboxService) sequenceOf(userID, eventID string) (int64, error) {
+	value, err := s.redisRepo.HGet(inboxIndexPrefix+userID, eventID, context.Background())
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Remove deletes the messages of a user's inbox that match, returning the removed ones
+func (s *InboxService) Remove(userID string, match func(models.ChatMessage) bool) ([]models.ChatMessage, error) {
+	messages, err := s.Since(userID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []models.ChatMessage
+	for _, message := range messages {
+		if !match(message) {
+			continue
+		}
+		sequence := strconv.FormatInt(message.Sequence, 10)
+		if err := s.redisRepo.ZRemRangeByScore(inboxPrefix+userID, sequence, sequence, context.Background()); err != nil {
+			return removed, err
+		}
+		s.redisRepo.HDel(inboxIndexPrefix+userID, message.EventID, context.Background())
+		removed = append(removed, message)
+	}
+	return removed, nil
+}
+
+// Receivers returns the users whose inbox may hold messages from a sender
+func (s *InboxService) Receivers(senderID string) ([]string, error) {
+	return s.redisRepo.SMembers(inboxReceiversPrefix+senderID, context.Background())
+}
+
+// MarkRead remembers that the user has read a message, so it is left out of digests
+func (s *InboxService) MarkRead(userID, eventID string) error {
+	ctx := context.Background()
+	if err := s.redisRepo.SAdd(inboxReadPrefix+userID, eventID, ctx); err != nil {
+		return err
+	}
+	return s.redisRepo.Expire(inboxReadPrefix+userID, s.config.TTL, ctx)
+}
+
+// Unread returns the stored messages after the given sequence that the user has not read
+func (s *InboxService) Unread(userID string, sequence int64) ([]models.ChatMessage, error) {
+	messages, err := s.Since(userID, sequence)
+	if err != nil {
+		return nil, err
+	}
+	read, err := s.redisRepo.SMembers(inboxReadPrefix+userID, context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	readEvents := make(map[string]bool, len(read))
+	for _, eventID := range read {
+		readEvents[eventID] = true
+	}
+	unread := messages[:0]
+	for _, message := range messages {
+		if !readEvents[message.EventID] {
+			unread = append(unread, message)
+		}
+	}
+	return unread, nil
+}
+
+// inboxOwner returns the user whose inbox a key is, false for the other inbox:* keys
+func inboxOwner(key string) (string, bool) {
+	for _, prefix := range []string{inboxSequencePrefix, inboxReadPrefix, inboxReceiversPrefix, inboxIndexPrefix} {
+		if strings.HasPrefix(key, prefix) {
+			return "", false
+		}
+	}
+	return strings.TrimPrefix(key, inboxPrefix), strings.HasPrefix(key, inboxPrefix)
+}
+
+// sealInboxEntry encrypts a message for the inbox of its receiver
+func sealInboxEntry(encryption *EncryptionService, message models.ChatMessage) ([]byte, error) {
+	sealed, err := encryption.SealJson(message.Tenant, inboxPrefix+message.ReceiverUserID, message)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(storedInboxEntry{Sealed: sealed})
+}
+
+// openInboxEntry decodes an entry of a user's inbox, returning its sealed form when it has one
+func openInboxEntry(encryption *EncryptionService, userID, entry string) (models.ChatMessage, *models.SealedData, error) {
+	var message models.ChatMessage
+	stored := storedInboxEntry{ChatMessage: &message}
+	if err := json.Unmarshal([]byte(entry), &stored); err != nil {
+		return message, nil, err
+	}
+	if stored.Sealed == nil {
+		return message, nil, nil
+	}
+	err := encryption.OpenJson(stored.Sealed, inboxPrefix+userID, &message)
+	return message, stored.Sealed, err
+}
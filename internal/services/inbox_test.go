@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/models"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestInboxFindAndMissed(t *testing.T) {
+	_, redisRepo := testRedis(t)
+	config := InboxConfigFromEnv()
+	config.MaxMessages = 3
+	inbox := NewInboxService(redisRepo, config, testEncryption(t, redisRepo))
+	for i := 1; i <= 10; i++ {
+		message := &models.ChatMessage{EventID: fmt.Sprintf("event-%d", i), SenderUserID: "alice", ReceiverUserID: "bob", Message: "hi"}
+		if err := inbox.Append(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if message, err := inbox.Find("bob", "event-9"); err != nil || message.Sequence != 9 {
+		t.Fatalf("Find(event-9) = %+v, %v, want sequence 9", message, err)
+	}
+	if _, err := inbox.Find("bob", "event-2"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Find(trimmed event) error = %v, want ErrMessageNotFound", err)
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        int64 // Sequence of the first replayed message
+	}{
+		{name: "retained event", lastEventID: "event-8", want: 9},
+		{name: "trimmed event", lastEventID: "event-1", want: 8},
+		{name: "unknown event", lastEventID: "event-x", want: 8},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			missed, err := inbox.Missed("bob", ResumeRequest{LastEventID: test.lastEventID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(missed) == 0 || missed[0].Sequence != test.want || missed[len(missed)-1].Sequence != 10 {
+				t.Errorf("Missed(%s) = %+v, want %d through 10", test.lastEventID, missed, test.want)
+			}
+		})
+	}
+
+	// Index fields of trimmed sequences are pruned once it holds twice the retained messages
+	index, err := redisRepo.Eval("return redis.call('HLEN', KEYS[1])", []string{inboxIndexPrefix + "bob"}, nil, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.(int64) > 2*config.MaxMessages {
+		t.Errorf("index holds %d events, want at most %d", index, 2*config.MaxMessages)
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	keysDevicePrefix  = "keys:device:"
+	keysPreKeysPrefix = "keys:prekeys:"
+	keysDevicesPrefix = "keys:devices:" // Set of the device IDs of a user
+
+	maxOneTimePreKeys = 1000 // Per device, older ones are dropped
+)
+
+var (
+	ErrDeviceKeysNotFound = errors.New("no keys published for this device")
+	ErrUnsignedPreKey     = errors.New("signed_pre_key needs a signature")
+	ErrInvalidDeviceID    = errors.New("device id can't contain ':'")
+)
+
+// KeyDirectoryService stores the public keys devices publish for end-to-end encryption,
+// following the X3DH model: an identity key, a signed prekey and one-time prekeys that
+// are handed out once each. Private keys never reach the server.
+type KeyDirectoryService struct {
+	redisRepo redis.IRedisRepositories
+}
+
+func NewKeyDirectoryService(redisRepo redis.IRedisRepositories) *KeyDirectoryService {
+	return &KeyDirectoryService{redisRepo: redisRepo}
+}
+
+// Publish stores a device's identity and signed prekey and adds its one-time prekeys.
+// A new identity key invalidates the one-time prekeys made for the old one.
+func (s *KeyDirectoryService) Publish(userID, deviceID string, request dtos.PublishKeysDto) (*models.DeviceKeys, error) {
+	if request.SignedPreKey.Signature == "" {
+		return nil, ErrUnsignedPreKey
+	}
+	// The device ID ends the Redis keys, so user "alice" with device "x:y" would share
+	// the keys of user "alice:x" with device "y"
+	if strings.Contains(deviceID, ":") {
+		return nil, ErrInvalidDeviceID
+	}
+	ctx := context.Background()
+
+	if previous, err := s.deviceKeys(userID, deviceID); err == nil && previous.IdentityKey != request.IdentityKey {
+		log.Printf("Device %s of user %s published a new identity key", deviceID, userID)
+		s.redisRepo.Del(preKeysKey(userID, deviceID), ctx)
+	}
+
+	keys := &models.DeviceKeys{
+		UserID:       userID,
+		DeviceID:     deviceID,
+		IdentityKey:  request.IdentityKey,
+		SignedPreKey: toPreKey(request.SignedPreKey),
+		UpdatedAt:    time.Now().UTC(),
+	}
+	keysJson, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.Set(deviceKeysKey(userID, deviceID), keysJson, 0, ctx); err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.SAdd(keysDevicesPrefix+userID, deviceID, ctx); err != nil {
+		return nil, err
+	}
+
+	if keys.OneTimePreKeys, err = s.addPreKeys(userID, deviceID, request.OneTimePreKeys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// AddPreKeys uploads more one-time prekeys for a published device, returning how many it has
+func (s *KeyDirectoryService) AddPreKeys(userID, deviceID string, preKeys []dtos.PreKeyDto) (int64, error) {
+	if _, err := s.deviceKeys(userID, deviceID); err != nil {
+		return 0, err
+	}
+	return s.addPreKeys(userID, deviceID, preKeys)
+}
+
+// DeviceKeys returns what a device has published, with its remaining one-time prekeys
+func (s *KeyDirectoryService) DeviceKeys(userID, deviceID string) (*models.DeviceKeys, error) {
+	keys, err := s.deviceKeys(userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	keys.OneTimePreKeys, _ = s.redisRepo.LLen(preKeysKey(userID, deviceID), context.Background())
+	return keys, nil
+}
+
+// RemoveDevice deletes all keys of a device, so peers stop encrypting to it
+func (s *KeyDirectoryService) RemoveDevice(userID, deviceID string) error {
+	if _, err := s.deviceKeys(userID, deviceID); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	s.redisRepo.Del(preKeysKey(userID, deviceID), ctx)
+	if err := s.redisRepo.Del(deviceKeysKey(userID, deviceID), ctx); err != nil {
+		return err
+	}
+	return s.redisRepo.SRem(keysDevicesPrefix+userID, deviceID, ctx)
+}
+
+// Bundles returns a prekey bundle for every device of a user, consuming one one-time
+// prekey of each device that has any left. Devices are read from the user's device set,
+// a key pattern would also match users whose ID extends this one.
+func (s *KeyDirectoryService) Bundles(userID string) ([]models.PreKeyBundle, error) {
+	ctx := context.Background()
+	deviceIDs, err := s.redisRepo.SMembers(keysDevicesPrefix+userID, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bundles := make([]models.PreKeyBundle, 0, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		device, err := s.deviceKeys(userID, deviceID)
+		if err != nil {
+			continue // Removed between SMEMBERS and GET
+		}
+
+		bundle := models.PreKeyBundle{
+			UserID:       userID,
+			DeviceID:     deviceID,
+			IdentityKey:  device.IdentityKey,
+			SignedPreKey: device.SignedPreKey,
+		}
+		if data, err := s.redisRepo.LPop(preKeysKey(userID, deviceID), ctx); err == nil {
+			var preKey models.PreKey
+			if err := json.Unmarshal([]byte(data), &preKey); err == nil {
+				bundle.OneTimePreKey = &preKey
+			}
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
+func (s *KeyDirectoryService) addPreKeys(userID, deviceID string, preKeys []dtos.PreKeyDto) (int64, error) {
+	ctx := context.Background()
+	key := preKeysKey(userID, deviceID)
+	for _, preKey := range preKeys {
+		preKeyJson, err := json.Marshal(toPreKey(preKey))
+		if err != nil {
+			return 0, err
+		}
+		if err := s.redisRepo.LPush(key, preKeyJson, ctx); err != nil {
+			return 0, err
+		}
+	}
+	s.redisRepo.LTrim(key, 0, maxOneTimePreKeys-1, ctx)
+	return s.redisRepo.LLen(key, ctx)
+}
+
+func (s *KeyDirectoryService) deviceKeys(userID, deviceID string) (*models.DeviceKeys, error) {
+	data, err := s.redisRepo.Get(deviceKeysKey(userID, deviceID), context.Background())
+	if err != nil {
+		return nil, ErrDeviceKeysNotFound
+	}
+	var keys models.DeviceKeys
+	if err := json.Unmarshal([]byte(data), &keys); err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
+
+func toPreKey(preKey dtos.PreKeyDto) models.PreKey {
+	return models.PreKey{KeyID: preKey.KeyID, PublicKey: preKey.PublicKey, Signature: preKey.Signature}
+}
+
+func deviceKeysKey(userID, deviceID string) string {
+	return keysDevicePrefix + userID + ":" + deviceID
+}
+
+func preKeysKey(userID, deviceID string) string {
+	return keysPreKeysPrefix + userID + ":" + deviceID
+}
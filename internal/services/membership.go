@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/redis"
+	"errors"
+	"log"
+)
+
+const (
+	chatMembersPrefix = "chat:members:"
+	chatTrafficPrefix = "chat:traffic:" // Set once a group chat carried a message
+)
+
+var (
+	ErrNotChatMember  = errors.New("not a member of this chat")
+	ErrDirectChat     = errors.New("direct chats have no members")
+	ErrChatHasTraffic = errors.New("chat has no members left and can't be created again")
+)
+
+// MembershipService keeps the member list of group chats, used for fan-out
+type MembershipService struct {
+	redisRepo redis.IRedisRepositories
+}
+
+func NewMembershipService(redisRepo redis.IRedisRepositories) *MembershipService {
+	return &MembershipService{redisRepo: redisRepo}
+}
+
+func (s *MembershipService) AddMember(chatID, userID string) error {
+	if err := s.redisRepo.SAdd(chatMembersPrefix+chatID, userID, context.Background()); err != nil {
+		return err
+	}
+	log.Printf("User %s joined chat %s", userID, chatID)
+	return nil
+}
+
+func (s *MembershipService) RemoveMember(chatID, userID string) error {
+	if err := s.redisRepo.SRem(chatMembersPrefix+chatID, userID, context.Background()); err != nil {
+		return err
+	}
+	log.Printf("User %s left chat %s", userID, chatID)
+	return nil
+}
+
+func (s *MembershipService) Members(chatID string) ([]string, error) {
+	return s.redisRepo.SMembers(chatMembersPrefix+chatID, context.Background())
+}
+
+func (s *MembershipService) IsMember(chatID, userID string) (bool, error) {
+	return s.redisRepo.SIsMember(chatMembersPrefix+chatID, userID, context.Background())
+}
+
+// claimChatScript lets ARGV[1] add members to the chat whose members are in KEYS[1]. A
+// chat without members that never carried traffic (KEYS[2]) is created with ARGV[1] as
+// its first member, in the same step, so two users can't both create it. Returns 1 when
+// it created the chat, 0 for a member, -1 for a non-member and -2 for a used chat.
+const claimChatScript = `
+if redis.call('SCARD', KEYS[1]) > 0 then
+	if redis.call('SISMEMBER', KEYS[1], ARGV[1]) == 1 then return 0 end
+	return -1
+end
+if redis.call('EXISTS', KEYS[2]) == 1 then return -2 end
+redis.call('SADD', KEYS[1], ARGV[1])
+return 1
+`
+
+// Invite adds a user to a group chat on behalf of a member. Inviting into a chat
+// without members creates it with the inviter and the invitee.
+func (s *MembershipService) Invite(chatID, inviterID, userID string) error {
+	if _, err := s.claim(chatID, inviterID); err != nil {
+		return err
+	}
+	return s.AddMember(chatID, userID)
+}
+
+// Join adds a user to a group chat they are already a member of, or creates a chat
+// without members with them alone, reporting whether it did the latter
+func (s *MembershipService) Join(chatID, userID string) (bool, error) {
+	return s.claim(chatID, userID)
+}
+
+// claim checks that a user may add members to a chat, reporting whether the chat is
+// created by it, with the user as its member. Direct chats are never created this way,
+// and neither are group chats that carried messages before all their members left.
+func (s *MembershipService) claim(chatID, userID string) (bool, error) {
+	if models.IsDirectChat(chatID) {
+		return false, ErrDirectChat
+	}
+	result, err := s.redisRepo.Eval(claimChatScript, []string{chatMembersPrefix + chatID, chatTrafficPrefix + chatID}, []interface{}{userID}, context.Background())
+	if err != nil {
+		return false, err
+	}
+	switch code, _ := result.(int64); code {
+	case 1:
+		log.Printf("User %s created chat %s", userID, chatID)
+		return true, nil
+	case 0:
+		return false, nil
+	case -2:
+		return false, ErrChatHasTraffic
+	default:
+		return false, ErrNotChatMember
+	}
+}
+
+// RecordTraffic marks a group chat as used, so it can't be claimed again once empty
+func (s *MembershipService) RecordTraffic(chatID string) error {
+	if models.IsDirectChat(chatID) {
+		return nil
+	}
+	_, err := s.redisRepo.SetNX(chatTrafficPrefix+chatID, []byte("1"), 0, context.Background())
+	return err
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		name        string
+		members     []string
+		traffic     bool
+		chatID      string
+		wantCreated bool
+		wantErr     error
+	}{
+		{name: "empty chat is created", chatID: "team", wantCreated: true},
+		{name: "member joins again", members: []string{"alice"}, chatID: "team"},
+		{name: "non-member is refused", members: []string{"bob"}, chatID: "team", wantErr: ErrNotChatMember},
+		{name: "used chat isn't created again", traffic: true, chatID: "team", wantErr: ErrChatHasTraffic},
+		{name: "direct chat", chatID: "dm:alice:bob", wantErr: ErrDirectChat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, redisRepo := testRedis(t)
+			memberships := NewMembershipService(redisRepo)
+			for _, member := range test.members {
+				if err := memberships.AddMember(test.chatID, member); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.traffic {
+				if err := memberships.RecordTraffic(test.chatID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			created, err := memberships.Join(test.chatID, "alice")
+			if created != test.wantCreated || !errors.Is(err, test.wantErr) {
+				t.Fatalf("Join() = %v, %v, want %v, %v", created, err, test.wantCreated, test.wantErr)
+			}
+			isMember, _ := memberships.IsMember(test.chatID, "alice")
+			if isMember != (test.wantErr == nil) {
+				t.Errorf("IsMember() = %v after Join() = %v", isMember, err)
+			}
+		})
+	}
+}
+
+func TestJoinRace(t *testing.T) {
+	_, redisRepo := testRedis(t)
+	memberships := NewMembershipService(redisRepo)
+
+	users := []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi"}
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	creators := 0
+	for _, user := range users {
+		wg.Add(1)
+		go func(user string) {
+			defer wg.Done()
+			created, err := memberships.Join("team", user)
+			if err != nil && !errors.Is(err, ErrNotChatMember) {
+				t.Error(err)
+			}
+			if created {
+				mutex.Lock()
+				creators++
+				mutex.Unlock()
+			}
+		}(user)
+	}
+	wg.Wait()
+
+	members, err := memberships.Members("team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creators != 1 || len(members) != 1 {
+		t.Errorf("%d users created the chat and it has members %v, want one", creators, members)
+	}
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/utils"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	moderationRulesPrefix = "moderation:rules:"
+	moderationHitsKey     = "moderation:hits"
+
+	maxCachedPipelines = 10000 // Expired entries are pruned past this, one per active chat
+)
+
+var (
+	ErrModerationRulesNotFound = errors.New("no moderation rules for this scope")
+	ErrInvalidModerationRule   = errors.New("invalid moderation rule")
+	moderationActions          = []string{constants.ModerationActionRedact, constants.ModerationActionFlag, constants.ModerationActionReject}
+)
+
+type ModerationConfig struct {
+	CacheTTL time.Duration // How long rules are cached between Redis reads, so edits reach every server within it
+	MaxHits  int64         // Hits kept for review, older ones are dropped
+}
+
+// ModerationConfigFromEnv builds the moderation configuration from MODERATION_* environment variables
+func ModerationConfigFromEnv() *ModerationConfig {
+	return &ModerationConfig{
+		CacheTTL: utils.GetEnvDuration("MODERATION_CACHE_TTL", 10*time.Second),
+		MaxHits:  int64(utils.GetEnvInt("MODERATION_MAX_HITS", 10000)),
+	}
+}
+
+// moderationPipeline is a rule set compiled into filters, cached per scope
+type moderationPipeline struct {
+	rules            []models.ModerationRule
+	filters          []ModerationFilter
+	rejectCiphertext bool
+	loadedAt         time.Time
+}
+
+// ModerationService runs messages through the filter rules of their chat, or of the
+// sender's tenant when the chat has none, before they are published
+type ModerationService struct {
+	redisRepo  redis.IRedisRepositories
+	config     *ModerationConfig
+	encryption *EncryptionService
+
+	mutex     sync.RWMutex
+	pipelines map[string]*moderationPipeline
+}
+
+func NewModerationService(redisRepo redis.IRedisRepositories, config *ModerationConfig, encryption *EncryptionService) *ModerationService {
+	return &ModerationService{
+		redisRepo:  redisRepo,
+		config:     config,
+		encryption: encryption,
+		pipelines:  make(map[string]*moderationPipeline),
+	}
+}
+
+// Moderate returns the text to deliver, with redacted matches masked. A rejected message
+// returns a *PolicyError. Ciphertext can't be read: it passes unchanged, unless the rules
+// that apply reject it.
+func (s *ModerationService) Moderate(sender Sender, chatID, receiverUserID, messageType, text string) (string, error) {
+	if text == "" {
+		return text, nil
+	}
+
+	scope, scopeID, pipeline := s.resolve(sender.Tenant, chatID)
+	if pipeline == nil {
+		return text, nil
+	}
+	if messageType == constants.MessageTypeCiphertext {
+		if pipeline.rejectCiphertext {
+			log.Printf("Rejected ciphertext by %s in chat %s, %s %s is moderated", sender.UserID, chatID, scope, scopeID)
+			return "", deny(constants.DenyModerated, "end-to-end encrypted messages are not allowed here, messages are moderated")
+		}
+		return text, nil
+	}
+
+	var hits []models.ModerationHit
+	flagged := false
+	for i, filter := range pipeline.filters {
+		rule := pipeline.rules[i]
+		redacted, matches := filter.Redact(text)
+		if matches == 0 {
+			continue
+		}
+		hits = append(hits, models.ModerationHit{
+			ID:             uuid.New().String(),
+			OccurredAt:     time.Now().UTC(),
+			Scope:          scope,
+			ScopeID:        scopeID,
+			Rule:           rule.Name,
+			Filter:         rule.Filter,
+			Action:         rule.Action,
+			Matches:        matches,
+			SenderUserID:   sender.UserID,
+			ChatID:         chatID,
+			ReceiverUserID: receiverUserID,
+			Tenant:         sender.Tenant,
+		})
+
+		switch rule.Action {
+		case constants.ModerationActionReject:
+			s.recordHits(hits, "")
+			log.Printf("Rejected message by %s in chat %s, rule %q", sender.UserID, chatID, ruleLabel(rule))
+			return "", deny(constants.DenyModerated, "message rejected by moderation rule %q", ruleLabel(rule))
+		case constants.ModerationActionRedact:
+			text = redacted
+		case constants.ModerationActionFlag:
+			flagged = true
+		}
+	}
+
+	if flagged {
+		s.recordHits(hits, text)
+	} else {
+		s.recordHits(hits, "")
+	}
+	return text, nil
+}
+
+// GetRules returns the rules of a tenant or chat
+func (s *ModerationService) GetRules(scope, id string) (*models.ModerationRules, error) {
+	data, err := s.redisRepo.Get(moderationRulesKey(scope, id), context.Background())
+	if err != nil {
+		return nil, ErrModerationRulesNotFound
+	}
+	var rules models.ModerationRules
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// SetRules replaces the rules of a tenant or chat, after checking every rule compiles
+func (s *ModerationService) SetRules(scope, id string, request dtos.ModerationRulesDto) (*models.ModerationRules, error) {
+	if _, err := compileModerationRules(request.Rules); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidModerationRule, err)
+	}
+
+	rules := &models.ModerationRules{Scope: scope, ID: id, Rules: request.Rules, RejectCiphertext: request.RejectCiphertext, UpdatedAt: time.Now().UTC()}
+	rulesJson, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.Set(moderationRulesKey(scope, id), rulesJson, 0, context.Background()); err != nil {
+		return nil, err
+	}
+	s.invalidateCache(scope, id)
+	log.Printf("Moderation rules of %s %s updated, %d rules", scope, id, len(rules.Rules))
+	return rules, nil
+}
+
+// DeleteRules removes the rules of a tenant or chat, so the next scope applies
+func (s *ModerationService) DeleteRules(scope, id string) error {
+	key := moderationRulesKey(scope, id)
+	if _, err := s.redisRepo.Get(key, context.Background()); err != nil {
+		return ErrModerationRulesNotFound
+	}
+	if err := s.redisRepo.Del(key, context.Background()); err != nil {
+		return err
+	}
+	s.invalidateCache(scope, id)
+	log.Printf("Moderation rules of %s %s deleted", scope, id)
+	return nil
+}
+
+// Hits returns the most recent hits, newest first, optionally only those of one chat
+func (s *ModerationService) Hits(chatID string, limit int64) ([]models.ModerationHit, error) {
+	entries, err := s.redisRepo.LRange(moderationHitsKey, 0, s.config.MaxHits-1, context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]models.ModerationHit, 0)
+	for _, entry := range entries {
+		var hit models.ModerationHit
+		if err := json.Unmarshal([]byte(entry), &hit); err != nil {
+			continue
+		}
+		if chatID != "" && hit.ChatID != chatID {
+			continue
+		}
+		if err := openHitMessage(s.encryption, &hit); err != nil {
+			log.Printf("Message of moderation hit %s is unreadable: %v", hit.ID, err)
+			hit.SealedMessage = nil
+		}
+		hits = append(hits, hit)
+		if int64(len(hits)) >= limit {
+			break
+		}
+	}
+	return hits, nil
+}
+
+// resolve finds the pipeline that applies: the chat's, else the sender's tenant's, else the default tenant's
+func (s *ModerationService) resolve(tenant, chatID string) (string, string, *moderationPipeline) {
+	if tenant == "" {
+		tenant = constants.ModerationDefaultTenant
+	}
+	candidates := [][2]string{
+		{constants.ModerationScopeChat, chatID},
+		{constants.ModerationScopeTenant, tenant},
+		{constants.ModerationScopeTenant, constants.ModerationDefaultTenant},
+	}
+	for _, candidate := range candidates {
+		if candidate[1] == "" {
+			continue
+		}
+		if pipeline := s.cachedPipeline(candidate[0], candidate[1]); len(pipeline.filters) > 0 || pipeline.rejectCiphertext {
+			return candidate[0], candidate[1], pipeline
+		}
+	}
+	return "", "", nil
+}
+
+// cachedPipeline returns the compiled rules of a scope, empty when it has none.
+// Missing rules are cached too, so chats without rules don't cost a Redis read per message.
+func (s *ModerationService) cachedPipeline(scope, id string) *moderationPipeline {
+	key := moderationRulesKey(scope, id)
+	s.mutex.RLock()
+	pipeline, ok := s.pipelines[key]
+	s.mutex.RUnlock()
+	if ok && time.Since(pipeline.loadedAt) < s.config.CacheTTL {
+		return pipeline
+	}
+
+	pipeline = &moderationPipeline{loadedAt: time.Now()}
+	rules, err := s.GetRules(scope, id)
+	if err == nil {
+		filters, err := compileModerationRules(rules.Rules)
+		if err != nil {
+			log.Printf("Ignoring invalid moderation rules of %s %s: %v", scope, id, err)
+		} else {
+			pipeline.rules = rules.Rules
+			pipeline.filters = filters
+			pipeline.rejectCiphertext = rules.RejectCiphertext
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.pipelines) >= maxCachedPipelines {
+		for cachedKey, cached := range s.pipelines {
+			if time.Since(cached.loadedAt) >= s.config.CacheTTL {
+				delete(s.pipelines, cachedKey)
+			}
+		}
+	}
+	s.pipelines[key] = pipeline
+	return pipeline
+}
+
+func (s *ModerationService) invalidateCache(scope, id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.pipelines, moderationRulesKey(scope, id))
+}
+
+// recordHits stores hits for review. message is kept only on flagged messages, encrypted.
+func (s *ModerationService) recordHits(hits []models.ModerationHit, message string) {
+	ctx := context.Background()
+	for _, hit := range hits {
+		if hit.Action == constants.ModerationActionFlag {
+			hit.Message = message
+			if err := sealHitMessage(s.encryption, &hit); err != nil {
+				log.Printf("Error encrypting message of moderation hit %s, keeping the hit without it: %v", hit.ID, err)
+				hit.Message = ""
+			}
+		}
+		hitJson, err := json.Marshal(hit)
+		if err != nil {
+			continue
+		}
+		if err := s.redisRepo.LPush(moderationHitsKey, hitJson, ctx); err != nil {
+			log.Println("Error recording moderation hit:", err)
+			return
+		}
+	}
+	if len(hits) > 0 {
+		s.redisRepo.LTrim(moderationHitsKey, 0, s.config.MaxHits-1, ctx)
+	}
+}
+
+// sealHitMessage replaces a hit's message with its encrypted form
+func sealHitMessage(encryption *EncryptionService, hit *models.ModerationHit) error {
+	if hit.Message == "" {
+		return nil
+	}
+	sealed, err := encryption.Seal(hit.Tenant, moderationHitsKey+":"+hit.ID, []byte(hit.Message))
+	if err != nil {
+		return err
+	}
+	hit.Message, hit.SealedMessage = "", sealed
+	return nil
+}
+
+// openHitMessage decrypts a hit's stored message into Message
+func openHitMessage(encryption *EncryptionService, hit *models.ModerationHit) error {
+	if hit.SealedMessage == nil {
+		return nil
+	}
+	message, err := encryption.Open(hit.SealedMessage, moderationHitsKey+":"+hit.ID)
+	if err != nil {
+		return err
+	}
+	hit.Message, hit.SealedMessage = string(message), nil
+	return nil
+}
+
+func compileModerationRules(rules []models.ModerationRule) ([]ModerationFilter, error) {
+	filters := make([]ModerationFilter, 0, len(rules))
+	for i, rule := range rules {
+		if !slices.Contains(moderationActions, rule.Action) {
+			return nil, fmt.Errorf("rule %d: unknown action %q", i, rule.Action)
+		}
+		filter, err := moderationFilter(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func ruleLabel(rule models.ModerationRule) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return rule.Filter
+}
+
+func moderationRulesKey(scope, id string) string {
+	return moderationRulesPrefix + scope + ":" + id
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ModerationFilter finds matches in a message. Redact returns the text with every match
+// masked and how many matches there were; a filter that can't mask returns the text as is.
+type ModerationFilter interface {
+	Redact(text string) (string, int)
+}
+
+// ModerationFilterFactory builds a filter from a rule, failing on an invalid rule
+type ModerationFilterFactory func(rule models.ModerationRule) (ModerationFilter, error)
+
+var (
+	moderationFiltersMutex sync.RWMutex
+	moderationFilters      = map[string]ModerationFilterFactory{
+		constants.ModerationFilterProfanity: newProfanityFilter,
+		constants.ModerationFilterLink:      newLinkFilter,
+		constants.ModerationFilterRegex:     newRegexFilter,
+	}
+)
+
+// RegisterModerationFilter makes a custom filter available to rules under the given name
+func RegisterModerationFilter(name string, factory ModerationFilterFactory) {
+	moderationFiltersMutex.Lock()
+	defer moderationFiltersMutex.Unlock()
+	moderationFilters[name] = factory
+}
+
+func moderationFilter(rule models.ModerationRule) (ModerationFilter, error) {
+	moderationFiltersMutex.RLock()
+	factory, ok := moderationFilters[rule.Filter]
+	moderationFiltersMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown moderation filter %q", rule.Filter)
+	}
+	return factory(rule)
+}
+
+// patternFilter masks every match of a regular expression
+type patternFilter struct {
+	pattern *regexp.Regexp
+	mask    func(match string) string
+}
+
+func (f *patternFilter) Redact(text string) (string, int) {
+	matches := 0
+	redacted := f.pattern.ReplaceAllStringFunc(text, func(match string) string {
+		matches++
+		return f.mask(match)
+	})
+	return redacted, matches
+}
+
+// newProfanityFilter matches the rule's words as whole words, ignoring case, and masks
+// them with asterisks of the same length
+func newProfanityFilter(rule models.ModerationRule) (ModerationFilter, error) {
+	var words []string
+	for _, word := range rule.Words {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, regexp.QuoteMeta(word))
+		}
+	}
+	if len(words) == 0 {
+		return nil, errors.New("profanity filter needs words")
+	}
+	// Longest first, so a word isn't shadowed by a shorter one it starts with
+	sort.SliceStable(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+	return &wordFilter{pattern: regexp.MustCompile(`(?i)(?:` + strings.Join(words, "|") + `)`)}, nil
+}
+
+// wordFilter masks whole-word matches with asterisks. RE2's \b only knows ASCII word
+// characters, so boundaries are checked here and also hold for words like "mälö".
+type wordFilter struct {
+	pattern *regexp.Regexp
+}
+
+func (f *wordFilter) Redact(text string) (string, int) {
+	var redacted strings.Builder
+	matches, last := 0, 0
+	for _, match := range f.pattern.FindAllStringIndex(text, -1) {
+		if !wordBoundary(text, match[0]) || !wordBoundary(text, match[1]) {
+			continue
+		}
+		redacted.WriteString(text[last:match[0]])
+		redacted.WriteString(strings.Repeat("*", utf8.RuneCountInString(text[match[0]:match[1]])))
+		last = match[1]
+		matches++
+	}
+	if matches == 0 {
+		return text, 0
+	}
+	redacted.WriteString(text[last:])
+	return redacted.String(), matches
+}
+
+// wordBoundary reports whether a byte offset of text doesn't split a word
+func wordBoundary(text string, offset int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:offset])
+	after, _ := utf8.DecodeRuneInString(text[offset:])
+	return !isWordRune(before) || !isWordRune(after)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://)?(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}(?::\d+)?(?:[/?#][^\s]*)?`)
+
+// linkFilter masks URLs and bare domains whose host is, or is a subdomain of, a blocked domain
+type linkFilter struct {
+	domains []string
+}
+
+func newLinkFilter(rule models.ModerationRule) (ModerationFilter, error) {
+	var domains []string
+	for _, domain := range rule.Domains {
+		if domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "."); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 {
+		return nil, errors.New("link filter needs domains")
+	}
+	return &linkFilter{domains: domains}, nil
+}
+
+func (f *linkFilter) Redact(text string) (string, int) {
+	matches := 0
+	redacted := linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		if !f.blocked(link) {
+			return link
+		}
+		matches++
+		return "[link removed]"
+	})
+	return redacted, matches
+}
+
+func (f *linkFilter) blocked(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range f.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// newRegexFilter masks matches of any of the rule's patterns
+func newRegexFilter(rule models.ModerationRule) (ModerationFilter, error) {
+	if len(rule.Patterns) == 0 {
+		return nil, errors.New("regex filter needs patterns")
+	}
+	var patterns []string
+	for _, pattern := range rule.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, "(?:"+pattern+")")
+	}
+	return &patternFilter{
+		pattern: regexp.MustCompile(strings.Join(patterns, "|")),
+		mask: func(string) string {
+			return "[redacted]"
+		},
+	}, nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"testing"
+)
+
+func TestModerationFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		rule        models.ModerationRule
+		text        string
+		want        string
+		wantMatches int
+	}{
+		{
+			name: "profanity masks whole words ignoring case",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterProfanity, Words: []string{"darn"}},
+			text: "Darn it, darn",
+			want: "**** it, ****", wantMatches: 2,
+		},
+		{
+			name: "profanity leaves longer words alone",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterProfanity, Words: []string{"heck"}},
+			text: "checked heckle",
+			want: "checked heckle", wantMatches: 0,
+		},
+		{
+			name: "profanity quotes its words",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterProfanity, Words: []string{"a.b", " "}},
+			text: "a.b axb",
+			want: "*** axb", wantMatches: 1,
+		},
+		{
+			name: "profanity masks runes, not bytes",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterProfanity, Words: []string{"mälö"}},
+			text: "so mälö",
+			want: "so ****", wantMatches: 1,
+		},
+		{
+			name: "profanity prefers the longer word",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterProfanity, Words: []string{"ab", "abc"}},
+			text: "abc ab abd",
+			want: "*** ** abd", wantMatches: 2,
+		},
+		{
+			name: "link matches urls of blocked domains",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterLink, Domains: []string{"evil.example"}},
+			text: "see https://evil.example/login?x=1 now",
+			want: "see [link removed] now", wantMatches: 1,
+		},
+		{
+			name: "link matches subdomains and bare domains",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterLink, Domains: []string{" .Evil.Example. "}},
+			text: "login.evil.example and EVIL.EXAMPLE:8080/x",
+			want: "[link removed] and [link removed]", wantMatches: 2,
+		},
+		{
+			name: "link leaves lookalike domains alone",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterLink, Domains: []string{"evil.example"}},
+			text: "notevil.example and evil.example.org",
+			want: "notevil.example and evil.example.org", wantMatches: 0,
+		},
+		{
+			name: "regex masks any of its patterns",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterRegex, Patterns: []string{`\b\d{3}-\d{2}-\d{4}\b`, `(?i)secret`}},
+			text: "ssn 123-45-6789, SECRET",
+			want: "ssn [redacted], [redacted]", wantMatches: 2,
+		},
+		{
+			name: "regex patterns don't capture each other",
+			rule: models.ModerationRule{Filter: constants.ModerationFilterRegex, Patterns: []string{`a|b`, `c`}},
+			text: "abcd",
+			want: "[redacted][redacted][redacted]d", wantMatches: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := moderationFilter(tt.rule)
+			if err != nil {
+				t.Fatalf("moderationFilter() error = %v", err)
+			}
+			got, matches := filter.Redact(tt.text)
+			if got != tt.want || matches != tt.wantMatches {
+				t.Errorf("Redact(%q) = %q, %d, want %q, %d", tt.text, got, matches, tt.want, tt.wantMatches)
+			}
+		})
+	}
+}
+
+func TestModerationFilterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.ModerationRule
+	}{
+		{"unknown filter", models.ModerationRule{Filter: "sentiment", Action: constants.ModerationActionFlag}},
+		{"profanity without words", models.ModerationRule{Filter: constants.ModerationFilterProfanity, Action: constants.ModerationActionRedact, Words: []string{" "}}},
+		{"link without domains", models.ModerationRule{Filter: constants.ModerationFilterLink, Action: constants.ModerationActionRedact}},
+		{"regex without patterns", models.ModerationRule{Filter: constants.ModerationFilterRegex, Action: constants.ModerationActionRedact}},
+		{"invalid regex", models.ModerationRule{Filter: constants.ModerationFilterRegex, Action: constants.ModerationActionRedact, Patterns: []string{"("}}},
+		{"unknown action", models.ModerationRule{Filter: constants.ModerationFilterProfanity, Action: "ban", Words: []string{"darn"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := compileModerationRules([]models.ModerationRule{tt.rule}); err == nil {
+				t.Errorf("compileModerationRules(%+v) accepted an invalid rule", tt.rule)
+			}
+		})
+	}
+}
+
+type upperFilter struct{}
+
+func (upperFilter) Redact(text string) (string, int) {
+	if text == "shout" {
+		return "SHOUT", 1
+	}
+	return text, 0
+}
+
+func TestRegisterModerationFilter(t *testing.T) {
+	RegisterModerationFilter("test-upper", func(models.ModerationRule) (ModerationFilter, error) {
+		return upperFilter{}, nil
+	})
+	filters, err := compileModerationRules([]models.ModerationRule{{Filter: "test-upper", Action: constants.ModerationActionRedact}})
+	if err != nil {
+		t.Fatalf("compileModerationRules() error = %v", err)
+	}
+	if got, matches := filters[0].Redact("shout"); got != "SHOUT" || matches != 1 {
+		t.Errorf("Redact() = %q, %d", got, matches)
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/utils"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Sender is the authenticated author of a message
+type Sender struct {
+	UserID string
+	Roles  []string // From the token claims, or assigned by the server for bots
+	Tenant string   // From the token claims, empty for the default tenant
+}
+
+// SendRequest describes a message about to be published, as seen by a Policy
+type SendRequest struct {
+	Sender         Sender `json:"sender"`
+	Action         string `json:"action"` // message.send or bot.invoke
+	ChatID         string `json:"chat_id"`
+	ReceiverUserID string `json:"receiver_user_id,omitempty"` // Empty for a send to a whole group chat
+	MessageType    string `json:"message_type"`
+}
+
+// PolicyError is a denied send. Code is machine readable and sent to the client.
+type PolicyError struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+func deny(code, format string, args ...interface{}) *PolicyError {
+	return &PolicyError{Code: code, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Policy decides whether a message may be sent. It returns nil to allow, a *PolicyError
+// to deny, and any other error when it could not decide (which also denies).
+type Policy interface {
+	AuthorizeSend(request SendRequest) error
+}
+
+// PolicyChain allows a send only when every policy allows it, checked in order
+type PolicyChain []Policy
+
+func (c PolicyChain) AuthorizeSend(request SendRequest) error {
+	for _, policy := range c {
+		if err := policy.AuthorizeSend(request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type PolicyConfig struct {
+	DefaultRole     string              // Role of users whose token carries none
+	RolePermissions map[string][]string // Actions each role may perform, "*" allows everything
+	URL             string              // External policy engine consulted after the built-in rules
+	Timeout         time.Duration       // Timeout of a call to the external policy engine
+}
+
+// PolicyConfigFromEnv builds the policy configuration from POLICY_* environment variables
+func PolicyConfigFromEnv() *PolicyConfig {
+	return &PolicyConfig{
+		DefaultRole:     utils.GetEnvString("POLICY_DEFAULT_ROLE", constants.RoleUser),
+		RolePermissions: parseRolePermissions(utils.GetEnvString("POLICY_ROLE_PERMISSIONS", "user=message.send,bot.invoke;bot=message.send;service=message.send;admin=*")),
+		URL:             os.Getenv("POLICY_URL"),
+		Timeout:         utils.GetEnvDuration("POLICY_TIMEOUT", 2*time.Second),
+	}
+}
+
+// parseRolePermissions reads "role=action,action;role=action"
+func parseRolePermissions(value string) map[string][]string {
+	permissions := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		role, actions, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || role == "" {
+			if entry != "" {
+				log.Printf("Ignoring malformed role permissions %q", entry)
+			}
+			continue
+		}
+		permissions[role] = []string{}
+		for _, action := range strings.Split(actions, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				permissions[role] = append(permissions[role], action)
+			}
+		}
+	}
+	return permissions
+}
+
+// MembershipPolicy requires sender and receiver to be members of group chats. Direct
+// chats have no members, only the two users their ID is derived from may use one.
+type MembershipPolicy struct {
+	memberships *MembershipService
+}
+
+func NewMembershipPolicy(memberships *MembershipService) *MembershipPolicy {
+	return &MembershipPolicy{memberships: memberships}
+}
+
+func (p *MembershipPolicy) AuthorizeSend(request SendRequest) error {
+	if models.IsDirectChat(request.ChatID) {
+		if request.ReceiverUserID == "" || request.ChatID != models.DirectChatID(request.Sender.UserID, request.ReceiverUserID) {
+			return deny(constants.DenyNotMember, "chat %s is not a direct chat of yours with %s", request.ChatID, request.ReceiverUserID)
+		}
+		return nil
+	}
+
+	members, err := p.memberships.Members(request.ChatID)
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 && request.ReceiverUserID != "" {
+		return deny(constants.DenyNotMember, "chat %s has no members, direct messages go to %s", request.ChatID, models.DirectChatID(request.Sender.UserID, request.ReceiverUserID))
+	}
+	if len(members) == 0 {
+		return deny(constants.DenyNotMember, "chat %s has no members", request.ChatID)
+	}
+	if !slices.Contains(members, request.Sender.UserID) {
+		return deny(constants.DenyNotMember, "you are not a member of chat %s", request.ChatID)
+	}
+	if request.ReceiverUserID != "" && !slices.Contains(members, request.ReceiverUserID) {
+		return deny(constants.DenyNotMember, "%s is not a member of chat %s", request.ReceiverUserID, request.ChatID)
+	}
+	return nil
+}
+
+// BlockListPolicy drops messages to receivers who have blocked the sender
+type BlockListPolicy struct {
+	blockService *BlockService
+}
+
+func NewBlockListPolicy(blockService *BlockService) *BlockListPolicy {
+	return &BlockListPolicy{blockService: blockService}
+}
+
+func (p *BlockListPolicy) AuthorizeSend(request SendRequest) error {
+	if request.ReceiverUserID == "" {
+		return nil
+	}
+	blocked, err := p.blockService.IsBlocked(request.ReceiverUserID, request.Sender.UserID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return deny(constants.DenyBlocked, "%s does not accept messages from you", request.ReceiverUserID)
+	}
+	return nil
+}
+
+// RolePolicy allows an action when any of the sender's roles grants it
+type RolePolicy struct {
+	config *PolicyConfig
+}
+
+func NewRolePolicy(config *PolicyConfig) *RolePolicy {
+	return &RolePolicy{config: config}
+}
+
+func (p *RolePolicy) AuthorizeSend(request SendRequest) error {
+	roles := request.Sender.Roles
+	if len(roles) == 0 {
+		roles = []string{p.config.DefaultRole}
+	}
+	for _, role := range roles {
+		permissions := p.config.RolePermissions[role]
+		if slices.Contains(permissions, "*") || slices.Contains(permissions, request.Action) {
+			return nil
+		}
+	}
+	return deny(constants.DenyForbidden, "your role does not permit %s", request.Action)
+}
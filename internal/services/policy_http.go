@@ -0,0 +1,66 @@
+package services
+
+import (
+	"bytes"
+	"distributed-chat-system/internal/constants"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// HTTPPolicy delegates decisions to an external policy engine. It POSTs the SendRequest
+// as JSON and expects {"allow": bool, "code": "...", "reason": "..."} back. When the
+// engine can't be reached the send is denied.
+type HTTPPolicy struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewHTTPPolicy(config *PolicyConfig) *HTTPPolicy {
+	log.Println("🚀 Initialized Policy : HTTP", config.URL)
+	return &HTTPPolicy{
+		url:        config.URL,
+		httpClient: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+type httpPolicyDecision struct {
+	Allow  bool   `json:"allow"`
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+func (p *HTTPPolicy) AuthorizeSend(request SendRequest) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	response, err := p.httpClient.Post(p.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Println("Error calling policy engine:", err)
+		return deny(constants.DenyPolicyUnavailable, "authorization is temporarily unavailable")
+	}
+	defer response.Body.Close()
+
+	var decision httpPolicyDecision
+	if response.StatusCode != http.StatusOK {
+		log.Printf("Policy engine responded with status %d", response.StatusCode)
+		return deny(constants.DenyPolicyUnavailable, "authorization is temporarily unavailable")
+	}
+	if err := json.NewDecoder(response.Body).Decode(&decision); err != nil {
+		return fmt.Errorf("malformed policy decision: %w", err)
+	}
+
+	if decision.Allow {
+		return nil
+	}
+	if decision.Code == "" {
+		decision.Code = constants.DenyForbidden
+	}
+	if decision.Reason == "" {
+		decision.Reason = "message denied by policy"
+	}
+	return &PolicyError{Code: decision.Code, Reason: decision.Reason}
+}
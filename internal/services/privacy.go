@@ -0,0 +1,406 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/utils"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	privacyJobPrefix     = "privacy:job:"
+	privacyArchivePrefix = "privacy:archive:"
+)
+
+var (
+	ErrPrivacyJobNotFound = errors.New("privacy job not found")
+	ErrArchiveNotReady    = errors.New("export has not completed")
+	ErrArchiveExpired     = errors.New("export archive has expired, start a new export")
+)
+
+type PrivacyConfig struct {
+	ArchiveTTL time.Duration // How long export archives can be downloaded
+	JobTTL     time.Duration // How long finished jobs are kept for their progress and counts
+}
+
+// PrivacyConfigFromEnv builds the privacy job configuration from PRIVACY_* environment variables
+func PrivacyConfigFromEnv() *PrivacyConfig {
+	return &PrivacyConfig{
+		ArchiveTTL: utils.GetEnvDuration("PRIVACY_ARCHIVE_TTL", 24*time.Hour),
+		JobTTL:     utils.GetEnvDuration("PRIVACY_JOB_TTL", 30*24*time.Hour),
+	}
+}
+
+// privacyStep is one part of a job, returning how many items it exported or erased
+type privacyStep struct {
+	name string
+	run  func() (int, error)
+}
+
+// PrivacyService exports and erases everything stored about a user, for data subject
+// requests. Jobs run in the background on the server that accepted them, one step at a
+// time, and record their progress in Redis so any server can report it. Erasure is
+// idempotent: a job that failed or died with its server can simply be started again.
+type PrivacyService struct {
+	redisRepo    redis.IRedisRepositories
+	config       *PrivacyConfig
+	inboxService *InboxService
+	chatService  *ChatMessageService
+	audit        *AuditService
+	encryption   *EncryptionService
+}
+
+func NewPrivacyService(redisRepo redis.IRedisRepositories, config *PrivacyConfig, inboxService *InboxService, chatService *ChatMessageService, audit *AuditService, encryption *EncryptionService) *PrivacyService {
+	return &PrivacyService{
+		redisRepo:    redisRepo,
+		config:       config,
+		inboxService: inboxService,
+		chatService:  chatService,
+		audit:        audit,
+		encryption:   encryption,
+	}
+}
+
+// StartExport starts collecting a user's data into a zip archive of JSON files
+func (s *PrivacyService) StartExport(userID, actor string) (*models.PrivacyJob, error) {
+	job := newPrivacyJob(constants.PrivacyJobExport, userID, actor)
+	archive := newPrivacyArchive()
+	steps := []privacyStep{
+		{"profile", func() (int, error) { return s.exportProfile(userID, archive) }},
+		{"messages_received", func() (int, error) { return s.exportInbox(userID, archive) }},
+		{"messages_sent", func() (int, error) { return s.exportSentMessages(userID, archive) }},
+		{"devices", func() (int, error) { return s.exportDevices(userID, archive) }},
+		{"chats", func() (int, error) { return s.exportChats(userID, archive) }},
+		{"reports", func() (int, error) { return s.exportReports(userID, archive) }},
+		{"bot_invocations", func() (int, error) { return s.exportBotInvocations(userID, archive) }},
+		{"webhook_dead_letters", func() (int, error) { return s.exportDeadLetters(userID, archive) }},
+		{"audit", func() (int, error) { return s.exportAudit(userID, archive) }},
+		{"archive", func() (int, error) { return s.storeArchive(job, archive) }},
+	}
+	return s.start(job, steps)
+}
+
+// StartErasure starts deleting a user's data, including the copies of their messages
+// in other users' inboxes. The user's socket is closed first, and their own keys are
+// deleted last, so nothing the closing session writes survives.
+func (s *PrivacyService) StartErasure(userID, actor string) (*models.PrivacyJob, error) {
+	job := newPrivacyJob(constants.PrivacyJobErasure, userID, actor)
+	steps := []privacyStep{
+		{"sessions", func() (int, error) { return s.eraseSessions(userID) }},
+		{"messages_sent", func() (int, error) { return s.eraseSentMessages(userID) }},
+		{"chats", func() (int, error) { return s.eraseMemberships(userID) }},
+		{"blocks", func() (int, error) { return s.eraseBlocks(userID) }},
+		{"reports", func() (int, error) { return s.eraseReports(userID) }},
+		{"moderation_hits", func() (int, error) { return s.eraseModerationHits(userID) }},
+		{"bot_invocations", func() (int, error) { return s.eraseBotInvocations(userID) }},
+		{"webhook_dead_letters", func() (int, error) { return s.eraseDeadLetters(userID) }},
+		{"user_data", func() (int, error) { return s.eraseUserKeys(userID) }},
+	}
+	return s.start(job, steps)
+}
+
+func (s *PrivacyService) Get(jobID string) (*models.PrivacyJob, error) {
+	data, err := s.redisRepo.Get(privacyJobPrefix+jobID, context.Background())
+	if err != nil {
+		return nil, ErrPrivacyJobNotFound
+	}
+	var job models.PrivacyJob
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Archive returns the zip archive of a completed export
+func (s *PrivacyService) Archive(jobID string) ([]byte, *models.PrivacyJob, error) {
+	job, err := s.Get(jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Kind != constants.PrivacyJobExport || job.Status != constants.PrivacyJobCompleted {
+		return nil, job, ErrArchiveNotReady
+	}
+	data, err := s.redisRepo.Get(privacyArchivePrefix+jobID, context.Background())
+	if err != nil {
+		return nil, job, ErrArchiveExpired
+	}
+	return []byte(data), job, nil
+}
+
+func newPrivacyJob(kind, userID, actor string) *models.PrivacyJob {
+	now := time.Now().UTC()
+	return &models.PrivacyJob{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		UserID:    userID,
+		Status:    constants.PrivacyJobPending,
+		Items:     make(map[string]int),
+		Actor:     actor,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// start saves a job and runs it in the background, returning it as it was saved
+func (s *PrivacyService) start(job *models.PrivacyJob, steps []privacyStep) (*models.PrivacyJob, error) {
+	job.StepsTotal = len(steps)
+	if err := s.save(job); err != nil {
+		return nil, err
+	}
+	snapshot := *job
+	snapshot.Items = make(map[string]int)
+
+	log.Printf("Started %s job %s for user %s", job.Kind, job.ID, job.UserID)
+	go s.run(job, steps)
+	return &snapshot, nil
+}
+
+// run executes the steps in order, saving progress after each, and stops at the first failure
+func (s *PrivacyService) run(job *models.PrivacyJob, steps []privacyStep) {
+	job.Status = constants.PrivacyJobRunning
+	for _, step := range steps {
+		job.Step = step.name
+		s.save(job)
+
+		count, err := step.run()
+		if err != nil {
+			job.Status = constants.PrivacyJobFailed
+			job.Error = fmt.Sprintf("%s: %v", step.name, err)
+			s.save(job)
+			log.Printf("%s job %s for user %s failed at %s: %v", job.Kind, job.ID, job.UserID, step.name, err)
+			return
+		}
+		job.Items[step.name] = count
+		job.StepsDone++
+	}
+
+	completedAt := time.Now().UTC()
+	job.Status = constants.PrivacyJobCompleted
+	job.Step = ""
+	job.CompletedAt = &completedAt
+	s.save(job)
+	log.Printf("Completed %s job %s for user %s", job.Kind, job.ID, job.UserID)
+}
+
+func (s *PrivacyService) save(job *models.PrivacyJob) error {
+	job.UpdatedAt = time.Now().UTC()
+	jobJson, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := s.redisRepo.Set(privacyJobPrefix+job.ID, jobJson, s.config.JobTTL, context.Background()); err != nil {
+		log.Printf("Error saving progress of %s job %s: %v", job.Kind, job.ID, err)
+		return err
+	}
+	return nil
+}
+
+// privacyArchive is a zip archive built in memory, one JSON file per kind of data
+type privacyArchive struct {
+	buffer bytes.Buffer
+	writer *zip.Writer
+	files  map[string]int // Items per file, for the manifest
+}
+
+func newPrivacyArchive() *privacyArchive {
+	archive := &privacyArchive{files: make(map[string]int)}
+	archive.writer = zip.NewWriter(&archive.buffer)
+	return archive
+}
+
+func (a *privacyArchive) add(name string, items int, value interface{}) error {
+	file, err := a.writer.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	a.files[name] = items
+	return encoder.Encode(value)
+}
+
+// storeArchive adds the manifest, closes the archive and keeps it for ArchiveTTL
+func (s *PrivacyService) storeArchive(job *models.PrivacyJob, archive *privacyArchive) (int, error) {
+	manifest := map[string]interface{}{
+		"job_id":       job.ID,
+		"user_id":      job.UserID,
+		"generated_at": time.Now().UTC(),
+		"files":        archive.files,
+	}
+	if err := archive.add("manifest.json", len(archive.files), manifest); err != nil {
+		return 0, err
+	}
+	if err := archive.writer.Close(); err != nil {
+		return 0, err
+	}
+	if err := s.redisRepo.Set(privacyArchivePrefix+job.ID, archive.buffer.Bytes(), s.config.ArchiveTTL, context.Background()); err != nil {
+		return 0, err
+	}
+	expiresAt := time.Now().UTC().Add(s.config.ArchiveTTL)
+	job.ArchiveExpiresAt = &expiresAt
+	return len(archive.files), nil
+}
+
+// rawValue returns a key's JSON value as stored, nil when the key doesn't exist
+func (s *PrivacyService) rawValue(key string) json.RawMessage {
+	data, err := s.redisRepo.Get(key, context.Background())
+	if err != nil || !json.Valid([]byte(data)) {
+		return nil
+	}
+	return json.RawMessage(data)
+}
+
+// otherInboxes returns the users, other than userID, whose inbox may hold messages userID sent
+func (s *PrivacyService) otherInboxes(userID string) ([]string, error) {
+	receivers, err := s.inboxService.Receivers(userID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(receivers, func(receiver string) bool { return receiver == userID }), nil
+}
+
+// userKeys returns the keys that belong to a user alone. Push collapse counters are
+// left to expire with the collapse window.
+func (s *PrivacyService) userKeys(userID string) ([]string, error) {
+	keys := []string{
+		inboxPrefix + userID,
+		inboxSequencePrefix + userID,
+		inboxReadPrefix + userID,
+		inboxIndexPrefix + userID,
+		inboxReceiversPrefix + userID,
+		digestPreferencesPrefix + userID,
+		digestStatePrefix + userID,
+		digestOfflinePrefix + userID,
+		digestLockPrefix + userID,
+		pushSettingsPrefix + userID,
+		blockListPrefix + userID,
+		blockedByPrefix + userID,
+		sanctionKey(constants.SanctionMute, userID),
+		sanctionKey(constants.SanctionBan, userID),
+		rateLimitBucketPrefix + constants.RateLimitScopeUser + ":" + userID,
+		rateLimitViolationPrefix + userID,
+	}
+	tokens, deviceIDs, err := s.devices(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		keys = append(keys, pushDeviceKey(userID, token))
+	}
+	for _, deviceID := range deviceIDs {
+		keys = append(keys, deviceKeysKey(userID, deviceID), preKeysKey(userID, deviceID))
+	}
+	// The index sets go last, so an erasure that fails halfway can be retried
+	return append(keys, pushDevicesPrefix+userID, keysDevicesPrefix+userID), nil
+}
+
+// devices returns the user's push device tokens and encryption key device IDs from the
+// per-user index sets. Matching key patterns instead would pick up other users whose
+// ID starts with this one and a colon.
+func (s *PrivacyService) devices(userID string) ([]string, []string, error) {
+	ctx := context.Background()
+	tokens, err := s.redisRepo.SMembers(pushDevicesPrefix+userID, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	deviceIDs, err := s.redisRepo.SMembers(keysDevicesPrefix+userID, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, deviceIDs, nil
+}
+
+// reports returns the reports a user filed or that are about them
+func (s *PrivacyService) reports(userID string) ([]models.AbuseReport, error) {
+	keys, err := s.redisRepo.Keys(reportPrefix+"*", context.Background())
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]models.AbuseReport, 0)
+	for _, key := range keys {
+		var report models.AbuseReport
+		if err := json.Unmarshal(s.rawValue(key), &report); err != nil {
+			continue
+		}
+		if report.ReporterUserID == userID || report.ReportedUserID == userID {
+			openReportMessage(s.encryption, &report)
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+// listEntriesOf returns the entries of a Redis list, as stored, that concern a user
+func (s *PrivacyService) listEntriesOf(key, userID string, users func(entry []byte) []string) ([]string, error) {
+	entries, err := s.redisRepo.LRange(key, 0, -1, context.Background())
+	if err != nil {
+		return nil, err
+	}
+	matching := make([]string, 0)
+	for _, entry := range entries {
+		for _, user := range users([]byte(entry)) {
+			if user == userID {
+				matching = append(matching, entry)
+				break
+			}
+		}
+	}
+	return matching, nil
+}
+
+func moderationHitUsers(entry []byte) []string {
+	var hit models.ModerationHit
+	if json.Unmarshal(entry, &hit) != nil {
+		return nil
+	}
+	return []string{hit.SenderUserID, hit.ReceiverUserID}
+}
+
+// deadLetterUsers reads the users out of a dead-lettered message or lifecycle event
+func deadLetterUsers(entry []byte) []string {
+	var deadLetter struct {
+		Payload struct {
+			Data struct {
+				SenderUserID   string `json:"sender_user_id"`
+				ReceiverUserID string `json:"receiver_user_id"`
+				UserID         string `json:"user_id"`
+				ActorUserID    string `json:"actor_user_id"`
+			} `json:"data"`
+		} `json:"payload"`
+	}
+	if json.Unmarshal(entry, &deadLetter) != nil {
+		return nil
+	}
+	data := deadLetter.Payload.Data
+	return []string{data.SenderUserID, data.ReceiverUserID, data.UserID, data.ActorUserID}
+}
+
+// botInvocationsOf returns the stored bot invocations a user sent or received, by key
+func (s *PrivacyService) botInvocationsOf(userID string) (map[string]models.BotInvocation, error) {
+	keys, err := s.redisRepo.Keys(botInvocationPrefix+"*", context.Background())
+	if err != nil {
+		return nil, err
+	}
+	invocations := make(map[string]models.BotInvocation)
+	for _, key := range keys {
+		var invocation models.BotInvocation
+		if err := json.Unmarshal(s.rawValue(key), &invocation); err != nil {
+			continue
+		}
+		if invocation.SenderUserID == userID || invocation.ReceiverUserID == userID {
+			invocations[key] = invocation
+		}
+	}
+	return invocations, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"errors"
+	"log"
+)
+
+// erasedReason is shown to clients told to drop a message whose sender was erased
+const erasedReason = "the sender's data was erased"
+
+// eraseSessions closes the user's socket and removes their registry entry
+func (s *PrivacyService) eraseSessions(userID string) (int, error) {
+	if s.chatService.LookupUserChatServer(userID) == nil {
+		return 0, nil
+	}
+	if _, err := s.chatService.ForceDisconnect(userID, "account data erased"); err != nil && !errors.Is(err, ErrUserNotConnected) {
+		return 0, err
+	}
+	// The owning server removes the entry as the socket closes; don't wait for it
+	return 1, s.redisRepo.Del(userID, context.Background())
+}
+
+// eraseSentMessages removes the user's messages from every other inbox, in direct and
+// group chats alike, and tells connected receivers to drop them
+func (s *PrivacyService) eraseSentMessages(userID string) (int, error) {
+	receivers, err := s.otherInboxes(userID)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, receiver := range receivers {
+		copies, err := s.inboxService.Remove(receiver, func(message models.ChatMessage) bool {
+			return message.SenderUserID == userID
+		})
+		removed += len(copies)
+		if err != nil {
+			return removed, err
+		}
+		for _, removedCopy := range copies {
+			s.chatService.NotifyModeration(receiver, constants.ReportActionDeleteMessage, erasedReason, removedCopy.ChatID, removedCopy.EventID, nil)
+		}
+	}
+	return removed, nil
+}
+
+// eraseMemberships removes the user from every group chat
+func (s *PrivacyService) eraseMemberships(userID string) (int, error) {
+	chats, err := s.memberships(userID)
+	if err != nil {
+		return 0, err
+	}
+	for i, chatID := range chats {
+		if err := s.redisRepo.SRem(chatMembersPrefix+chatID, userID, context.Background()); err != nil {
+			return i, err
+		}
+	}
+	return len(chats), nil
+}
+
+// eraseBlocks removes the user from other users' block lists, and from the index of
+// the users they blocked
+func (s *PrivacyService) eraseBlocks(userID string) (int, error) {
+	ctx := context.Background()
+	blocked, err := s.redisRepo.SMembers(blockListPrefix+userID, ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, blockedUserID := range blocked {
+		if err := s.redisRepo.SRem(blockedByPrefix+blockedUserID, userID, ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	blockers, err := s.redisRepo.SMembers(blockedByPrefix+userID, ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, blocker := range blockers {
+		if err := s.redisRepo.SRem(blockListPrefix+blocker, userID, ctx); err != nil {
+			return i, err
+		}
+	}
+	return len(blockers), nil
+}
+
+// eraseReports deletes the reports the user filed or that are about them, with the
+// message copies they hold
+func (s *PrivacyService) eraseReports(userID string) (int, error) {
+	reports, err := s.reports(userID)
+	if err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	for i, report := range reports {
+		s.redisRepo.SRem(reportStatusPrefix+report.Status, report.ID, ctx)
+		if err := s.redisRepo.Del(reportPrefix+report.ID, ctx); err != nil {
+			return i, err
+		}
+	}
+	return len(reports), nil
+}
+
+// eraseModerationHits removes the hits on messages the user sent or received. Entries
+// are removed by value, so hits recorded meanwhile are kept.
+func (s *PrivacyService) eraseModerationHits(userID string) (int, error) {
+	return s.removeListEntries(moderationHitsKey, userID, moderationHitUsers)
+}
+
+func (s *PrivacyService) eraseBotInvocations(userID string) (int, error) {
+	invocations, err := s.botInvocationsOf(userID)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for key := range invocations {
+		if err := s.redisRepo.Del(key, context.Background()); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (s *PrivacyService) eraseDeadLetters(userID string) (int, error) {
+	return s.removeListEntries(webhookDeadLetterKey, userID, deadLetterUsers)
+}
+
+// eraseUserKeys deletes everything kept under the user's own keys: inbox, read
+// receipts, settings, devices, encryption keys, block list, sanctions and counters
+func (s *PrivacyService) eraseUserKeys(userID string) (int, error) {
+	keys, err := s.userKeys(userID)
+	if err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	erased := 0
+	for _, key := range keys {
+		// Redis reports -2 for keys that don't exist
+		if ttl, err := s.redisRepo.TTL(key, ctx); err == nil && ttl == -2 {
+			continue
+		}
+		if err := s.redisRepo.Del(key, ctx); err != nil {
+			return erased, err
+		}
+		erased++
+	}
+	log.Printf("Erased %d keys of user %s", erased, userID)
+	return erased, nil
+}
+
+func (s *PrivacyService) removeListEntries(key, userID string, users func(entry []byte) []string) (int, error) {
+	entries, err := s.listEntriesOf(key, userID, users)
+	if err != nil {
+		return 0, err
+	}
+	for i, entry := range entries {
+		if err := s.redisRepo.LRem(key, 0, entry, context.Background()); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"encoding/json"
+	"strings"
+)
+
+// exportProfile collects the user's settings, sanctions and where they are connected
+func (s *PrivacyService) exportProfile(userID string, archive *privacyArchive) (int, error) {
+	sanctions := make([]json.RawMessage, 0)
+	for _, kind := range []string{constants.SanctionMute, constants.SanctionBan} {
+		if sanction := s.rawValue(sanctionKey(kind, userID)); sanction != nil {
+			sanctions = append(sanctions, sanction)
+		}
+	}
+	profile := map[string]interface{}{
+		"user_id":               userID,
+		"connected_to_server":   s.chatService.LookupUserChatServer(userID),
+		"digest_preferences":    s.rawValue(digestPreferencesPrefix + userID),
+		"digest_state":          s.rawValue(digestStatePrefix + userID),
+		"notification_settings": s.rawValue(pushSettingsPrefix + userID),
+		"sanctions":             sanctions,
+	}
+	return 1, archive.add("profile.json", 1, profile)
+}
+
+// exportInbox collects the messages the user received and which of them they read
+func (s *PrivacyService) exportInbox(userID string, archive *privacyArchive) (int, error) {
+	messages, err := s.inboxService.Since(userID, 0)
+	if err != nil {
+		return 0, err
+	}
+	read, err := s.redisRepo.SMembers(inboxReadPrefix+userID, context.Background())
+	if err != nil {
+		return 0, err
+	}
+	if err := archive.add("messages_received.json", len(messages), messages); err != nil {
+		return 0, err
+	}
+	return len(messages), archive.add("read_receipts.json", len(read), read)
+}
+
+// exportSentMessages collects the copies of the user's messages in other users' inboxes
+func (s *PrivacyService) exportSentMessages(userID string, archive *privacyArchive) (int, error) {
+	receivers, err := s.otherInboxes(userID)
+	if err != nil {
+		return 0, err
+	}
+	sent := make([]models.ChatMessage, 0)
+	for _, receiver := range receivers {
+		messages, err := s.inboxService.Since(receiver, 0)
+		if err != nil {
+			return 0, err
+		}
+		for _, message := range messages {
+			if message.SenderUserID == userID {
+				sent = append(sent, message)
+			}
+		}
+	}
+	return len(sent), archive.add("messages_sent.json", len(sent), sent)
+}
+
+// exportDevices collects the user's push devices and published encryption keys
+func (s *PrivacyService) exportDevices(userID string, archive *privacyArchive) (int, error) {
+	ctx := context.Background()
+	tokens, deviceIDs, err := s.devices(userID)
+	if err != nil {
+		return 0, err
+	}
+	devices := make([]json.RawMessage, 0, len(tokens))
+	for _, token := range tokens {
+		if device := s.rawValue(pushDeviceKey(userID, token)); device != nil {
+			devices = append(devices, device)
+		}
+	}
+
+	encryptionKeys := make([]map[string]interface{}, 0, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		key := deviceKeysKey(userID, deviceID)
+		preKeys, err := s.redisRepo.LRange(preKeysKey(userID, deviceID), 0, -1, ctx)
+		if err != nil {
+			return 0, err
+		}
+		oneTimePreKeys := make([]json.RawMessage, 0, len(preKeys))
+		for _, preKey := range preKeys {
+			oneTimePreKeys = append(oneTimePreKeys, json.RawMessage(preKey))
+		}
+		encryptionKeys = append(encryptionKeys, map[string]interface{}{
+			"device":            s.rawValue(key),
+			"one_time_pre_keys": oneTimePreKeys,
+		})
+	}
+
+	if err := archive.add("push_devices.json", len(devices), devices); err != nil {
+		return 0, err
+	}
+	return len(devices) + len(encryptionKeys), archive.add("encryption_keys.json", len(encryptionKeys), encryptionKeys)
+}
+
+// exportChats collects the group chats the user is a member of and the users they blocked
+func (s *PrivacyService) exportChats(userID string, archive *privacyArchive) (int, error) {
+	chats, err := s.memberships(userID)
+	if err != nil {
+		return 0, err
+	}
+	blocked, err := s.redisRepo.SMembers(blockListPrefix+userID, context.Background())
+	if err != nil {
+		return 0, err
+	}
+	if err := archive.add("chats.json", len(chats), chats); err != nil {
+		return 0, err
+	}
+	return len(chats) + len(blocked), archive.add("blocks.json", len(blocked), blocked)
+}
+
+// exportReports collects reports by or about the user, and moderation hits on their messages
+func (s *PrivacyService) exportReports(userID string, archive *privacyArchive) (int, error) {
+	reports, err := s.reports(userID)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := s.listEntriesOf(moderationHitsKey, userID, moderationHitUsers)
+	if err != nil {
+		return 0, err
+	}
+	hits := make([]models.ModerationHit, 0, len(entries))
+	for _, entry := range entries {
+		var hit models.ModerationHit
+		if err := json.Unmarshal([]byte(entry), &hit); err != nil {
+			continue
+		}
+		if err := openHitMessage(s.encryption, &hit); err != nil {
+			hit.SealedMessage = nil
+		}
+		hits = append(hits, hit)
+	}
+	if err := archive.add("reports.json", len(reports), reports); err != nil {
+		return 0, err
+	}
+	return len(reports) + len(hits), archive.add("moderation_hits.json", len(hits), hits)
+}
+
+func (s *PrivacyService) exportBotInvocations(userID string, archive *privacyArchive) (int, error) {
+	invocations, err := s.botInvocationsOf(userID)
+	if err != nil {
+		return 0, err
+	}
+	list := make([]models.BotInvocation, 0, len(invocations))
+	for _, invocation := range invocations {
+		list = append(list, invocation)
+	}
+	return len(list), archive.add("bot_invocations.json", len(list), list)
+}
+
+// exportDeadLetters collects webhook deliveries about the user that were never delivered
+func (s *PrivacyService) exportDeadLetters(userID string, archive *privacyArchive) (int, error) {
+	entries, err := s.listEntriesOf(webhookDeadLetterKey, userID, deadLetterUsers)
+	if err != nil {
+		return 0, err
+	}
+	deadLetters := make([]models.WebhookDeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		var deadLetter models.WebhookDeadLetter
+		if err := json.Unmarshal([]byte(entry), &deadLetter); err != nil {
+			continue
+		}
+		if err := openDeadLetterMessage(s.encryption, &deadLetter); err != nil {
+			deadLetter.SealedMessage = nil // Shredded, the text is gone
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	return len(deadLetters), archive.add("webhook_dead_letters.json", len(deadLetters), deadLetters)
+}
+
+// exportAudit collects the audit records naming the user as actor or target
+func (s *PrivacyService) exportAudit(userID string, archive *privacyArchive) (int, error) {
+	records := make([]models.AuditRecord, 0)
+	err := s.audit.Export(AuditFilter{}, func(record models.AuditRecord) error {
+		if record.Actor == userID || record.Target == userID || record.Details["on_behalf_of"] == userID {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(records), archive.add("audit.json", len(records), records)
+}
+
+// memberships returns the group chats a user is a member of
+func (s *PrivacyService) memberships(userID string) ([]string, error) {
+	ctx := context.Background()
+	keys, err := s.redisRepo.Keys(chatMembersPrefix+"*", ctx)
+	if err != nil {
+		return nil, err
+	}
+	chats := make([]string, 0)
+	for _, key := range keys {
+		if member, err := s.redisRepo.SIsMember(key, userID, ctx); err == nil && member {
+			chats = append(chats, strings.TrimPrefix(key, chatMembersPrefix))
+		}
+	}
+	return chats, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestEraseThroughIndexes(t *testing.T) {
+	chat := newTestChat(t)
+	encryption := testEncryption(t, chat.redisRepo)
+	privacy := NewPrivacyService(chat.redisRepo, PrivacyConfigFromEnv(), chat.inbox, chat.ChatMessageService, nil, encryption)
+	blocks := NewBlockService(chat.redisRepo)
+
+	for _, message := range []struct{ sender, receiver string }{{"mallory", "alice"}, {"mallory", "bob"}, {"alice", "bob"}} {
+		if _, err := chat.SendMessageToUser(Sender{UserID: message.sender}, dtos.ChatMessageDto{
+			ChatID: models.DirectChatID(message.sender, message.receiver), ReceiverUserID: message.receiver, MessageType: constants.MessageTypeText, Message: "hi",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, block := range []struct{ user, blocked string }{{"alice", "mallory"}, {"bob", "mallory"}, {"mallory", "carol"}} {
+		if err := blocks.Block(block.user, block.blocked); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if removed, err := privacy.eraseSentMessages("mallory"); err != nil || removed != 2 {
+		t.Fatalf("eraseSentMessages() = %d, %v, want 2 copies", removed, err)
+	}
+	for user, want := range map[string]int{"alice": 0, "bob": 1} {
+		if messages := chat.inboxOf(t, user); len(messages) != want {
+			t.Errorf("inbox of %s = %+v, want %d messages", user, messages, want)
+		}
+	}
+
+	if removed, err := privacy.eraseBlocks("mallory"); err != nil || removed != 2 {
+		t.Fatalf("eraseBlocks() = %d, %v, want 2 lists", removed, err)
+	}
+	for _, user := range []string{"alice", "bob"} {
+		if blocked, _ := blocks.IsBlocked(user, "mallory"); blocked {
+			t.Errorf("%s still blocks mallory", user)
+		}
+	}
+	if blockers, _ := chat.redisRepo.SMembers(blockedByPrefix+"carol", context.Background()); len(blockers) != 0 {
+		t.Errorf("carol is still indexed as blocked by %v", blockers)
+	}
+
+	keys, err := privacy.userKeys("mallory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{inboxReceiversPrefix, blockedByPrefix} {
+		found := false
+		for _, key := range keys {
+			found = found || strings.HasPrefix(key, prefix)
+		}
+		if !found {
+			t.Errorf("userKeys() = %v, missing the %s index", keys, prefix)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/utils"
+	"distributed-chat-system/pkg/push"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	pushDevicePrefix   = "push:device:"
+	pushDevicesPrefix  = "push:devices:" // Set of the device tokens of a user
+	pushSettingsPrefix = "push:settings:"
+	pushPendingPrefix  = "push:pending:"
+)
+
+var ErrDeviceNotFound = errors.New("device not registered")
+
+type PushConfig struct {
+	CollapseWindow time.Duration // Pushes for one chat within this window are collapsed into a running count
+	Timeout        time.Duration // Timeout of pushing to all devices of a user
+}
+
+// PushConfigFromEnv builds the push configuration from PUSH_* environment variables
+func PushConfigFromEnv() *PushConfig {
+	return &PushConfig{
+		CollapseWindow: utils.GetEnvDuration("PUSH_COLLAPSE_WINDOW", 10*time.Minute),
+		Timeout:        utils.GetEnvDuration("PUSH_TIMEOUT", 10*time.Second),
+	}
+}
+
+// PushService notifies the mobile devices of users who are offline when a message arrives
+type PushService struct {
+	redisRepo redis.IRedisRepositories
+	notifier  push.PushNotifier
+	config    *PushConfig
+}
+
+func NewPushService(redisRepo redis.IRedisRepositories, notifier push.PushNotifier, config *PushConfig) *PushService {
+	return &PushService{
+		redisRepo: redisRepo,
+		notifier:  notifier,
+		config:    config,
+	}
+}
+
+// RegisterDevice stores a device token of a user, replacing an earlier registration of the same token
+func (s *PushService) RegisterDevice(userID string, request dtos.RegisterDeviceDto) (*models.Device, error) {
+	device := &models.Device{
+		UserID:       userID,
+		Token:        request.Token,
+		Platform:     request.Platform,
+		RegisteredAt: time.Now().UTC(),
+	}
+	deviceJson, err := json.Marshal(device)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.Set(pushDeviceKey(userID, device.Token), deviceJson, 0, context.Background()); err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.SAdd(pushDevicesPrefix+userID, device.Token, context.Background()); err != nil {
+		return nil, err
+	}
+	log.Printf("Registered %s device for user %s", device.Platform, userID)
+	return device, nil
+}
+
+// ListDevices returns the devices registered by a user. They are read from the user's
+// token set, a key pattern would also match users whose ID extends this one.
+func (s *PushService) ListDevices(userID string) ([]models.Device, error) {
+	tokens, err := s.redisRepo.SMembers(pushDevicesPrefix+userID, context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]models.Device, 0, len(tokens))
+	for _, token := range tokens {
+		key := pushDeviceKey(userID, token)
+		data, err := s.redisRepo.Get(key, context.Background())
+		if err != nil {
+			continue // Unregistered between SMEMBERS and GET
+		}
+		var device models.Device
+		if err := json.Unmarshal([]byte(data), &device); err != nil {
+			log.Printf("Skipping malformed device %s: %v", key, err)
+			continue
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// UnregisterDevice removes a device token of a user
+func (s *PushService) UnregisterDevice(userID, token string) error {
+	key := pushDeviceKey(userID, token)
+	if _, err := s.redisRepo.Get(key, context.Background()); err != nil {
+		return ErrDeviceNotFound
+	}
+	return s.removeDevice(userID, token, context.Background())
+}
+
+// removeDevice deletes a device and takes its token out of the user's set
+func (s *PushService) removeDevice(userID, token string, ctx context.Context) error {
+	if err := s.redisRepo.Del(pushDeviceKey(userID, token), ctx); err != nil {
+		return err
+	}
+	return s.redisRepo.SRem(pushDevicesPrefix+userID, token, ctx)
+}
+
+// GetSettings returns a user's notification settings, the defaults when never set
+func (s *PushService) GetSettings(userID string) (*models.NotificationSettings, error) {
+	data, err := s.redisRepo.Get(pushSettingsPrefix+userID, context.Background())
+	if err != nil {
+		return &models.NotificationSettings{UserID: userID, MutedChats: []string{}}, nil
+	}
+	var settings models.NotificationSettings
+	if err := json.Unmarshal([]byte(data), &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpdateSettings replaces a user's muted chats and do-not-disturb settings
+func (s *PushService) UpdateSettings(userID string, request dtos.UpdateNotificationSettingsDto) (*models.NotificationSettings, error) {
+	settings := &models.NotificationSettings{
+		UserID:            userID,
+		MutedChats:        request.MutedChats,
+		DoNotDisturb:      request.DoNotDisturb,
+		DoNotDisturbUntil: request.DoNotDisturbUntil,
+		UpdatedAt:         time.Now().UTC(),
+	}
+	if settings.MutedChats == nil {
+		settings.MutedChats = []string{}
+	}
+	settingsJson, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.Set(pushSettingsPrefix+userID, settingsJson, 0, context.Background()); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// NotifyOffline pushes a message to the devices of its receiver, who has no socket on any
+// server. Pushes of one chat share a collapse key and count the messages that arrived
+// within the collapse window, so the device shows one up-to-date notification per chat.
+func (s *PushService) NotifyOffline(message models.ChatMessage) {
+	if message.MessageType == constants.MessageTypeBotInvocation {
+		return
+	}
+
+	settings, err := s.GetSettings(message.ReceiverUserID)
+	if err != nil {
+		log.Printf("Error loading notification settings of user %s: %v", message.ReceiverUserID, err)
+		return
+	}
+	if suppressed(settings, message.ChatID, time.Now()) {
+		return
+	}
+
+	devices, err := s.ListDevices(message.ReceiverUserID)
+	if err != nil || len(devices) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+
+	pendingKey := pushPendingPrefix + message.ReceiverUserID + ":" + message.ChatID
+	pending, err := s.redisRepo.Incr(pendingKey, ctx)
+	if err != nil {
+		pending = 1
+	}
+	s.redisRepo.Expire(pendingKey, s.config.CollapseWindow, ctx)
+
+	body := message.SenderUserID + ": " + excerpt(message.Message)
+	if pending > 1 {
+		body = fmt.Sprintf("%d new messages", pending)
+	}
+
+	for _, device := range devices {
+		err := s.notifier.Push(ctx, push.Notification{
+			Token:       device.Token,
+			Platform:    device.Platform,
+			Title:       message.ChatID,
+			Body:        body,
+			CollapseKey: message.ChatID,
+			Badge:       int(pending),
+			Data: map[string]string{
+				"chat_id":        message.ChatID,
+				"event_id":       message.EventID,
+				"sender_user_id": message.SenderUserID,
+				"sequence":       strconv.FormatInt(message.Sequence, 10),
+			},
+		})
+		if errors.Is(err, push.ErrInvalidToken) {
+			log.Printf("Removing stale %s device of user %s", device.Platform, device.UserID)
+			s.removeDevice(device.UserID, device.Token, ctx)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error pushing to %s device of user %s: %v", device.Platform, device.UserID, err)
+		}
+	}
+}
+
+// suppressed reports whether the settings silence pushes for a chat at the given time
+func suppressed(settings *models.NotificationSettings, chatID string, now time.Time) bool {
+	if settings.DoNotDisturb {
+		return true
+	}
+	if settings.DoNotDisturbUntil != nil && now.Before(*settings.DoNotDisturbUntil) {
+		return true
+	}
+	return slices.Contains(settings.MutedChats, chatID)
+}
+
+func pushDeviceKey(userID, token string) string {
+	return pushDevicePrefix + userID + ":" + token
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/utils"
+	"distributed-chat-system/pkg/redis"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	rateLimitBucketPrefix    = "ratelimit:bucket:"
+	rateLimitViolationPrefix = "ratelimit:violations:"
+)
+
+// tokenBucketScript takes a token from each bucket in KEYS, or from none of them. Bucket
+// i holds at most ARGV[2i-1] tokens and gets one token back every ARGV[2i] milliseconds.
+// It returns 0 when the tokens were taken, otherwise the position of the first empty
+// bucket and how many milliseconds until every bucket has a token again. Redis' clock
+// is used so every server refills at the same pace.
+const tokenBucketScript = `
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000 + math.floor(tonumber(clock[2]) / 1000)
+
+local tokens = {}
+local denied, retry = 0, 0
+for i, key in ipairs(KEYS) do
+	local capacity = tonumber(ARGV[2 * i - 1])
+	local interval = tonumber(ARGV[2 * i])
+	local bucket = redis.call('HMGET', key, 'tokens', 'updated')
+	local updated = tonumber(bucket[2]) or now
+	tokens[i] = math.min(capacity, (tonumber(bucket[1]) or capacity) + math.max(0, now - updated) / interval)
+	if tokens[i] < 1 then
+		if denied == 0 then denied = i end
+		retry = math.max(retry, math.ceil((1 - tokens[i]) * interval))
+	end
+end
+
+for i, key in ipairs(KEYS) do
+	if denied == 0 then tokens[i] = tokens[i] - 1 end
+	redis.call('HSET', key, 'tokens', tostring(tokens[i]), 'updated', tostring(now))
+	redis.call('PEXPIRE', key, tostring(math.ceil(tonumber(ARGV[2 * i - 1]) * tonumber(ARGV[2 * i])) + 1000))
+end
+return {denied, retry}
+`
+
+// RateLimit is a token bucket: bursts of up to Burst frames, then one frame per Refill
+type RateLimit struct {
+	Burst  int
+	Refill time.Duration
+}
+
+type RateLimitConfig struct {
+	Enabled         bool
+	Connection      RateLimit     // Every frame read from one socket
+	User            RateLimit     // Messages of one user, on every server together
+	Chat            RateLimit     // Messages into one chat
+	MaxViolations   int           // Rejections within ViolationWindow before the user is disconnected
+	ViolationWindow time.Duration // Window over which rejections are counted
+}
+
+// RateLimitConfigFromEnv builds the rate limit configuration from RATE_LIMIT_* environment variables
+func RateLimitConfigFromEnv() *RateLimitConfig {
+	return &RateLimitConfig{
+		Enabled: utils.GetEnvBool("RATE_LIMIT_ENABLED", true),
+		Connection: RateLimit{
+			Burst:  utils.GetEnvInt("RATE_LIMIT_CONNECTION_BURST", 20),
+			Refill: utils.GetEnvDuration("RATE_LIMIT_CONNECTION_REFILL", 100*time.Millisecond),
+		},
+		User: RateLimit{
+			Burst:  utils.GetEnvInt("RATE_LIMIT_USER_BURST", 10),
+			Refill: utils.GetEnvDuration("RATE_LIMIT_USER_REFILL", 500*time.Millisecond),
+		},
+		Chat: RateLimit{
+			Burst:  utils.GetEnvInt("RATE_LIMIT_CHAT_BURST", 50),
+			Refill: utils.GetEnvDuration("RATE_LIMIT_CHAT_REFILL", 100*time.Millisecond),
+		},
+		MaxViolations:   utils.GetEnvInt("RATE_LIMIT_MAX_VIOLATIONS", 20),
+		ViolationWindow: utils.GetEnvDuration("RATE_LIMIT_VIOLATION_WINDOW", time.Minute),
+	}
+}
+
+// RateLimitError is a frame rejected by a rate limit
+type RateLimitError struct {
+	Scope      string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limit exceeded, retry in %s", e.Scope, e.RetryAfter)
+}
+
+// RateLimitService enforces token buckets kept in Redis, so a user can't escape a limit
+// by reconnecting to another server. When Redis is unreachable frames are let through.
+type RateLimitService struct {
+	redisRepo redis.IRedisRepositories
+	config    *RateLimitConfig
+}
+
+func NewRateLimitService(redisRepo redis.IRedisRepositories, config *RateLimitConfig) *RateLimitService {
+	if !config.Enabled {
+		log.Println("⚠️ Rate limiting is disabled")
+	}
+	return &RateLimitService{redisRepo: redisRepo, config: config}
+}
+
+// AllowFrame takes a token for a frame read from a connection
+func (s *RateLimitService) AllowFrame(connectionID string) error {
+	return s.take(rateLimitBucket{constants.RateLimitScopeConnection, connectionID, s.config.Connection})
+}
+
+// AllowMessage takes a token from the sender's and the chat's buckets, or from neither
+// when one of them is empty
+func (s *RateLimitService) AllowMessage(userID, chatID string) error {
+	return s.take(
+		rateLimitBucket{constants.RateLimitScopeUser, userID, s.config.User},
+		rateLimitBucket{constants.RateLimitScopeChat, chatID, s.config.Chat},
+	)
+}
+
+// RecordViolation counts a rejected frame of a user, reporting whether the user has
+// exceeded limits often enough within the window to be disconnected. Chat limits are
+// not counted, since other members' traffic fills them.
+func (s *RateLimitService) RecordViolation(userID string, limitErr *RateLimitError) bool {
+	if limitErr.Scope == constants.RateLimitScopeChat {
+		return false
+	}
+	ctx := context.Background()
+	key := rateLimitViolationPrefix + userID
+	violations, err := s.redisRepo.Incr(key, ctx)
+	if err != nil {
+		log.Printf("Error counting rate limit violations of user %s: %v", userID, err)
+		return false
+	}
+	if violations == 1 {
+		s.redisRepo.Expire(key, s.config.ViolationWindow, ctx)
+	}
+	if violations < int64(s.config.MaxViolations) {
+		return false
+	}
+	s.redisRepo.Del(key, ctx)
+	log.Printf("User %s exceeded rate limits %d times within %s", userID, violations, s.config.ViolationWindow)
+	return true
+}
+
+type rateLimitBucket struct {
+	scope string
+	id    string
+	limit RateLimit
+}
+
+func (s *RateLimitService) take(buckets ...rateLimitBucket) error {
+	if !s.config.Enabled {
+		return nil
+	}
+
+	keys := make([]string, 0, len(buckets))
+	args := make([]interface{}, 0, 2*len(buckets))
+	checked := make([]rateLimitBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		if bucket.limit.Burst <= 0 || bucket.limit.Refill <= 0 {
+			continue
+		}
+		keys = append(keys, rateLimitBucketPrefix+bucket.scope+":"+bucket.id)
+		args = append(args, bucket.limit.Burst, bucket.limit.Refill.Milliseconds())
+		checked = append(checked, bucket)
+	}
+	if len(checked) == 0 {
+		return nil
+	}
+
+	result, err := s.redisRepo.Eval(tokenBucketScript, keys, args, context.Background())
+	if err != nil {
+		log.Printf("Error checking rate limits %v: %v", keys, err)
+		return nil
+	}
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		log.Printf("Unexpected rate limit result %v", result)
+		return nil
+	}
+	denied, _ := values[0].(int64)
+	if denied < 1 || denied > int64(len(checked)) {
+		return nil
+	}
+	retryAfter, _ := values[1].(int64)
+	return &RateLimitError{Scope: checked[denied-1].scope, RetryAfter: time.Duration(retryAfter) * time.Millisecond}
+}
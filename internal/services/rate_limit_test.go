@@ -0,0 +1,73 @@
+package services
+
+import (
+	"distributed-chat-system/internal/constants"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAllowMessage(t *testing.T) {
+	type send struct{ user, chat string }
+	tests := []struct {
+		name           string
+		before         []send // Sends that fill a bucket
+		denied         send
+		wantScope      string
+		after          []send // Sends that pass only if the denied one took no token
+		wantDisconnect bool
+	}{
+		{
+			name:      "chat bucket denial keeps the user's tokens and isn't a violation",
+			before:    []send{{"alice", "team"}},
+			denied:    send{"bob", "team"},
+			wantScope: constants.RateLimitScopeChat,
+			after:     []send{{"bob", "other"}, {"bob", "another"}},
+		},
+		{
+			name:           "user bucket denial keeps the chat's token and is a violation",
+			before:         []send{{"alice", "one"}, {"alice", "two"}},
+			denied:         send{"alice", "team"},
+			wantScope:      constants.RateLimitScopeUser,
+			after:          []send{{"bob", "team"}},
+			wantDisconnect: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, redisRepo := testRedis(t)
+			limiter := NewRateLimitService(redisRepo, &RateLimitConfig{
+				Enabled:         true,
+				User:            RateLimit{Burst: 2, Refill: time.Hour},
+				Chat:            RateLimit{Burst: 1, Refill: time.Hour},
+				MaxViolations:   1,
+				ViolationWindow: time.Minute,
+			})
+
+			for _, send := range test.before {
+				if err := limiter.AllowMessage(send.user, send.chat); err != nil {
+					t.Fatalf("AllowMessage(%s, %s) = %v, want nil", send.user, send.chat, err)
+				}
+			}
+
+			var limitErr *RateLimitError
+			if err := limiter.AllowMessage(test.denied.user, test.denied.chat); !errors.As(err, &limitErr) || limitErr.Scope != test.wantScope {
+				t.Fatalf("AllowMessage(%s, %s) = %v, want a %s limit", test.denied.user, test.denied.chat, err, test.wantScope)
+			}
+			if limitErr.RetryAfter <= 0 {
+				t.Errorf("RetryAfter = %s, want a wait", limitErr.RetryAfter)
+			}
+
+			for _, send := range test.after {
+				if err := limiter.AllowMessage(send.user, send.chat); err != nil {
+					t.Errorf("AllowMessage(%s, %s) = %v, the denied send took a token", send.user, send.chat, err)
+				}
+			}
+
+			if disconnect := limiter.RecordViolation(test.denied.user, limitErr); disconnect != test.wantDisconnect {
+				t.Errorf("RecordViolation() = %v, want %v", disconnect, test.wantDisconnect)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	reportPrefix       = "reports:report:"
+	reportStatusPrefix = "reports:status:" // Set of report ids per status
+)
+
+var (
+	ErrReportNotFound    = errors.New("report not found")
+	ErrInvalidReport     = errors.New("report an event_id you received or a reported_user_id other than yourself")
+	ErrReportTransition  = errors.New("report can't move to that status")
+	ErrReportDismissed   = errors.New("report was dismissed, reopen it first")
+	ErrNoReportedMessage = errors.New("report is not about a message")
+	ErrInvalidDuration   = errors.New("duration must be a positive Go duration, e.g. 24h")
+)
+
+// reportTransitions lists the statuses each status may move to. Dismissed reports can be
+// reopened; actioned ones stay closed, further actions are applied to them directly.
+var reportTransitions = map[string][]string{
+	constants.ReportStatusOpen:      {constants.ReportStatusReviewing, constants.ReportStatusActioned, constants.ReportStatusDismissed},
+	constants.ReportStatusReviewing: {constants.ReportStatusOpen, constants.ReportStatusActioned, constants.ReportStatusDismissed},
+	constants.ReportStatusDismissed: {constants.ReportStatusOpen},
+}
+
+// ReportService keeps the abuse reports users file and applies the actions moderators
+// take on them. Open and reviewing reports form the moderation queue. The copy of a
+// reported message is stored encrypted.
+type ReportService struct {
+	redisRepo    redis.IRedisRepositories
+	inboxService *InboxService
+	chatService  *ChatMessageService
+	sanctions    *SanctionService
+	encryption   *EncryptionService
+}
+
+func NewReportService(redisRepo redis.IRedisRepositories, inboxService *InboxService, chatService *ChatMessageService, sanctions *SanctionService, encryption *EncryptionService) *ReportService {
+	return &ReportService{
+		redisRepo:    redisRepo,
+		inboxService: inboxService,
+		chatService:  chatService,
+		sanctions:    sanctions,
+		encryption:   encryption,
+	}
+}
+
+// Create files a report. A reported message must still be in the reporter's inbox;
+// it is copied into the report, so it survives the inbox and a later deletion.
+func (s *ReportService) Create(reporterUserID string, request dtos.CreateReportDto) (*models.AbuseReport, error) {
+	now := time.Now().UTC()
+	report := &models.AbuseReport{
+		ID:             uuid.New().String(),
+		ReporterUserID: reporterUserID,
+		ReportedUserID: request.ReportedUserID,
+		EventID:        request.EventID,
+		Reason:         request.Reason,
+		Details:        request.Details,
+		Status:         constants.ReportStatusOpen,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if request.EventID != "" {
+		message, err := s.inboxService.Find(reporterUserID, request.EventID)
+		if err != nil {
+			return nil, err
+		}
+		if request.ReportedUserID != "" && request.ReportedUserID != message.SenderUserID {
+			return nil, ErrInvalidReport
+		}
+		report.Message = message
+		report.ReportedUserID = message.SenderUserID
+		report.ChatID = message.ChatID
+	}
+	if report.ReportedUserID == "" || report.ReportedUserID == reporterUserID {
+		return nil, ErrInvalidReport
+	}
+
+	if err := s.save(report, ""); err != nil {
+		return nil, err
+	}
+	log.Printf("User %s reported user %s, report %s", reporterUserID, report.ReportedUserID, report.ID)
+	return report, nil
+}
+
+func (s *ReportService) Get(id string) (*models.AbuseReport, error) {
+	data, err := s.redisRepo.Get(reportPrefix+id, context.Background())
+	if err != nil {
+		return nil, ErrReportNotFound
+	}
+	var report models.AbuseReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, err
+	}
+	openReportMessage(s.encryption, &report)
+	return &report, nil
+}
+
+// List returns reports in a status, oldest first. Without a status it returns the
+// queue: open and reviewing reports.
+func (s *ReportService) List(status string, limit int) ([]models.AbuseReport, error) {
+	statuses := []string{status}
+	if status == "" {
+		statuses = []string{constants.ReportStatusOpen, constants.ReportStatusReviewing}
+	}
+
+	reports := make([]models.AbuseReport, 0)
+	for _, status := range statuses {
+		ids, err := s.redisRepo.SMembers(reportStatusPrefix+status, context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			report, err := s.Get(id)
+			if err != nil {
+				continue
+			}
+			reports = append(reports, *report)
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].CreatedAt.Before(reports[j].CreatedAt)
+	})
+	if len(reports) > limit {
+		reports = reports[:limit]
+	}
+	return reports, nil
+}
+
+// UpdateStatus moves a report through the review workflow
+func (s *ReportService) UpdateStatus(id, status, note string) (*models.AbuseReport, error) {
+	report, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(reportTransitions[report.Status], status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrReportTransition, report.Status, status)
+	}
+
+	previous := report.Status
+	report.Status = status
+	if note != "" {
+		report.Note = note
+	}
+	report.UpdatedAt = time.Now().UTC()
+	if err := s.save(report, previous); err != nil {
+		return nil, err
+	}
+	log.Printf("Report %s moved from %s to %s", id, previous, status)
+	return report, nil
+}
+
+// Act applies a moderator's action against the reported user and marks the report
+// actioned. Enforcement reaches the server holding the user's socket through its topic.
+func (s *ReportService) Act(id string, request dtos.ReportActionDto, actor string) (*models.AbuseReport, error) {
+	report, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status == constants.ReportStatusDismissed {
+		return nil, ErrReportDismissed
+	}
+
+	var duration time.Duration
+	if request.Duration != "" {
+		if duration, err = time.ParseDuration(request.Duration); err != nil || duration <= 0 {
+			return nil, ErrInvalidDuration
+		}
+	}
+
+	action := models.ReportAction{Action: request.Action, Reason: request.Reason, Actor: actor, AppliedAt: time.Now().UTC()}
+	userID := report.ReportedUserID
+	switch request.Action {
+	case constants.ReportActionDeleteMessage:
+		if report.Message == nil {
+			return nil, ErrNoReportedMessage
+		}
+		if _, err := s.chatService.DeleteMessage(*report.Message, request.Reason); err != nil {
+			return nil, err
+		}
+	case constants.ReportActionWarn:
+		s.chatService.NotifyModeration(userID, constants.ReportActionWarn, request.Reason, report.ChatID, "", nil)
+	case constants.ReportActionMute:
+		sanction, err := s.sanctions.Impose(userID, constants.SanctionMute, request.Reason, report.ID, duration)
+		if err != nil {
+			return nil, err
+		}
+		action.Until = sanction.Until
+		s.chatService.NotifyModeration(userID, constants.ReportActionMute, request.Reason, "", "", sanction.Until)
+	case constants.ReportActionBan:
+		sanction, err := s.sanctions.Impose(userID, constants.SanctionBan, request.Reason, report.ID, duration)
+		if err != nil {
+			return nil, err
+		}
+		action.Until = sanction.Until
+		reason := "banned"
+		if request.Reason != "" {
+			reason += ": " + request.Reason
+		}
+		if _, err := s.chatService.ForceDisconnect(userID, reason); err != nil && !errors.Is(err, ErrUserNotConnected) {
+			log.Printf("Error disconnecting banned user %s: %v", userID, err)
+		}
+	}
+
+	previous := report.Status
+	report.Actions = append(report.Actions, action)
+	report.Status = constants.ReportStatusActioned
+	report.UpdatedAt = action.AppliedAt
+	if err := s.save(report, previous); err != nil {
+		return nil, err
+	}
+	log.Printf("Applied %s to user %s for report %s", request.Action, userID, id)
+	return report, nil
+}
+
+// save stores a report and moves it from the previous status' set to its current one
+func (s *ReportService) save(report *models.AbuseReport, previousStatus string) error {
+	ctx := context.Background()
+	stored := *report
+	if report.Message != nil {
+		sealed, err := s.encryption.SealJson(report.Message.Tenant, reportPrefix+report.ID, report.Message)
+		if err != nil {
+			return err
+		}
+		stored.Message, stored.SealedMessage = nil, sealed
+	}
+	reportJson, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if err := s.redisRepo.Set(reportPrefix+report.ID, reportJson, 0, ctx); err != nil {
+		return err
+	}
+	if previousStatus != "" && previousStatus != report.Status {
+		s.redisRepo.SRem(reportStatusPrefix+previousStatus, report.ID, ctx)
+	}
+	return s.redisRepo.SAdd(reportStatusPrefix+report.Status, report.ID, ctx)
+}
+
+// openReportMessage decrypts the stored copy of a report's message. A copy whose data
+// key was shredded is dropped.
+func openReportMessage(encryption *EncryptionService, report *models.AbuseReport) {
+	if report.SealedMessage == nil {
+		return
+	}
+	var message models.ChatMessage
+	if err := encryption.OpenJson(report.SealedMessage, reportPrefix+report.ID, &message); err != nil {
+		log.Printf("Message of report %s is unreadable: %v", report.ID, err)
+	} else {
+		report.Message = &message
+	}
+	report.SealedMessage = nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/redis"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+const sanctionPrefix = "sanctions:"
+
+var ErrSanctionNotFound = errors.New("no such sanction on this user")
+
+// SanctionService keeps the mutes and bans moderators put on users. They live in Redis
+// and expire with their key, so every server enforces them from the next message on.
+type SanctionService struct {
+	redisRepo redis.IRedisRepositories
+}
+
+func NewSanctionService(redisRepo redis.IRedisRepositories) *SanctionService {
+	return &SanctionService{redisRepo: redisRepo}
+}
+
+// Impose mutes or bans a user, for the given duration or permanently when it is 0
+func (s *SanctionService) Impose(userID, kind, reason, reportID string, duration time.Duration) (*models.Sanction, error) {
+	now := time.Now().UTC()
+	sanction := &models.Sanction{UserID: userID, Kind: kind, Reason: reason, ReportID: reportID, CreatedAt: now}
+	if duration > 0 {
+		until := now.Add(duration)
+		sanction.Until = &until
+	}
+
+	sanctionJson, err := json.Marshal(sanction)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.Set(sanctionKey(kind, userID), sanctionJson, duration, context.Background()); err != nil {
+		return nil, err
+	}
+	log.Printf("Imposed %s on user %s, %s", kind, userID, durationLabel(duration))
+	return sanction, nil
+}
+
+// Lift ends a mute or ban early
+func (s *SanctionService) Lift(userID, kind string) error {
+	if _, err := s.Active(userID, kind); err != nil {
+		return err
+	}
+	if err := s.redisRepo.Del(sanctionKey(kind, userID), context.Background()); err != nil {
+		return err
+	}
+	log.Printf("Lifted %s of user %s", kind, userID)
+	return nil
+}
+
+// Active returns a user's mute or ban, ErrSanctionNotFound when there is none
+func (s *SanctionService) Active(userID, kind string) (*models.Sanction, error) {
+	data, err := s.redisRepo.Get(sanctionKey(kind, userID), context.Background())
+	if err != nil {
+		return nil, ErrSanctionNotFound
+	}
+	var sanction models.Sanction
+	if err := json.Unmarshal([]byte(data), &sanction); err != nil {
+		return nil, err
+	}
+	return &sanction, nil
+}
+
+// List returns all active sanctions of a user
+func (s *SanctionService) List(userID string) []models.Sanction {
+	sanctions := make([]models.Sanction, 0)
+	for _, kind := range []string{constants.SanctionMute, constants.SanctionBan} {
+		if sanction, err := s.Active(userID, kind); err == nil {
+			sanctions = append(sanctions, *sanction)
+		}
+	}
+	return sanctions
+}
+
+// Banned reports whether a user may not connect
+func (s *SanctionService) Banned(userID string) bool {
+	_, err := s.Active(userID, constants.SanctionBan)
+	return err == nil
+}
+
+// SanctionPolicy denies sends by muted or banned users
+type SanctionPolicy struct {
+	sanctions *SanctionService
+}
+
+func NewSanctionPolicy(sanctions *SanctionService) *SanctionPolicy {
+	return &SanctionPolicy{sanctions: sanctions}
+}
+
+func (p *SanctionPolicy) AuthorizeSend(request SendRequest) error {
+	if p.sanctions.Banned(request.Sender.UserID) {
+		return deny(constants.DenyBanned, "you are banned")
+	}
+	if mute, err := p.sanctions.Active(request.Sender.UserID, constants.SanctionMute); err == nil {
+		if mute.Until != nil {
+			return deny(constants.DenyMuted, "you are muted until %s", mute.Until.Format(time.RFC3339))
+		}
+		return deny(constants.DenyMuted, "you are muted")
+	}
+	return nil
+}
+
+func sanctionKey(kind, userID string) string {
+	return sanctionPrefix + kind + ":" + userID
+}
+
+func durationLabel(duration time.Duration) string {
+	if duration <= 0 {
+		return "permanently"
+	}
+	return "for " + duration.String()
+}
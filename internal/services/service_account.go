@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/pkg/redis"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serviceAccountPrefix = "service_accounts:account:"
+	apiKeyPrefix         = "service_accounts:key:"
+	accountKeysPrefix    = "service_accounts:keys:" // Set of key ids per account
+	apiKeyLastUsedPrefix = "service_accounts:last_used:"
+
+	lastUsedResolution = time.Minute // Last use is written at most this often per key
+)
+
+var (
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	ErrServiceAccountExists   = errors.New("service account already exists")
+	ErrAPIKeyNotFound         = errors.New("API key not found")
+	ErrInvalidAPIKey          = errors.New("invalid or expired API key")
+	ErrScopeDenied            = errors.New("API key lacks the required scope")
+)
+
+// storedAPIKey is an API key as kept in Redis, with the hash of its secret
+type storedAPIKey struct {
+	models.APIKey
+	SecretHash string `json:"secret_hash"`
+}
+
+// ServiceAccountService manages service accounts and authenticates their API keys.
+// Keys look like dcs_<key id>.<secret>; only a SHA-256 hash of the secret is stored.
+type ServiceAccountService struct {
+	redisRepo redis.IRedisRepositories
+
+	mutex    sync.Mutex
+	lastUsed map[string]time.Time // Last use written per key id
+}
+
+func NewServiceAccountService(redisRepo redis.IRedisRepositories) *ServiceAccountService {
+	return &ServiceAccountService{redisRepo: redisRepo, lastUsed: make(map[string]time.Time)}
+}
+
+// Create registers a service account and issues its first API key
+func (s *ServiceAccountService) Create(request dtos.CreateServiceAccountDto) (*models.ServiceAccount, *dtos.APIKeyResponseDto, error) {
+	if _, err := s.Get(request.ID); err == nil {
+		return nil, nil, ErrServiceAccountExists
+	}
+
+	account := &models.ServiceAccount{
+		ID:        request.ID,
+		Name:      request.Name,
+		Scopes:    request.Scopes,
+		Tenant:    request.Tenant,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.save(account); err != nil {
+		return nil, nil, err
+	}
+	key, err := s.issueKey(account.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Service account %s created with scopes %s", account.ID, strings.Join(account.Scopes, ","))
+	return account, key, nil
+}
+
+func (s *ServiceAccountService) Get(accountID string) (*models.ServiceAccount, error) {
+	data, err := s.redisRepo.Get(serviceAccountPrefix+accountID, context.Background())
+	if err != nil {
+		return nil, ErrServiceAccountNotFound
+	}
+	var account models.ServiceAccount
+	if err := json.Unmarshal([]byte(data), &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (s *ServiceAccountService) List() ([]models.ServiceAccount, error) {
+	keys, err := s.redisRepo.Keys(serviceAccountPrefix+"*", context.Background())
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]models.ServiceAccount, 0, len(keys))
+	for _, key := range keys {
+		account, err := s.Get(strings.TrimPrefix(key, serviceAccountPrefix))
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, nil
+}
+
+// UpdateScopes replaces an account's scopes; they apply to its existing keys at once
+func (s *ServiceAccountService) UpdateScopes(accountID string, scopes []string) (*models.ServiceAccount, error) {
+	account, err := s.Get(accountID)
+	if err != nil {
+		return nil, err
+	}
+	account.Scopes = scopes
+	if err := s.save(account); err != nil {
+		return nil, err
+	}
+	log.Printf("Service account %s scopes set to %s", accountID, strings.Join(scopes, ","))
+	return account, nil
+}
+
+// Delete removes an account and all of its keys
+func (s *ServiceAccountService) Delete(accountID string) error {
+	if _, err := s.Get(accountID); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	keyIDs, err := s.redisRepo.SMembers(accountKeysPrefix+accountID, ctx)
+	if err != nil {
+		return err
+	}
+	for _, keyID := range keyIDs {
+		s.redisRepo.Del(apiKeyPrefix+keyID, ctx)
+		s.redisRepo.Del(apiKeyLastUsedPrefix+keyID, ctx)
+	}
+	s.redisRepo.Del(accountKeysPrefix+accountID, ctx)
+	if err := s.redisRepo.Del(serviceAccountPrefix+accountID, ctx); err != nil {
+		return err
+	}
+	log.Printf("Service account %s deleted with %d keys", accountID, len(keyIDs))
+	return nil
+}
+
+// Keys lists an account's keys that still work, with when each was last used
+func (s *ServiceAccountService) Keys(accountID string) ([]models.APIKey, error) {
+	ctx := context.Background()
+	keyIDs, err := s.redisRepo.SMembers(accountKeysPrefix+accountID, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]models.APIKey, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		key, err := s.storedKey(keyID)
+		if err != nil {
+			// Expired after a rotation
+			s.redisRepo.SRem(accountKeysPrefix+accountID, keyID, ctx)
+			continue
+		}
+		if lastUsed, err := s.redisRepo.Get(apiKeyLastUsedPrefix+keyID, ctx); err == nil {
+			if at, err := time.Parse(time.RFC3339, lastUsed); err == nil {
+				key.LastUsedAt = &at
+			}
+		}
+		keys = append(keys, key.APIKey)
+	}
+	slices.SortFunc(keys, func(a, b models.APIKey) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return keys, nil
+}
+
+// Rotate issues a new key. The account's other keys keep working for the grace period,
+// so deployments can switch over, and are revoked at once without one.
+func (s *ServiceAccountService) Rotate(accountID string, grace time.Duration) (*dtos.APIKeyResponseDto, error) {
+	if _, err := s.Get(accountID); err != nil {
+		return nil, err
+	}
+	previous, err := s.Keys(accountID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.issueKey(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, old := range previous {
+		if grace <= 0 {
+			s.Revoke(accountID, old.ID)
+			continue
+		}
+		if err := s.expireKey(old.ID, grace); err != nil {
+			log.Printf("Error expiring API key %s of service account %s: %v", old.ID, accountID, err)
+		}
+	}
+	if grace > 0 {
+		log.Printf("Rotated API keys of service account %s, %d previous keys expire in %s", accountID, len(previous), grace)
+	} else {
+		log.Printf("Rotated API keys of service account %s, %d previous keys revoked", accountID, len(previous))
+	}
+	return key, nil
+}
+
+// Revoke deletes a key at once
+func (s *ServiceAccountService) Revoke(accountID, keyID string) error {
+	key, err := s.storedKey(keyID)
+	if err != nil || key.AccountID != accountID {
+		return ErrAPIKeyNotFound
+	}
+	ctx := context.Background()
+	s.redisRepo.SRem(accountKeysPrefix+accountID, keyID, ctx)
+	s.redisRepo.Del(apiKeyLastUsedPrefix+keyID, ctx)
+	if err := s.redisRepo.Del(apiKeyPrefix+keyID, ctx); err != nil {
+		return err
+	}
+	log.Printf("Revoked API key %s of service account %s", keyID, accountID)
+	return nil
+}
+
+// Authenticate verifies an API key and that its account holds the scope
+func (s *ServiceAccountService) Authenticate(apiKey, scope string) (*models.ServiceAccount, error) {
+	keyID, secret, ok := strings.Cut(strings.TrimPrefix(apiKey, constants.APIKeyPrefix), ".")
+	if !ok || !strings.HasPrefix(apiKey, constants.APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.storedKey(keyID)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashSecret(secret))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	account, err := s.Get(key.AccountID)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if !slices.Contains(account.Scopes, scope) {
+		return nil, fmt.Errorf("%w %s", ErrScopeDenied, scope)
+	}
+	s.touch(keyID)
+	return account, nil
+}
+
+func (s *ServiceAccountService) issueKey(accountID string) (*dtos.APIKeyResponseDto, error) {
+	keyIDBytes := make([]byte, 8)
+	if _, err := rand.Read(keyIDBytes); err != nil {
+		return nil, err
+	}
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	key := storedAPIKey{
+		APIKey: models.APIKey{
+			ID:        hex.EncodeToString(keyIDBytes),
+			AccountID: accountID,
+			CreatedAt: time.Now().UTC(),
+		},
+		SecretHash: hashSecret(secret),
+	}
+	if err := s.saveKey(key, 0); err != nil {
+		return nil, err
+	}
+	if err := s.redisRepo.SAdd(accountKeysPrefix+accountID, key.ID, context.Background()); err != nil {
+		return nil, err
+	}
+	return &dtos.APIKeyResponseDto{Key: constants.APIKeyPrefix + key.ID + "." + secret, APIKey: key.APIKey}, nil
+}
+
+// expireKey limits a key to the grace period; Redis drops it once it passes
+func (s *ServiceAccountService) expireKey(keyID string, grace time.Duration) error {
+	key, err := s.storedKey(keyID)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().UTC().Add(grace)
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(expiresAt) {
+		return nil // Already expiring sooner, from an earlier rotation
+	}
+	key.ExpiresAt = &expiresAt
+	return s.saveKey(*key, grace)
+}
+
+// touch records that a key was used, at most once per lastUsedResolution
+func (s *ServiceAccountService) touch(keyID string) {
+	now := time.Now().UTC()
+	s.mutex.Lock()
+	if now.Sub(s.lastUsed[keyID]) < lastUsedResolution {
+		s.mutex.Unlock()
+		return
+	}
+	s.lastUsed[keyID] = now
+	s.mutex.Unlock()
+
+	if err := s.redisRepo.Set(apiKeyLastUsedPrefix+keyID, []byte(now.Format(time.RFC3339)), 0, context.Background()); err != nil {
+		log.Printf("Error recording use of API key %s: %v", keyID, err)
+	}
+}
+
+func (s *ServiceAccountService) storedKey(keyID string) (*storedAPIKey, error) {
+	data, err := s.redisRepo.Get(apiKeyPrefix+keyID, context.Background())
+	if err != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	var key storedAPIKey
+	if err := json.Unmarshal([]byte(data), &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *ServiceAccountService) saveKey(key storedAPIKey, ttl time.Duration) error {
+	keyJson, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return s.redisRepo.Set(apiKeyPrefix+key.ID, keyJson, ttl, context.Background())
+}
+
+func (s *ServiceAccountService) save(account *models.ServiceAccount) error {
+	accountJson, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	return s.redisRepo.Set(serviceAccountPrefix+account.ID, accountJson, 0, context.Background())
+}
+
+// serviceAccountActorPrefix sets service accounts apart from users, who may not take IDs under it
+const serviceAccountActorPrefix = "service-account:"
+
+// ServiceAccountActor names a service account as a sender, in logs and in records of who did what
+func ServiceAccountActor(accountID string) string {
+	return serviceAccountActorPrefix + accountID
+}
+
+// IsServiceAccountActor reports whether an ID is reserved for service accounts
+func IsServiceAccountActor(id string) bool {
+	return strings.HasPrefix(id, serviceAccountActorPrefix)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
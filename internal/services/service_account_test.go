@@ -0,0 +1,115 @@
+package services
+
+import (
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     func(t *testing.T, mini *miniredis.Miniredis, accounts *ServiceAccountService, first string) string
+		scope   string
+		wantErr error
+	}{
+		{
+			name: "current key",
+			key: func(t *testing.T, _ *miniredis.Miniredis, _ *ServiceAccountService, first string) string {
+				return first
+			},
+			scope: constants.ScopeSend,
+		},
+		{
+			name: "missing scope",
+			key: func(t *testing.T, _ *miniredis.Miniredis, _ *ServiceAccountService, first string) string {
+				return first
+			},
+			scope:   constants.ScopeAdmin,
+			wantErr: ErrScopeDenied,
+		},
+		{
+			name: "rotated key within the grace period",
+			key: func(t *testing.T, _ *miniredis.Miniredis, accounts *ServiceAccountService, first string) string {
+				rotate(t, accounts, time.Hour)
+				return first
+			},
+			scope: constants.ScopeSend,
+		},
+		{
+			name: "rotated key after the grace period",
+			key: func(t *testing.T, mini *miniredis.Miniredis, accounts *ServiceAccountService, first string) string {
+				rotate(t, accounts, time.Hour)
+				mini.FastForward(2 * time.Hour)
+				return first
+			},
+			scope:   constants.ScopeSend,
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			name: "key rotated without a grace period",
+			key: func(t *testing.T, _ *miniredis.Miniredis, accounts *ServiceAccountService, first string) string {
+				rotate(t, accounts, 0)
+				return first
+			},
+			scope:   constants.ScopeSend,
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			name: "new key after rotation",
+			key: func(t *testing.T, _ *miniredis.Miniredis, accounts *ServiceAccountService, _ string) string {
+				return rotate(t, accounts, 0)
+			},
+			scope: constants.ScopeSend,
+		},
+		{
+			name: "wrong secret",
+			key: func(t *testing.T, _ *miniredis.Miniredis, _ *ServiceAccountService, first string) string {
+				keyID, _, _ := strings.Cut(first, ".")
+				return keyID + ".not-the-secret"
+			},
+			scope:   constants.ScopeSend,
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			name:    "not an API key",
+			key:     func(*testing.T, *miniredis.Miniredis, *ServiceAccountService, string) string { return "Bearer abc" },
+			scope:   constants.ScopeSend,
+			wantErr: ErrInvalidAPIKey,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mini, redisRepo := testRedis(t)
+			accounts := NewServiceAccountService(redisRepo)
+			_, key, err := accounts.Create(dtos.CreateServiceAccountDto{ID: "billing", Name: "Billing", Scopes: []string{constants.ScopeSend}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			account, err := accounts.Authenticate(test.key(t, mini, accounts, key.Key), test.scope)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && account.ID != "billing" {
+				t.Errorf("Authenticate() = %s, want billing", account.ID)
+			}
+		})
+	}
+}
+
+// rotate rotates the billing account's keys, returning the new one
+func rotate(t *testing.T, accounts *ServiceAccountService, grace time.Duration) string {
+	t.Helper()
+	key, err := accounts.Rotate("billing", grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.Key
+}
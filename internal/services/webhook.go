@@ -0,0 +1,429 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"distributed-chat-system/internal/apis/dtos"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"distributed-chat-system/internal/utils"
+	"distributed-chat-system/pkg/kafka"
+	"distributed-chat-system/pkg/redis"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	webhookSubscriptionPrefix = "webhook:subscription:"
+	webhookDeadLetterKey      = "webhook:dead-letters"
+	webhookDeadLetterMaxLen   = 1000
+)
+
+var ErrWebhookNotFound = errors.New("webhook subscription not found")
+
+type WebhookConfig struct {
+	MaxAttempts    int           // Delivery attempts before a payload is dead-lettered
+	InitialBackoff time.Duration // Wait before the first retry, doubled on every attempt
+	RequestTimeout time.Duration // Timeout of a single HTTP delivery
+	MaxInFlight    int           // Concurrent deliveries per server
+	CacheTTL       time.Duration // How long subscriptions are cached between Redis reads
+}
+
+// WebhookConfigFromEnv builds the webhook configuration from WEBHOOK_* environment variables
+func WebhookConfigFromEnv() *WebhookConfig {
+	return &WebhookConfig{
+		MaxAttempts:    utils.GetEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		InitialBackoff: utils.GetEnvDuration("WEBHOOK_INITIAL_BACKOFF", time.Second),
+		RequestTimeout: utils.GetEnvDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+		MaxInFlight:    utils.GetEnvInt("WEBHOOK_MAX_IN_FLIGHT", 32),
+		CacheTTL:       utils.GetEnvDuration("WEBHOOK_CACHE_TTL", 10*time.Second),
+	}
+}
+
+// Validate rejects settings that would stall or crash delivery
+func (c *WebhookConfig) Validate() error {
+	if c.MaxAttempts < 1 {
+		return errors.New("WEBHOOK_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.MaxInFlight < 1 {
+		return errors.New("WEBHOOK_MAX_IN_FLIGHT must be at least 1")
+	}
+	return nil
+}
+
+// WebhookService delivers chat events to operator-registered HTTP endpoints.
+// It reads the same per-server Kafka topic as chat delivery under a separate
+// consumer group, so slow endpoints never hold up messages to users.
+type WebhookService struct {
+	kafkaClient kafka.IKafkaClient
+	redisRepo   redis.IRedisRepositories
+	encryption  *EncryptionService
+	config      *WebhookConfig
+	httpClient  *http.Client
+	inFlight    chan struct{}
+
+	mutex         sync.RWMutex
+	subscriptions []models.WebhookSubscription
+	loadedAt      time.Time
+}
+
+func NewWebhookService(kafkaClient kafka.IKafkaClient, redisRepo redis.IRedisRepositories, encryption *EncryptionService, config *WebhookConfig) *WebhookService {
+	return &WebhookService{
+		kafkaClient: kafkaClient,
+		redisRepo:   redisRepo,
+		encryption:  encryption,
+		config:      config,
+		httpClient:  &http.Client{Timeout: config.RequestTimeout},
+		inFlight:    make(chan struct{}, config.MaxInFlight),
+	}
+}
+
+// StartDispatching starts consuming this server's topic for webhook delivery
+func (s *WebhookService) StartDispatching() {
+	groupID := os.Getenv("CHAT_GROUP_ID") + "-webhooks"
+	s.kafkaClient.ConsumeMessagesWithGroup(context.Background(), os.Getenv("SERVER_ID"), groupID, s.consumeEvent)
+}
+
+// CreateSubscription registers a new webhook endpoint
+func (s *WebhookService) CreateSubscription(request dtos.CreateWebhookDto) (*models.WebhookSubscription, error) {
+	for _, eventType := range request.Events {
+		if !slices.Contains(constants.AllEventTypes, eventType) {
+			return nil, fmt.Errorf("unknown event type: %s", eventType)
+		}
+	}
+
+	subscription := &models.WebhookSubscription{
+		ID:        uuid.New().String(),
+		URL:       request.URL,
+		Secret:    request.Secret,
+		Events:    request.Events,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	subscriptionJson, err := json.Marshal(subscription)
+	if err != nil {
+		return nil, err
+	}
+	err = s.redisRepo.Set(webhookSubscriptionPrefix+subscription.ID, subscriptionJson, 0, context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateCache()
+	log.Println("Webhook subscription created: ", subscription.ID)
+	return subscription, nil
+}
+
+// ListSubscriptions returns all registered webhook subscriptions
+func (s *WebhookService) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	keys, err := s.redisRepo.Keys(webhookSubscriptionPrefix+"*", context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions := make([]models.WebhookSubscription, 0, len(keys))
+	for _, key := range keys {
+		data, err := s.redisRepo.Get(key, context.Background())
+		if err != nil {
+			continue // Deleted between SCAN and GET
+		}
+		var subscription models.WebhookSubscription
+		if err := json.Unmarshal([]byte(data), &subscription); err != nil {
+			log.Printf("Skipping malformed webhook subscription %s: %v", key, err)
+			continue
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes a webhook subscription
+func (s *WebhookService) DeleteSubscription(id string) error {
+	key := webhookSubscriptionPrefix + id
+	if _, err := s.redisRepo.Get(key, context.Background()); err != nil {
+		return ErrWebhookNotFound
+	}
+	if err := s.redisRepo.Del(key, context.Background()); err != nil {
+		return err
+	}
+
+	s.invalidateCache()
+	log.Println("Webhook subscription deleted: ", id)
+	return nil
+}
+
+// ListDeadLetters returns the most recent deliveries that exhausted their retries
+func (s *WebhookService) ListDeadLetters(limit int64) ([]models.WebhookDeadLetter, error) {
+	entries, err := s.redisRepo.LRange(webhookDeadLetterKey, 0, limit-1, context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters := make([]models.WebhookDeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		var deadLetter models.WebhookDeadLetter
+		if err := json.Unmarshal([]byte(entry), &deadLetter); err != nil {
+			continue
+		}
+		if err := openDeadLetterMessage(s.encryption, &deadLetter); err != nil {
+			log.Printf("Message of webhook dead letter %s is unreadable: %v", deadLetter.Payload.ID, err)
+			deadLetter.SealedMessage = nil
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	return deadLetters, nil
+}
+
+// Handles a single record from the server topic & fans it out to matching subscriptions
+func (s *WebhookService) consumeEvent(message string) {
+	payload, err := toWebhookPayload(message)
+	if err != nil {
+		log.Println("Skipping event for webhooks:", err)
+		return
+	}
+	// Control events are internal to the cluster
+	if !slices.Contains(constants.AllEventTypes, payload.Type) {
+		return
+	}
+
+	subscriptions, err := s.cachedSubscriptions()
+	if err != nil {
+		log.Println("Error loading webhook subscriptions:", err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		if len(subscription.Events) > 0 && !slices.Contains(subscription.Events, payload.Type) {
+			continue
+		}
+
+		// Bound concurrent deliveries; this only waits when every slot is retrying
+		s.inFlight <- struct{}{}
+		go func(subscription models.WebhookSubscription) {
+			defer func() { <-s.inFlight }()
+			s.deliver(subscription, payload)
+		}(subscription)
+	}
+}
+
+// toWebhookPayload converts a raw topic record (chat message or lifecycle event) into a webhook payload
+func toWebhookPayload(message string) (models.WebhookPayload, error) {
+	var header struct {
+		EventID   string `json:"event_id"`
+		EventType string `json:"event_type"`
+	}
+	if err := json.Unmarshal([]byte(message), &header); err != nil {
+		return models.WebhookPayload{}, err
+	}
+
+	payload := models.WebhookPayload{
+		ID:         header.EventID,
+		Type:       header.EventType,
+		OccurredAt: time.Now().UTC(),
+	}
+
+	if header.EventType == "" || header.EventType == constants.EventMessageSent {
+		var chatMessage models.ChatMessage
+		if err := json.Unmarshal([]byte(message), &chatMessage); err != nil {
+			return models.WebhookPayload{}, err
+		}
+		chatMessage.EventType = constants.EventMessageSent
+		// Webhooks get the metadata of encrypted messages only, never the ciphertext
+		if chatMessage.MessageType == constants.MessageTypeCiphertext {
+			chatMessage.Message = ""
+		}
+		payload.Type = constants.EventMessageSent
+		payload.Data = chatMessage
+		return payload, nil
+	}
+
+	var chatEvent models.ChatEvent
+	if err := json.Unmarshal([]byte(message), &chatEvent); err != nil {
+		return models.WebhookPayload{}, err
+	}
+	payload.OccurredAt = chatEvent.OccurredAt
+	payload.Data = chatEvent
+	return payload, nil
+}
+
+// deliver POSTs a signed payload, retrying with exponential backoff before dead-lettering it
+func (s *WebhookService) deliver(subscription models.WebhookSubscription, payload models.WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling webhook payload %s: %v", payload.ID, err)
+		return
+	}
+
+	backoff := s.config.InitialBackoff
+	var lastErr error
+	for attempt := 1; attempt <= s.config.MaxAttempts; attempt++ {
+		lastErr = s.post(subscription, payload, body, attempt)
+		if lastErr == nil {
+			return
+		}
+
+		log.Printf("Webhook delivery %s to %s failed (attempt %d/%d): %v",
+			payload.ID, subscription.URL, attempt, s.config.MaxAttempts, lastErr)
+		if attempt < s.config.MaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	s.deadLetter(models.WebhookDeadLetter{
+		SubscriptionID: subscription.ID,
+		URL:            subscription.URL,
+		Payload:        payload,
+		Attempts:       s.config.MaxAttempts,
+		LastError:      lastErr.Error(),
+		FailedAt:       time.Now().UTC(),
+	})
+}
+
+func (s *WebhookService) post(subscription models.WebhookSubscription, payload models.WebhookPayload, body []byte, attempt int) error {
+	request, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Chat-Event", payload.Type)
+	request.Header.Set("X-Chat-Delivery", payload.ID)
+	request.Header.Set("X-Chat-Delivery-Attempt", strconv.Itoa(attempt))
+	request.Header.Set("X-Chat-Timestamp", timestamp)
+	request.Header.Set("X-Chat-Signature", "sha256="+SignWebhookPayload(subscription.Secret, timestamp, body))
+
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhookPayload computes the hex HMAC-SHA256 of "<timestamp>.<body>" with the subscription secret.
+// Receivers recompute it to verify the payload and reject stale timestamps to prevent replays.
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookService) deadLetter(deadLetter models.WebhookDeadLetter) {
+	if err := sealDeadLetterMessage(s.encryption, &deadLetter); err != nil {
+		log.Printf("Error encrypting message of webhook dead letter %s, keeping the dead letter without it: %v", deadLetter.Payload.ID, err)
+	}
+	data, err := json.Marshal(deadLetter)
+	if err != nil {
+		log.Printf("Error marshalling webhook dead letter: %v", err)
+		return
+	}
+
+	ctx := context.Background()
+	if err := s.redisRepo.LPush(webhookDeadLetterKey, data, ctx); err != nil {
+		log.Printf("Error storing webhook dead letter %s: %v", deadLetter.Payload.ID, err)
+		return
+	}
+	s.redisRepo.LTrim(webhookDeadLetterKey, 0, webhookDeadLetterMaxLen-1, ctx)
+	log.Printf("Webhook delivery %s to %s dead-lettered", deadLetter.Payload.ID, deadLetter.URL)
+}
+
+// deadLetterMessage returns the chat message a dead letter carries, false for lifecycle events
+func deadLetterMessage(deadLetter *models.WebhookDeadLetter) (models.ChatMessage, bool) {
+	var message models.ChatMessage
+	if deadLetter.Payload.Type != constants.EventMessageSent {
+		return message, false
+	}
+	// Data is a models.ChatMessage when dead-lettered, and a map once read back
+	data, err := json.Marshal(deadLetter.Payload.Data)
+	if err != nil || json.Unmarshal(data, &message) != nil {
+		return message, false
+	}
+	return message, true
+}
+
+func deadLetterAAD(deadLetter *models.WebhookDeadLetter) string {
+	return webhookDeadLetterKey + ":" + deadLetter.SubscriptionID + ":" + deadLetter.Payload.ID
+}
+
+// sealDeadLetterMessage replaces the text of a dead-lettered message with its encrypted
+// form. The text is dropped when it can't be sealed.
+func sealDeadLetterMessage(encryption *EncryptionService, deadLetter *models.WebhookDeadLetter) error {
+	message, ok := deadLetterMessage(deadLetter)
+	if !ok || message.Message == "" {
+		return nil
+	}
+	text := message.Message
+	message.Message = ""
+	deadLetter.Payload.Data = message
+	sealed, err := encryption.Seal(message.Tenant, deadLetterAAD(deadLetter), []byte(text))
+	if err != nil {
+		return err
+	}
+	deadLetter.SealedMessage = sealed
+	return nil
+}
+
+// openDeadLetterMessage decrypts a dead letter's stored text back into its payload
+func openDeadLetterMessage(encryption *EncryptionService, deadLetter *models.WebhookDeadLetter) error {
+	if deadLetter.SealedMessage == nil {
+		return nil
+	}
+	message, ok := deadLetterMessage(deadLetter)
+	if !ok {
+		return nil
+	}
+	text, err := encryption.Open(deadLetter.SealedMessage, deadLetterAAD(deadLetter))
+	if err != nil {
+		return err
+	}
+	message.Message = string(text)
+	deadLetter.Payload.Data, deadLetter.SealedMessage = message, nil
+	return nil
+}
+
+func (s *WebhookService) cachedSubscriptions() ([]models.WebhookSubscription, error) {
+	s.mutex.RLock()
+	if time.Since(s.loadedAt) < s.config.CacheTTL {
+		defer s.mutex.RUnlock()
+		return s.subscriptions, nil
+	}
+	s.mutex.RUnlock()
+
+	subscriptions, err := s.ListSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.subscriptions = subscriptions
+	s.loadedAt = time.Now()
+	return subscriptions, nil
+}
+
+func (s *WebhookService) invalidateCache() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.loadedAt = time.Time{}
+}
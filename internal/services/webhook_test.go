@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"distributed-chat-system/internal/constants"
+	"distributed-chat-system/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestDeadLetterSealing(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     models.WebhookPayload
+		shred       bool
+		wantMessage string
+	}{
+		{
+			name:        "message is sealed",
+			payload:     models.WebhookPayload{ID: "event-1", Type: constants.EventMessageSent, Data: models.ChatMessage{EventID: "event-1", Tenant: "acme", SenderUserID: "alice", Message: "secret plans"}},
+			wantMessage: "secret plans",
+		},
+		{
+			name:    "message of a shredded tenant is dropped",
+			payload: models.WebhookPayload{ID: "event-1", Type: constants.EventMessageSent, Data: models.ChatMessage{EventID: "event-1", Tenant: "acme", SenderUserID: "alice", Message: "secret plans"}},
+			shred:   true,
+		},
+		{
+			name:    "lifecycle event is kept as is",
+			payload: models.WebhookPayload{ID: "event-2", Type: constants.EventUserConnected, Data: models.ChatEvent{EventID: "event-2", UserID: "alice"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, redisRepo := testRedis(t)
+			encryption := testEncryption(t, redisRepo)
+			webhooks := NewWebhookService(&recordingKafka{}, redisRepo, encryption, WebhookConfigFromEnv())
+
+			webhooks.deadLetter(models.WebhookDeadLetter{SubscriptionID: "sub-1", Payload: test.payload, Attempts: 5, LastError: "unexpected status 500"})
+
+			stored, err := redisRepo.LRange(webhookDeadLetterKey, 0, -1, context.Background())
+			if err != nil || len(stored) != 1 {
+				t.Fatalf("stored dead letters = %v, %v", stored, err)
+			}
+			if strings.Contains(stored[0], "secret plans") {
+				t.Errorf("dead letter stored in plain: %s", stored[0])
+			}
+
+			if test.shred {
+				if err := encryption.Shred("acme"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			deadLetters, err := webhooks.ListDeadLetters(10)
+			if err != nil || len(deadLetters) != 1 {
+				t.Fatalf("ListDeadLetters() = %v, %v", deadLetters, err)
+			}
+			deadLetter := deadLetters[0]
+			if deadLetter.SealedMessage != nil || deadLetter.Payload.ID != test.payload.ID {
+				t.Errorf("dead letter = %+v", deadLetter)
+			}
+			if message, ok := deadLetterMessage(&deadLetter); ok && message.Message != test.wantMessage {
+				t.Errorf("message = %q, want %q", message.Message, test.wantMessage)
+			}
+		})
+	}
+}
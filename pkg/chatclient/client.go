@@ -0,0 +1,564 @@
+package chatclient
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/codecs"
+	"distributed-chat-system/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrNotConnected = errors.New("chatclient: not connected")
+	ErrClosed       = errors.New("chatclient: client closed")
+	ErrAckTimeout   = errors.New("chatclient: timed out waiting for ack")
+)
+
+// RejectedError is returned by Send when the server refused the message
+type RejectedError struct {
+	Code       string // Machine readable reason, e.g. not_member, blocked or rate_limited
+	Reason     string
+	RetryAfter time.Duration // Set for rate_limited, how long to wait before sending again
+}
+
+func (e *RejectedError) Error() string {
+	if e.Code == "" {
+		return "chatclient: server rejected message: " + e.Reason
+	}
+	return fmt.Sprintf("chatclient: server rejected message (%s): %s", e.Code, e.Reason)
+}
+
+// Client is a chat WebSocket client that reconnects automatically with jittered
+// exponential backoff and resumes from the last sequence it has seen, so no
+// message is missed or delivered twice across reconnects.
+//
+// Sequences are assigned before a message is routed and routing only keeps the order
+// within a chat, so a message may arrive after one with a higher sequence. Reconnects
+// therefore resume a few sequences early and duplicates are dropped by event ID.
+type Client struct {
+	config *Config
+	codec  codecs.Codec
+
+	mutex        sync.Mutex
+	conn         *websocket.Conn
+	pendingAcks  map[string]chan Ack
+	onMessage    func(Message)
+	onConnect    func()
+	onDisconnect func(error)
+	onError      func(string)
+	onReceipt    func(Receipt)
+	onModeration func(ModerationNotice)
+
+	writeMutex   sync.Mutex
+	lastSequence atomic.Int64
+	seen         *eventSet     // Recently delivered event IDs, only touched by the read goroutine
+	connected    chan struct{} // Closed while a connection is up
+	closed       chan struct{}
+	closeOnce    sync.Once
+}
+
+// New creates a client; call Connect to start it
+func New(config *Config) *Client {
+	client := &Client{
+		config:      config,
+		codec:       codecs.ForSubprotocol(config.Subprotocol),
+		pendingAcks: make(map[string]chan Ack),
+		seen:        newEventSet(maxSeenEvents),
+		connected:   make(chan struct{}),
+		closed:      make(chan struct{}),
+	}
+	if config.ResumeFrom > 0 {
+		client.lastSequence.Store(config.ResumeFrom)
+	}
+	return client
+}
+
+// OnMessage registers the handler for delivered messages. It runs on the read
+// goroutine, so it should hand off slow work.
+func (c *Client) OnMessage(handler func(Message)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onMessage = handler
+}
+
+// OnConnect registers a handler called after every (re)connect and resume
+func (c *Client) OnConnect(handler func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onConnect = handler
+}
+
+// OnDisconnect registers a handler called whenever the connection drops
+func (c *Client) OnDisconnect(handler func(error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onDisconnect = handler
+}
+
+// OnError registers a handler for error frames sent by the server
+func (c *Client) OnError(handler func(string)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onError = handler
+}
+
+// OnReceipt registers a handler for delivery and read receipts of sent messages
+func (c *Client) OnReceipt(handler func(Receipt)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onReceipt = handler
+}
+
+// OnModeration registers a handler for warnings, mutes and messages deleted by moderators
+func (c *Client) OnModeration(handler func(ModerationNotice)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onModeration = handler
+}
+
+// LastSequence is the highest inbox sequence received, used to resume
+func (c *Client) LastSequence() int64 {
+	return c.lastSequence.Load()
+}
+
+// Connect dials the server and keeps the connection alive until Close or ctx is done.
+// It returns once the first connection is established or the first dial fails.
+func (c *Client) Connect(ctx context.Context) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.attach(conn)
+	go c.run(ctx, conn)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-c.closed:
+		}
+	}()
+	return nil
+}
+
+// Close stops reconnecting and closes the current connection
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+
+	c.mutex.Lock()
+	conn := c.conn
+	c.mutex.Unlock()
+	if conn == nil {
+		return nil
+	}
+
+	c.writeMutex.Lock()
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(c.config.WriteTimeout))
+	c.writeMutex.Unlock()
+	return conn.Close()
+}
+
+// WaitConnected blocks until a connection is up
+func (c *Client) WaitConnected(ctx context.Context) error {
+	c.mutex.Lock()
+	connected := c.connected
+	c.mutex.Unlock()
+
+	select {
+	case <-connected:
+		return nil
+	case <-c.closed:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Send sends a text message and waits for the server to ack it
+func (c *Client) Send(ctx context.Context, chatID, receiverUserID, message string) (*Ack, error) {
+	return c.SendTyped(ctx, chatID, receiverUserID, "text", message)
+}
+
+// DirectChatID is the chat ID of direct messages between two users. The server refuses
+// direct messages in any other chat without members.
+func DirectChatID(a, b string) string {
+	return models.DirectChatID(a, b)
+}
+
+// SendTyped sends a message with an explicit message_type and waits for the server to ack it
+func (c *Client) SendTyped(ctx context.Context, chatID, receiverUserID, messageType, message string) (*Ack, error) {
+	ack, err := c.writeAndWaitAck(ctx, outboundFrame{
+		Type:           "message",
+		ChatID:         chatID,
+		ReceiverUserID: receiverUserID,
+		MessageType:    messageType,
+		Message:        message,
+	})
+	if err != nil {
+		return ack, err
+	}
+	if ack.Error != "" {
+		return ack, &RejectedError{Code: ack.Code, Reason: ack.Error, RetryAfter: time.Duration(ack.RetryAfterMs) * time.Millisecond}
+	}
+	return ack, nil
+}
+
+// Reauthenticate hands the server a fresh token so it doesn't close the connection when
+// the current one expires. Reconnects always fetch a token from the TokenSource anyway.
+func (c *Client) Reauthenticate(ctx context.Context, token string) error {
+	_, err := c.writeAndWaitAck(ctx, outboundFrame{Type: "auth", Message: token})
+	return err
+}
+
+// writeAndWaitAck writes a frame with a new client_msg_id and waits for its ack
+func (c *Client) writeAndWaitAck(ctx context.Context, frame outboundFrame) (*Ack, error) {
+	frame.ClientMsgID = uuid.New().String()
+	ackChannel := make(chan Ack, 1)
+
+	c.mutex.Lock()
+	c.pendingAcks[frame.ClientMsgID] = ackChannel
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pendingAcks, frame.ClientMsgID)
+		c.mutex.Unlock()
+	}()
+
+	if err := c.write(frame); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(c.config.AckTimeout)
+	defer timer.Stop()
+	select {
+	case ack := <-ackChannel:
+		return &ack, nil
+	case <-timer.C:
+		return nil, ErrAckTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// MarkRead tells the server the user has read a message; its sender gets a read receipt
+func (c *Client) MarkRead(message Message) error {
+	return c.write(outboundFrame{Type: "read", ChatID: message.ChatID, EventID: message.EventID})
+}
+
+func (c *Client) write(frame outboundFrame) error {
+	c.mutex.Lock()
+	conn := c.conn
+	c.mutex.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	data, err := c.codec.Encode(frame)
+	if err != nil {
+		return err
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+	return conn.WriteMessage(c.codec.FrameType(), data)
+}
+
+// resumeFrom is the sequence a reconnect resumes from. Once this client has delivered
+// messages it backs off by resumeOverlap, so one that arrived late isn't skipped;
+// the overlap is dropped by event ID.
+func (c *Client) resumeFrom() int64 {
+	from := c.lastSequence.Load()
+	if c.seen.len() > 0 {
+		from = max(from-resumeOverlap, 0)
+	}
+	return from
+}
+
+// dial opens one connection, resuming from the last seen sequence when there is one
+func (c *Client) dial(ctx context.Context) (*websocket.Conn, error) {
+	endpoint, err := url.Parse(c.config.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+	// With a token the server takes the user from its subject, the path is only a cross-check
+	endpoint = endpoint.JoinPath("ws", "user", c.config.UserID)
+
+	query := endpoint.Query()
+	if c.lastSequence.Load() > 0 || c.config.ResumeFrom == 0 {
+		query.Set("resume_from", strconv.FormatInt(c.resumeFrom(), 10))
+	}
+	endpoint.RawQuery = query.Encode()
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: c.config.WriteTimeout,
+		Subprotocols:     []string{c.config.Subprotocol},
+	}
+	header, err := c.header(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, _, err := dialer.DialContext(ctx, endpoint.String(), header)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// header returns the configured headers plus the bearer token, asking the TokenSource when there is one
+func (c *Client) header(ctx context.Context) (http.Header, error) {
+	header := c.config.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	token := c.config.Token
+	if c.config.TokenSource != nil {
+		var err error
+		if token, err = c.config.TokenSource(ctx); err != nil {
+			return nil, fmt.Errorf("chatclient: fetching token: %w", err)
+		}
+	}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return header, nil
+}
+
+// run serves connections until the client is closed, reconnecting with backoff
+func (c *Client) run(ctx context.Context, conn *websocket.Conn) {
+	for {
+		err := c.serve(conn)
+		c.disconnected(err)
+
+		conn = c.reconnect(ctx)
+		if conn == nil {
+			return
+		}
+		c.attach(conn)
+	}
+}
+
+// reconnect dials until it succeeds, returning nil once the client is closed
+func (c *Client) reconnect(ctx context.Context) *websocket.Conn {
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-time.After(c.backoff(attempt)):
+		case <-c.closed:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+
+		conn, err := c.dial(ctx)
+		if err == nil {
+			return conn
+		}
+		log.Printf("chatclient: reconnect attempt %d failed: %v", attempt+1, err)
+	}
+}
+
+// backoff returns an exponential delay for the given attempt with equal jitter,
+// i.e. uniformly between half and all of the capped exponential value
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.config.MinBackoff << min(attempt, 16)
+	if ceiling <= 0 || ceiling > c.config.MaxBackoff {
+		ceiling = c.config.MaxBackoff
+	}
+	half := ceiling / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// attach makes a freshly dialed connection the current one, so writes go through it
+func (c *Client) attach(conn *websocket.Conn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.conn = conn
+	close(c.connected)
+}
+
+// serve runs the heartbeat and read loop of one connection until it fails
+func (c *Client) serve(conn *websocket.Conn) error {
+	c.mutex.Lock()
+	onConnect := c.onConnect
+	c.mutex.Unlock()
+
+	// Any frame, including pongs, proves the server is alive
+	conn.SetReadDeadline(time.Now().Add(c.config.PongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(c.config.PongTimeout))
+	})
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(c.config.PongTimeout))
+		c.writeMutex.Lock()
+		defer c.writeMutex.Unlock()
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(c.config.WriteTimeout))
+	})
+
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go c.ping(conn, stopPing)
+
+	if onConnect != nil {
+		onConnect()
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(c.config.PongTimeout))
+
+		var frame inboundFrame
+		if err := c.codec.Decode(data, &frame); err != nil {
+			log.Printf("chatclient: dropping undecodable frame: %v", err)
+			continue
+		}
+		c.dispatch(frame)
+	}
+}
+
+func (c *Client) ping(conn *websocket.Conn, stop chan struct{}) {
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.writeMutex.Lock()
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.config.WriteTimeout))
+			c.writeMutex.Unlock()
+			if err != nil {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (c *Client) dispatch(frame inboundFrame) {
+	switch frame.Type {
+	case "message", "":
+		// Replays and live delivery may overlap by design; drop what was already handled
+		if frame.EventID != "" && !c.seen.add(frame.EventID) {
+			return
+		}
+		if frame.Sequence > c.lastSequence.Load() {
+			c.lastSequence.Store(frame.Sequence)
+		}
+
+		c.mutex.Lock()
+		onMessage := c.onMessage
+		c.mutex.Unlock()
+		if onMessage != nil {
+			onMessage(Message{
+				EventID:     frame.EventID,
+				ChatID:      frame.ChatID,
+				Sender:      frame.Sender,
+				MessageType: frame.MessageType,
+				Message:     frame.Message,
+				Sequence:    frame.Sequence,
+			})
+		}
+
+	case "ack":
+		c.mutex.Lock()
+		ackChannel, ok := c.pendingAcks[frame.ClientMsgID]
+		c.mutex.Unlock()
+		if ok {
+			ackChannel <- Ack{ClientMsgID: frame.ClientMsgID, EventID: frame.EventID, Error: frame.Error, Code: frame.Code, RetryAfterMs: frame.RetryAfterMs}
+		}
+
+	case "resumed":
+		if frame.LastSequence > c.lastSequence.Load() {
+			c.lastSequence.Store(frame.LastSequence)
+		}
+
+	case "receipt":
+		c.mutex.Lock()
+		onReceipt := c.onReceipt
+		c.mutex.Unlock()
+		if onReceipt != nil {
+			onReceipt(Receipt{Status: frame.Status, EventID: frame.EventID, ChatID: frame.ChatID, UserID: frame.UserID})
+		}
+
+	case "moderation":
+		c.mutex.Lock()
+		onModeration := c.onModeration
+		c.mutex.Unlock()
+		if onModeration != nil {
+			onModeration(ModerationNotice{Action: frame.Action, Reason: frame.Reason, EventID: frame.EventID, ChatID: frame.ChatID, Until: frame.Until})
+		}
+
+	case "error":
+		c.mutex.Lock()
+		onError := c.onError
+		c.mutex.Unlock()
+		if onError != nil {
+			onError(frame.Error)
+		}
+	}
+}
+
+func (c *Client) disconnected(err error) {
+	c.mutex.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = nil
+	c.connected = make(chan struct{})
+	onDisconnect := c.onDisconnect
+	c.mutex.Unlock()
+
+	if onDisconnect != nil {
+		onDisconnect(err)
+	}
+}
+
+const (
+	maxSeenEvents = 1024 // Must stay well above resumeOverlap
+	resumeOverlap = 64
+)
+
+// eventSet remembers the last event IDs added to it, forgetting the oldest first
+type eventSet struct {
+	ids   map[string]struct{}
+	order []string // Ring buffer of ids in insertion order
+	next  int
+}
+
+func newEventSet(size int) *eventSet {
+	return &eventSet{ids: make(map[string]struct{}, size), order: make([]string, 0, size)}
+}
+
+// add records an event ID, reporting false when it was already there
+func (s *eventSet) add(id string) bool {
+	if _, exists := s.ids[id]; exists {
+		return false
+	}
+	if len(s.order) < cap(s.order) {
+		s.order = append(s.order, id)
+	} else {
+		delete(s.ids, s.order[s.next])
+		s.order[s.next] = id
+		s.next = (s.next + 1) % len(s.order)
+	}
+	s.ids[id] = struct{}{}
+	return true
+}
+
+func (s *eventSet) len() int {
+	return len(s.ids)
+}
@@ -0,0 +1,573 @@
+package chatclient
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/handlers"
+	"distributed-chat-system/internal/services"
+	"distributed-chat-system/pkg/jwt"
+	"distributed-chat-system/pkg/kms"
+	"distributed-chat-system/pkg/redis"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var testSecret = []byte("chatclient-test-secret")
+
+// fakeKafka hands published messages to the consumers of their topic in order,
+// standing in for the brokers between chat servers
+type fakeKafka struct {
+	mutex  sync.Mutex
+	topics map[string]chan string
+}
+
+func (k *fakeKafka) topic(name string) chan string {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if k.topics == nil {
+		k.topics = make(map[string]chan string)
+	}
+	if _, exists := k.topics[name]; !exists {
+		k.topics[name] = make(chan string, 1024)
+	}
+	return k.topics[name]
+}
+
+func (k *fakeKafka) PublishMessage(topic, receiverID, message string) error {
+	k.topic(topic) <- message
+	return nil
+}
+
+func (k *fakeKafka) ConsumeMessages(ctx context.Context, topic string, handler func(message string)) error {
+	messages := k.topic(topic)
+	go func() {
+		for {
+			select {
+			case message := <-messages:
+				handler(message)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (k *fakeKafka) ConsumeMessagesWithGroup(ctx context.Context, topic, groupID string, handler func(message string)) error {
+	return k.ConsumeMessages(ctx, topic, handler)
+}
+
+// testServer is the real WebSocketHandler and chat services over an in-memory Redis
+// and Kafka. Every connection goes through a proxy that can silence it.
+type testServer struct {
+	handler     *handlers.WebSocketHandler
+	memberships *services.MembershipService
+	url         string // ws:// URL of the proxy
+
+	refuse   atomic.Int32 // Upgrades still to answer with 503
+	mutex    sync.Mutex
+	attempts []time.Time // When each upgrade request arrived
+	proxied  []*proxiedConn
+}
+
+func newTestServer(t *testing.T, config *handlers.WebSocketConfig) *testServer {
+	t.Helper()
+	t.Setenv("SERVER_ID", "test-server")
+	gin.SetMode(gin.TestMode)
+
+	mini := miniredis.RunT(t)
+	redisRepo := redis.NewRedisRepositories(goredis.NewClient(&goredis.Options{Addr: mini.Addr()}))
+	keys, err := kms.NewLocalKMS(filepath.Join(t.TempDir(), "kms-keys.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryption := services.NewEncryptionService(redisRepo, keys, services.EncryptionConfigFromEnv())
+	memberships := services.NewMembershipService(redisRepo)
+	sanctions := services.NewSanctionService(redisRepo)
+	botService := services.NewBotService(redisRepo)
+	rateLimiter := services.NewRateLimitService(redisRepo, services.RateLimitConfigFromEnv())
+	policy := services.PolicyChain{
+		services.NewSanctionPolicy(sanctions),
+		services.NewMembershipPolicy(memberships),
+		services.NewBlockListPolicy(services.NewBlockService(redisRepo)),
+	}
+	chatService := services.NewChatMessageService(&fakeKafka{}, redisRepo, botService,
+		services.NewInboxService(redisRepo, services.InboxConfigFromEnv(), encryption), memberships,
+		services.NewPushService(redisRepo, nil, services.PushConfigFromEnv()), policy, rateLimiter,
+		services.NewModerationService(redisRepo, services.ModerationConfigFromEnv(), encryption))
+	chatService.StartMessageConsumption()
+
+	authService, err := services.NewAuthService(&services.AuthConfig{Secret: string(testSecret), Leeway: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := handlers.InitWebSocketHandler(chatService, botService, authService, rateLimiter, sanctions,
+		services.NewServiceAccountService(redisRepo), handlers.NewOriginHandler(&handlers.OriginConfig{}), config)
+
+	router := gin.New()
+	router.GET("/ws/user/:user_id", handler.InitWebSocket)
+	server := &testServer{handler: handler, memberships: memberships}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		server.attempts = append(server.attempts, time.Now())
+		server.mutex.Unlock()
+		if server.refuse.Add(-1) >= 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(backend.Close)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.proxy(listener, strings.TrimPrefix(backend.URL, "http://"))
+	server.url = "ws://" + listener.Addr().String()
+	return server
+}
+
+// proxiedConn forwards bytes between a client and the backend until it is silenced,
+// after which it swallows them, like a peer that vanished without closing
+type proxiedConn struct {
+	silenced atomic.Bool
+}
+
+func (s *testServer) proxy(listener net.Listener, backendAddr string) {
+	for {
+		client, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		backend, err := net.Dial("tcp", backendAddr)
+		if err != nil {
+			client.Close()
+			continue
+		}
+		proxied := &proxiedConn{}
+		s.mutex.Lock()
+		s.proxied = append(s.proxied, proxied)
+		s.mutex.Unlock()
+		go proxied.pipe(client, backend)
+		go proxied.pipe(backend, client)
+	}
+}
+
+func (p *proxiedConn) pipe(from, to net.Conn) {
+	defer from.Close()
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := from.Read(buffer)
+		if err != nil {
+			// A silenced connection doesn't pass on the close either
+			if !p.silenced.Load() {
+				to.Close()
+			}
+			return
+		}
+		if p.silenced.Load() {
+			continue
+		}
+		if _, err := to.Write(buffer[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// silence makes every open connection go quiet without closing it
+func (s *testServer) silence() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, proxied := range s.proxied {
+		proxied.silenced.Store(true)
+	}
+}
+
+func (s *testServer) attemptTimes() []time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]time.Time(nil), s.attempts...)
+}
+
+// testClient collects what a Client receives
+type testClient struct {
+	*Client
+	messages    chan Message
+	connects    chan struct{}
+	disconnects chan error
+}
+
+func (s *testServer) connect(t *testing.T, userID string, configure func(*Config)) *testClient {
+	t.Helper()
+	token, err := jwt.SignHS256(jwt.Claims{Subject: userID, ExpiresAt: time.Now().Add(time.Hour).Unix()}, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultConfig(s.url, userID)
+	config.Token = token
+	config.MinBackoff = 50 * time.Millisecond
+	config.MaxBackoff = 400 * time.Millisecond
+	config.AckTimeout = 2 * time.Second
+	if configure != nil {
+		configure(config)
+	}
+
+	client := &testClient{
+		Client:      New(config),
+		messages:    make(chan Message, 100),
+		connects:    make(chan struct{}, 100),
+		disconnects: make(chan error, 100),
+	}
+	client.OnMessage(func(message Message) { client.messages <- message })
+	client.OnConnect(func() { client.connects <- struct{}{} })
+	client.OnDisconnect(func(err error) { client.disconnects <- err })
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect %s: %v", userID, err)
+	}
+	t.Cleanup(func() { client.Close() })
+	client.awaitConnect(t)
+	// The socket registers with the chat service right after the upgrade
+	time.Sleep(50 * time.Millisecond)
+	return client
+}
+
+func (c *testClient) awaitConnect(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.connects:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not connect")
+	}
+}
+
+func (c *testClient) awaitDisconnect(t *testing.T, within time.Duration) error {
+	t.Helper()
+	select {
+	case err := <-c.disconnects:
+		return err
+	case <-time.After(within):
+		t.Fatalf("client did not notice the connection dropping within %s", within)
+		return nil
+	}
+}
+
+func (c *testClient) awaitMessage(t *testing.T) Message {
+	t.Helper()
+	select {
+	case message := <-c.messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message delivered")
+		return Message{}
+	}
+}
+
+func (c *testClient) expectNoMessage(t *testing.T) {
+	t.Helper()
+	select {
+	case message := <-c.messages:
+		t.Fatalf("unexpected message %+v", message)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+var directChat = DirectChatID("alice", "bob")
+
+func TestSendAckRoundTrip(t *testing.T) {
+	server := newTestServer(t, handlers.DefaultWebSocketConfig())
+	alice := server.connect(t, "alice", nil)
+	bob := server.connect(t, "bob", nil)
+
+	ack, err := alice.Send(context.Background(), directChat, "bob", "hello bob")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if ack.EventID == "" || ack.ClientMsgID == "" {
+		t.Fatalf("ack without ids: %+v", ack)
+	}
+
+	message := bob.awaitMessage(t)
+	if message.EventID != ack.EventID || message.Sender != "alice" || message.Message != "hello bob" || message.ChatID != directChat {
+		t.Errorf("bob got %+v, want event %s from alice", message, ack.EventID)
+	}
+	if message.Sequence != 1 || bob.LastSequence() != 1 {
+		t.Errorf("sequence = %d, last sequence = %d, want 1", message.Sequence, bob.LastSequence())
+	}
+}
+
+func TestSendRejectedByPolicy(t *testing.T) {
+	server := newTestServer(t, handlers.DefaultWebSocketConfig())
+	alice := server.connect(t, "alice", nil)
+	for _, member := range []string{"bob", "carol"} {
+		if err := server.memberships.AddMember("team", member); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		chatID string
+	}{
+		{"group of others", "team"},
+		{"direct chat of others", DirectChatID("bob", "carol")},
+		{"chat without members", "chat-1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ack, err := alice.Send(context.Background(), test.chatID, "bob", "not my chat")
+			var rejected *RejectedError
+			if !errors.As(err, &rejected) {
+				t.Fatalf("Send error = %v, want a RejectedError", err)
+			}
+			if ack == nil || ack.Code == "" || rejected.Code != ack.Code {
+				t.Errorf("ack = %+v, rejection = %+v", ack, rejected)
+			}
+		})
+	}
+}
+
+func TestReconnectBacksOffWithJitter(t *testing.T) {
+	server := newTestServer(t, handlers.DefaultWebSocketConfig())
+	alice := server.connect(t, "alice", nil)
+	bob := server.connect(t, "bob", nil)
+
+	// Three upgrades fail before the fourth succeeds
+	server.refuse.Store(3)
+	before := len(server.attemptTimes())
+	server.handler.Disconnect("bob", "test restart")
+	bob.awaitDisconnect(t, 2*time.Second)
+	bob.awaitConnect(t)
+
+	attempts := server.attemptTimes()[before:]
+	if len(attempts) != 4 {
+		t.Fatalf("%d upgrade attempts, want 4", len(attempts))
+	}
+	// Attempt n waits at least half of MinBackoff*2^n
+	for i := 1; i < len(attempts); i++ {
+		floor := (50 * time.Millisecond << i) / 2
+		if gap := attempts[i].Sub(attempts[i-1]); gap < floor-10*time.Millisecond {
+			t.Errorf("attempt %d came %s after the previous one, want at least %s", i+1, gap, floor)
+		}
+	}
+
+	// The reconnected client works as before
+	time.Sleep(50 * time.Millisecond)
+	ack, err := alice.Send(context.Background(), directChat, "bob", "welcome back")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if message := bob.awaitMessage(t); message.EventID != ack.EventID {
+		t.Errorf("bob got %+v, want event %s", message, ack.EventID)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	client := New(&Config{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond, 800 * time.Millisecond},
+		{4, 500 * time.Millisecond, time.Second}, // Capped at MaxBackoff
+		{40, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		distinct := make(map[time.Duration]bool)
+		for i := 0; i < 50; i++ {
+			delay := client.backoff(tt.attempt)
+			if delay < tt.min || delay > tt.max {
+				t.Errorf("backoff(%d) = %s, want within [%s, %s]", tt.attempt, delay, tt.min, tt.max)
+			}
+			distinct[delay] = true
+		}
+		if len(distinct) < 2 {
+			t.Errorf("backoff(%d) is not jittered", tt.attempt)
+		}
+	}
+}
+
+func TestResumeFromSequence(t *testing.T) {
+	server := newTestServer(t, handlers.DefaultWebSocketConfig())
+	alice := server.connect(t, "alice", nil)
+	bob := server.connect(t, "bob", func(config *Config) { config.ResumeFrom = 0 })
+
+	first, err := alice.Send(context.Background(), directChat, "bob", "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.awaitMessage(t)
+	resumeFrom := bob.LastSequence()
+	bob.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	// Sent while bob is offline, so they wait in his inbox
+	var missed []string
+	for _, text := range []string{"two", "three"} {
+		ack, err := alice.Send(context.Background(), directChat, "bob", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		missed = append(missed, ack.EventID)
+	}
+
+	bob = server.connect(t, "bob", func(config *Config) { config.ResumeFrom = resumeFrom })
+	for i, eventID := range missed {
+		message := bob.awaitMessage(t)
+		if message.EventID != eventID || message.Sequence != resumeFrom+int64(i)+1 {
+			t.Errorf("replayed %+v, want event %s with sequence %d", message, eventID, resumeFrom+int64(i)+1)
+		}
+		if message.EventID == first.EventID {
+			t.Errorf("message %s before the resume point was replayed", first.EventID)
+		}
+	}
+	bob.expectNoMessage(t)
+	if bob.LastSequence() != resumeFrom+2 {
+		t.Errorf("last sequence = %d, want %d", bob.LastSequence(), resumeFrom+2)
+	}
+}
+
+func TestResumeAfterReconnect(t *testing.T) {
+	server := newTestServer(t, handlers.DefaultWebSocketConfig())
+	alice := server.connect(t, "alice", nil)
+	bob := server.connect(t, "bob", func(config *Config) { config.MinBackoff = 300 * time.Millisecond })
+
+	if _, err := alice.Send(context.Background(), directChat, "bob", "before"); err != nil {
+		t.Fatal(err)
+	}
+	bob.awaitMessage(t)
+
+	// Sent during the reconnect backoff and replayed once bob is back, exactly once
+	server.handler.Disconnect("bob", "test restart")
+	bob.awaitDisconnect(t, 2*time.Second)
+	time.Sleep(50 * time.Millisecond)
+	ack, err := alice.Send(context.Background(), directChat, "bob", "during")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.awaitConnect(t)
+	if message := bob.awaitMessage(t); message.EventID != ack.EventID {
+		t.Errorf("bob got %+v, want event %s", message, ack.EventID)
+	}
+	bob.expectNoMessage(t)
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	// The server pings often but waits long for pongs, so the client is the first to notice
+	config := handlers.DefaultWebSocketConfig()
+	config.PingInterval = 100 * time.Millisecond
+	config.PongTimeout = 5 * time.Second
+	server := newTestServer(t, config)
+	bob := server.connect(t, "bob", func(config *Config) {
+		config.PingInterval = 100 * time.Millisecond
+		config.PongTimeout = 300 * time.Millisecond
+	})
+
+	// Heartbeats keep an idle connection up
+	select {
+	case err := <-bob.disconnects:
+		t.Fatalf("idle connection dropped: %v", err)
+	case <-time.After(time.Second):
+	}
+
+	// Once the server goes silent the client gives up after PongTimeout and reconnects
+	silencedAt := time.Now()
+	server.silence()
+	err := bob.awaitDisconnect(t, 2*time.Second)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("disconnect error = %v, want a read timeout", err)
+	}
+	// The last ping arrived up to one server ping interval before the silence
+	if waited := time.Since(silencedAt); waited < 200*time.Millisecond {
+		t.Errorf("gave up after %s, before PongTimeout", waited)
+	}
+	bob.awaitConnect(t)
+
+	alice := server.connect(t, "alice", nil)
+	ack, err := alice.Send(context.Background(), directChat, "bob", "still there?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message := bob.awaitMessage(t); message.EventID != ack.EventID {
+		t.Errorf("bob got %+v, want event %s", message, ack.EventID)
+	}
+}
+
+func TestEventSetForgetsOldest(t *testing.T) {
+	seen := newEventSet(2)
+	for _, step := range []struct {
+		id    string
+		added bool
+	}{
+		{"a", true}, {"b", true}, {"a", false}, {"c", true}, {"a", true}, {"c", false},
+	} {
+		if added := seen.add(step.id); added != step.added {
+			t.Errorf("add(%q) = %v, want %v", step.id, added, step.added)
+		}
+	}
+	if seen.len() != 2 {
+		t.Errorf("len = %d, want 2", seen.len())
+	}
+}
+
+func TestReadReceiptGoesToStoredSender(t *testing.T) {
+	server := newTestServer(t, handlers.DefaultWebSocketConfig())
+	alice := server.connect(t, "alice", nil)
+	bob := server.connect(t, "bob", nil)
+	carol := server.connect(t, "carol", nil)
+	receipts := map[string]chan Receipt{"alice": make(chan Receipt, 10), "carol": make(chan Receipt, 10)}
+	alice.OnReceipt(func(receipt Receipt) { receipts["alice"] <- receipt })
+	carol.OnReceipt(func(receipt Receipt) { receipts["carol"] <- receipt })
+
+	ack, err := alice.Send(context.Background(), directChat, "bob", "read me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := bob.awaitMessage(t)
+
+	// A read frame naming someone else, or an event bob never received, sends nothing
+	if err := bob.write(outboundFrame{Type: "read", EventID: ack.EventID, ReceiverUserID: "carol"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.write(outboundFrame{Type: "read", EventID: "not-in-inbox", ReceiverUserID: "carol"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.MarkRead(message); err != nil {
+		t.Fatal(err)
+	}
+
+	var read []Receipt
+	deadline := time.After(time.Second)
+	for len(read) < 2 {
+		select {
+		case receipt := <-receipts["alice"]:
+			if receipt.Status == "read" {
+				read = append(read, receipt)
+			}
+		case receipt := <-receipts["carol"]:
+			t.Fatalf("carol got a receipt for a message she didn't send: %+v", receipt)
+		case <-deadline:
+			t.Fatalf("alice got %d read receipts, want 2 (forged recipient and MarkRead)", len(read))
+		}
+	}
+	for _, receipt := range read {
+		if receipt.EventID != ack.EventID || receipt.UserID != "bob" || receipt.ChatID != directChat {
+			t.Errorf("receipt = %+v, want bob reading %s", receipt, ack.EventID)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package chatclient
+
+import (
+	"context"
+	"distributed-chat-system/internal/apis/codecs"
+	"net/http"
+	"time"
+)
+
+type Config struct {
+	ServerURL    string        // Base WebSocket URL, e.g. ws://localhost:8080
+	UserID       string        // User to connect as, may be empty when a token is set
+	Token        string        // Signed token sent as Authorization: Bearer on every handshake
+	TokenSource  TokenSource   // Takes precedence over Token, called before every (re)connect
+	Header       http.Header   // Extra handshake headers
+	Subprotocol  string        // Frame encoding, one of the chat.v1.* subprotocols
+	MinBackoff   time.Duration // First reconnect delay
+	MaxBackoff   time.Duration // Upper bound of the reconnect delay
+	PingInterval time.Duration // How often the client pings the server
+	PongTimeout  time.Duration // Connection is considered dead without any frame for this long
+	WriteTimeout time.Duration // Deadline for each frame write
+	AckTimeout   time.Duration // How long Send waits for the server ack
+	ResumeFrom   int64         // Sequence to resume from on the first connect, -1 for live only
+}
+
+// TokenSource returns a currently valid token, e.g. by refreshing it with an identity provider
+type TokenSource func(ctx context.Context) (string, error)
+
+// DefaultConfig provides a default client configuration
+func DefaultConfig(serverURL, userID string) *Config {
+	return &Config{
+		ServerURL:    serverURL,
+		UserID:       userID,
+		Header:       http.Header{},
+		Subprotocol:  codecs.JSONSubprotocol,
+		MinBackoff:   500 * time.Millisecond,
+		MaxBackoff:   30 * time.Second,
+		PingInterval: 20 * time.Second,
+		PongTimeout:  60 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		AckTimeout:   10 * time.Second,
+		ResumeFrom:   -1,
+	}
+}
@@ -0,0 +1,73 @@
+package chatclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// MessageTypeCiphertext is routed by the server without being read, stored or logged.
+// Ciphertext only reaches receivers who are connected; Send fails for offline ones.
+const MessageTypeCiphertext = "ciphertext"
+
+// PreKey is a public prekey, base64 encoded
+type PreKey struct {
+	KeyID     int64  `json:"key_id"`
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// DeviceKeys are the public keys a device publishes to the key directory
+type DeviceKeys struct {
+	IdentityKey    string   `json:"identity_key"`
+	SignedPreKey   PreKey   `json:"signed_pre_key"`
+	OneTimePreKeys []PreKey `json:"one_time_pre_keys,omitempty"`
+}
+
+// PreKeyBundle is what a peer's device offers to start an encrypted session
+type PreKeyBundle struct {
+	DeviceID      string  `json:"device_id"`
+	IdentityKey   string  `json:"identity_key"`
+	SignedPreKey  PreKey  `json:"signed_pre_key"`
+	OneTimePreKey *PreKey `json:"one_time_pre_key,omitempty"` // Nil once the device ran out
+}
+
+var errNoUserID = errors.New("chatclient: Config.UserID is required to publish keys")
+
+// PublishKeys publishes this user's device keys, returning how many one-time prekeys
+// the server holds for the device
+func (c *Client) PublishKeys(ctx context.Context, deviceID string, keys DeviceKeys) (int64, error) {
+	if c.config.UserID == "" {
+		return 0, errNoUserID
+	}
+	var published struct {
+		OneTimePreKeys int64 `json:"one_time_pre_keys"`
+	}
+	err := c.restCall(ctx, http.MethodPut, keys, &published, "users", c.config.UserID, "keys", deviceID)
+	return published.OneTimePreKeys, err
+}
+
+// UploadPreKeys tops up the one-time prekeys of a device
+func (c *Client) UploadPreKeys(ctx context.Context, deviceID string, preKeys []PreKey) (int64, error) {
+	if c.config.UserID == "" {
+		return 0, errNoUserID
+	}
+	var uploaded struct {
+		OneTimePreKeys int64 `json:"one_time_pre_keys"`
+	}
+	body := struct {
+		OneTimePreKeys []PreKey `json:"one_time_pre_keys"`
+	}{preKeys}
+	err := c.restCall(ctx, http.MethodPost, body, &uploaded, "users", c.config.UserID, "keys", deviceID, "prekeys")
+	return uploaded.OneTimePreKeys, err
+}
+
+// FetchPreKeyBundles fetches a bundle for every device of a user. Each call consumes a
+// one-time prekey per device, so fetch only when starting a new session.
+func (c *Client) FetchPreKeyBundles(ctx context.Context, userID string) ([]PreKeyBundle, error) {
+	var bundles struct {
+		Devices []PreKeyBundle `json:"devices"`
+	}
+	err := c.restCall(ctx, http.MethodGet, nil, &bundles, "keys", userID)
+	return bundles.Devices, err
+}
@@ -0,0 +1,17 @@
+package chatclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// Presence asks the server whether a user is currently connected anywhere in the cluster
+func (c *Client) Presence(ctx context.Context, userID string) (bool, error) {
+	var presence struct {
+		Online bool `json:"online"`
+	}
+	if err := c.restCall(ctx, http.MethodGet, nil, &presence, "users", userID, "presence"); err != nil {
+		return false, err
+	}
+	return presence.Online, nil
+}
@@ -0,0 +1,23 @@
+package chatclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// Report describes a message this user received, by EventID, or another user to the moderators
+type Report struct {
+	EventID        string `json:"event_id,omitempty"`
+	ReportedUserID string `json:"reported_user_id,omitempty"`
+	Reason         string `json:"reason"`
+	Details        string `json:"details,omitempty"`
+}
+
+// Report files an abuse report, returning its id
+func (c *Client) Report(ctx context.Context, report Report) (string, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	err := c.restCall(ctx, http.MethodPost, report, &created, "reports")
+	return created.ID, err
+}
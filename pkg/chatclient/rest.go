@@ -0,0 +1,56 @@
+package chatclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// restCall sends a JSON request to the server's REST API and decodes the response into out
+func (c *Client) restCall(ctx context.Context, method string, body, out interface{}, path ...string) error {
+	endpoint, err := url.Parse(c.config.ServerURL)
+	if err != nil {
+		return err
+	}
+	// Same host, plain HTTP(S) instead of WS(S)
+	endpoint.Scheme = strings.Replace(endpoint.Scheme, "ws", "http", 1)
+	endpoint = endpoint.JoinPath(path...)
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, endpoint.String(), reader)
+	if err != nil {
+		return err
+	}
+	if request.Header, err = c.header(ctx); err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("chatclient: %s %s failed with status %d", method, endpoint.Path, response.StatusCode)
+	}
+	if out == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
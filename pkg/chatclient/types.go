@@ -0,0 +1,70 @@
+package chatclient
+
+// Message is a chat message delivered to this client
+type Message struct {
+	EventID     string `json:"event_id"`
+	ChatID      string `json:"chat_id"`
+	Sender      string `json:"sender"`
+	MessageType string `json:"message_type"`
+	Message     string `json:"message"`
+	Sequence    int64  `json:"sequence,omitempty"`
+}
+
+// Ack is the server's answer to a Send
+type Ack struct {
+	ClientMsgID  string `json:"client_msg_id"`
+	EventID      string `json:"event_id,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Code         string `json:"code,omitempty"` // Why the server rejected the message, e.g. not_member or rate_limited
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+// Receipt tells the client a message it sent was delivered or read
+type Receipt struct {
+	Status  string `json:"status"` // delivered or read
+	EventID string `json:"event_id"`
+	ChatID  string `json:"chat_id"`
+	UserID  string `json:"user_id"` // Who received or read the message
+}
+
+// ModerationNotice tells the client a moderator acted on this user or on a message it received
+type ModerationNotice struct {
+	Action  string `json:"action"` // delete_message, warn or mute
+	Reason  string `json:"reason,omitempty"`
+	EventID string `json:"event_id,omitempty"` // delete_message: the message to remove from view
+	ChatID  string `json:"chat_id,omitempty"`
+	Until   string `json:"until,omitempty"` // mute: RFC 3339 end, empty when permanent
+}
+
+// outboundFrame is every frame the client writes
+type outboundFrame struct {
+	Type           string `json:"type,omitempty"`
+	EventID        string `json:"event_id,omitempty"`
+	ClientMsgID    string `json:"client_msg_id,omitempty"`
+	ChatID         string `json:"chat_id"`
+	ReceiverUserID string `json:"receiver_user_id,omitempty"`
+	MessageType    string `json:"message_type,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// inboundFrame is the union of every frame the server writes, discriminated by Type
+type inboundFrame struct {
+	Type         string `json:"type"`
+	EventID      string `json:"event_id"`
+	ChatID       string `json:"chat_id"`
+	Sender       string `json:"sender"`
+	Status       string `json:"status"`
+	UserID       string `json:"user_id"`
+	MessageType  string `json:"message_type"`
+	Message      string `json:"message"`
+	Sequence     int64  `json:"sequence"`
+	ClientMsgID  string `json:"client_msg_id"`
+	Error        string `json:"error"`
+	Code         string `json:"code"`
+	RetryAfterMs int64  `json:"retry_after_ms"`
+	Replayed     int    `json:"replayed"`
+	LastSequence int64  `json:"last_sequence"`
+	Action       string `json:"action"`
+	Reason       string `json:"reason"`
+	Until        string `json:"until"`
+}
@@ -0,0 +1,208 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Supported signing algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+var (
+	ErrMalformed            = errors.New("token is malformed")
+	ErrUnsupportedAlgorithm = errors.New("token signing algorithm is not accepted")
+	ErrInvalidSignature     = errors.New("token signature is invalid")
+	ErrExpired              = errors.New("token has expired")
+	ErrNotYetValid          = errors.New("token is not valid yet")
+	ErrInvalidIssuer        = errors.New("token issuer is not accepted")
+	ErrInvalidAudience      = errors.New("token audience is not accepted")
+	ErrMissingSubject       = errors.New("token has no subject")
+)
+
+// Claims are the registered claims the chat servers look at, plus optional roles and tenant
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Tenant    string   `json:"tenant,omitempty"` // Organization the user belongs to in multi-tenant deployments
+}
+
+// Expiry returns the expiry time, zero when the token never expires
+func (c *Claims) Expiry() time.Time {
+	if c.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// Audience accepts both the string and the array form of the aud claim
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+type VerifierConfig struct {
+	Secret    []byte         // HS256 shared secret
+	PublicKey *rsa.PublicKey // RS256 key of the identity provider
+	Issuer    string         // Required iss, empty accepts any
+	Audience  string         // Required entry of aud, empty accepts any
+	Leeway    time.Duration  // Clock skew tolerated on exp and nbf
+}
+
+// Verifier checks signed tokens. Only algorithms with a configured key are accepted,
+// so a token can never pick a weaker algorithm than the server expects.
+type Verifier struct {
+	config *VerifierConfig
+}
+
+func NewVerifier(config *VerifierConfig) *Verifier {
+	return &Verifier{config: config}
+}
+
+// Verify checks the signature and the time, issuer and audience claims of a token
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var tokenHeader header
+	if err := decodeSegment(parts[0], &tokenHeader); err != nil {
+		return nil, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.verifySignature(tokenHeader.Algorithm, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.validate(&claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (v *Verifier) verifySignature(algorithm, signingInput string, signature []byte) error {
+	switch {
+	case algorithm == HS256 && len(v.config.Secret) > 0:
+		if !hmac.Equal(signature, hs256(v.config.Secret, signingInput)) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case algorithm == RS256 && v.config.PublicKey != nil:
+		digest := sha256.Sum256([]byte(signingInput))
+		if rsa.VerifyPKCS1v15(v.config.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return ErrUnsupportedAlgorithm
+}
+
+func (v *Verifier) validate(claims *Claims, now time.Time) error {
+	if claims.Subject == "" {
+		return ErrMissingSubject
+	}
+	if claims.ExpiresAt != 0 && now.After(time.Unix(claims.ExpiresAt, 0).Add(v.config.Leeway)) {
+		return ErrExpired
+	}
+	if claims.NotBefore != 0 && now.Add(v.config.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return ErrNotYetValid
+	}
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return ErrInvalidIssuer
+	}
+	if v.config.Audience != "" && !slices.Contains(claims.Audience, v.config.Audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// SignHS256 issues a token signed with a shared secret, for development tools and tests
+func SignHS256(claims Claims, secret []byte) (string, error) {
+	headerJson, err := json.Marshal(header{Algorithm: HS256, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claimsJson, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJson) + "." + base64.RawURLEncoding.EncodeToString(claimsJson)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(hs256(secret, signingInput)), nil
+}
+
+// ParseRSAPublicKey reads a PEM encoded PKIX or PKCS#1 RSA public key
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is %T, not RSA", key)
+	}
+	return rsaKey, nil
+}
+
+func hs256(secret []byte, signingInput string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
@@ -0,0 +1,184 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("test-secret")
+
+// sign builds a token with an arbitrary header, signed by the given function
+func sign(t *testing.T, algorithm string, claims Claims, signer func(signingInput string) []byte) string {
+	t.Helper()
+	headerJson, err := json.Marshal(header{Algorithm: algorithm, Type: "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimsJson, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJson) + "." + base64.RawURLEncoding.EncodeToString(claimsJson)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signer(signingInput))
+}
+
+func rs256Signer(t *testing.T, key *rsa.PrivateKey) func(string) []byte {
+	return func(signingInput string) []byte {
+		digest := sha256.Sum256([]byte(signingInput))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+}
+
+func hs256Signer(secret []byte) func(string) []byte {
+	return func(signingInput string) []byte { return hs256(secret, signingInput) }
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The public key as an identity provider publishes it, which an attacker can read
+	publicPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+	parsed, err := ParseRSAPublicKey(publicPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := Claims{Subject: "alice", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	hsOnly := &VerifierConfig{Secret: testSecret}
+	rsOnly := &VerifierConfig{PublicKey: parsed}
+	both := &VerifierConfig{Secret: testSecret, PublicKey: parsed}
+
+	tests := []struct {
+		name    string
+		config  *VerifierConfig
+		token   string
+		wantErr error
+	}{
+		{"hs256", hsOnly, sign(t, HS256, claims, hs256Signer(testSecret)), nil},
+		{"rs256", rsOnly, sign(t, RS256, claims, rs256Signer(t, key)), nil},
+		{"both keys accept rs256", both, sign(t, RS256, claims, rs256Signer(t, key)), nil},
+		{"hs256 with wrong secret", hsOnly, sign(t, HS256, claims, hs256Signer([]byte("guess"))), ErrInvalidSignature},
+		{"rs256 by another key", rsOnly, sign(t, RS256, claims, rs256Signer(t, otherKey)), ErrInvalidSignature},
+		{"hs256 keyed with the public key, rs256 only", rsOnly, sign(t, HS256, claims, hs256Signer(publicPem)), ErrUnsupportedAlgorithm},
+		{"hs256 keyed with the public key, both keys", both, sign(t, HS256, claims, hs256Signer(publicPem)), ErrInvalidSignature},
+		{"rs256 on hs256 only", hsOnly, sign(t, RS256, claims, rs256Signer(t, key)), ErrUnsupportedAlgorithm},
+		{"none", hsOnly, sign(t, "none", claims, func(string) []byte { return nil }), ErrUnsupportedAlgorithm},
+		{"none uppercase", both, sign(t, "NONE", claims, func(string) []byte { return nil }), ErrUnsupportedAlgorithm},
+		{"none with a signature", hsOnly, sign(t, "none", claims, hs256Signer(testSecret)), ErrUnsupportedAlgorithm},
+		{"empty algorithm", hsOnly, sign(t, "", claims, hs256Signer(testSecret)), ErrUnsupportedAlgorithm},
+		{"hs512", hsOnly, sign(t, "HS512", claims, hs256Signer(testSecret)), ErrUnsupportedAlgorithm},
+		{"two segments", hsOnly, "e30.e30", ErrMalformed},
+		{"header not json", hsOnly, "bm90IGpzb24.e30.AA", ErrMalformed},
+		{"signature not base64url", hsOnly, "e30.e30.***", ErrMalformed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewVerifier(test.config).Verify(test.token)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && got.Subject != claims.Subject {
+				t.Errorf("Verify() subject = %q, want %q", got.Subject, claims.Subject)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTamperedClaims(t *testing.T) {
+	token := strings.Split(sign(t, HS256, Claims{Subject: "alice"}, hs256Signer(testSecret)), ".")
+	forged := strings.Split(sign(t, HS256, Claims{Subject: "admin"}, hs256Signer([]byte("guess"))), ".")
+	// The claims of a forged token with the signature of a genuine one
+	tampered := token[0] + "." + forged[1] + "." + token[2]
+	if _, err := NewVerifier(&VerifierConfig{Secret: testSecret}).Verify(tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestValidateClaims(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	leeway := 30 * time.Second
+	config := &VerifierConfig{Secret: testSecret, Issuer: "https://id.example.com", Audience: "chat", Leeway: leeway}
+	valid := func(modify func(*Claims)) Claims {
+		claims := Claims{Subject: "alice", Issuer: "https://id.example.com", Audience: Audience{"chat"}}
+		if modify != nil {
+			modify(&claims)
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		config  *VerifierConfig
+		claims  Claims
+		wantErr error
+	}{
+		{"valid", config, valid(nil), nil},
+		{"no subject", config, valid(func(c *Claims) { c.Subject = "" }), ErrMissingSubject},
+		{"expires later", config, valid(func(c *Claims) { c.ExpiresAt = now.Add(time.Minute).Unix() }), nil},
+		{"expired within leeway", config, valid(func(c *Claims) { c.ExpiresAt = now.Add(-leeway + time.Second).Unix() }), nil},
+		{"expired at the leeway", config, valid(func(c *Claims) { c.ExpiresAt = now.Add(-leeway).Unix() }), nil},
+		{"expired past leeway", config, valid(func(c *Claims) { c.ExpiresAt = now.Add(-leeway - time.Second).Unix() }), ErrExpired},
+		{"expired without leeway", &VerifierConfig{}, valid(func(c *Claims) { c.ExpiresAt = now.Add(-time.Second).Unix() }), ErrExpired},
+		{"not before passed", config, valid(func(c *Claims) { c.NotBefore = now.Add(-time.Minute).Unix() }), nil},
+		{"not before within leeway", config, valid(func(c *Claims) { c.NotBefore = now.Add(leeway - time.Second).Unix() }), nil},
+		{"not before past leeway", config, valid(func(c *Claims) { c.NotBefore = now.Add(leeway + time.Second).Unix() }), ErrNotYetValid},
+		{"issuer mismatch", config, valid(func(c *Claims) { c.Issuer = "https://evil.example.com" }), ErrInvalidIssuer},
+		{"issuer missing", config, valid(func(c *Claims) { c.Issuer = "" }), ErrInvalidIssuer},
+		{"issuer prefix", config, valid(func(c *Claims) { c.Issuer = "https://id.example.com.evil" }), ErrInvalidIssuer},
+		{"any issuer accepted", &VerifierConfig{}, valid(func(c *Claims) { c.Issuer = "anyone" }), nil},
+		{"audience mismatch", config, valid(func(c *Claims) { c.Audience = Audience{"billing"} }), ErrInvalidAudience},
+		{"audience missing", config, valid(func(c *Claims) { c.Audience = nil }), ErrInvalidAudience},
+		{"audience among several", config, valid(func(c *Claims) { c.Audience = Audience{"billing", "chat"} }), nil},
+		{"any audience accepted", &VerifierConfig{}, valid(func(c *Claims) { c.Audience = Audience{"billing"} }), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := NewVerifier(test.config).validate(&test.claims, now); !errors.Is(err, test.wantErr) {
+				t.Fatalf("validate() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestAudienceForms(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Audience
+		wantErr bool
+	}{
+		{`"chat"`, Audience{"chat"}, false},
+		{`["chat","billing"]`, Audience{"chat", "billing"}, false},
+		{`[]`, Audience{}, false},
+		{`42`, nil, true},
+	}
+	for _, test := range tests {
+		var got Audience
+		err := json.Unmarshal([]byte(test.json), &got)
+		if (err != nil) != test.wantErr {
+			t.Fatalf("Unmarshal(%s) error = %v, want error %v", test.json, err, test.wantErr)
+		}
+		if err == nil && len(got) != len(test.want) {
+			t.Errorf("Unmarshal(%s) = %q, want %q", test.json, got, test.want)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package kafka
 
+import "crypto/tls"
+
 type Config struct {
-	Brokers    string   // Comma-separated list of Kafka brokers
-	GroupID    string   // Consumer group ID
-	Topics     []string // Topics to consume
-	AutoOffset string   // Auto offset reset (earliest/latest)
-	ProducerID string   // Producer ID for logging
+	Brokers    string      // Comma-separated list of Kafka brokers
+	GroupID    string      // Consumer group ID
+	Topics     []string    // Topics to consume
+	AutoOffset string      // Auto offset reset (earliest/latest)
+	ProducerID string      // Producer ID for logging
+	TLS        *tls.Config // Connects to the brokers over TLS when set
 }
 
 // DefaultConfig provides a default Kafka configuration
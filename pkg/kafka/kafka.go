@@ -5,10 +5,19 @@ import (
 	"distributed-chat-system/internal/constants"
 	"log"
 	"strings"
+	"time"
 
 	kafka "github.com/segmentio/kafka-go"
 )
 
+// IKafkaClient is what the chat services need from Kafka, so they can run against an
+// in-memory fake in tests
+type IKafkaClient interface {
+	PublishMessage(topic, receiverID, message string) error
+	ConsumeMessages(ctx context.Context, topic string, handler func(message string)) error
+	ConsumeMessagesWithGroup(ctx context.Context, topic, groupID string, handler func(message string)) error
+}
+
 type KafkaClient struct {
 	Producer     *kafka.Writer
 	Consumer     *kafka.Reader
@@ -25,6 +34,7 @@ func NewKafkaClient(cfg *Config) *KafkaClient {
 	producer := kafka.NewWriter(kafka.WriterConfig{
 		Brokers:  brokers,
 		Balancer: &kafka.LeastBytes{},
+		Dialer:   dialer(cfg),
 	})
 
 	return &KafkaClient{
@@ -34,6 +44,15 @@ func NewKafkaClient(cfg *Config) *KafkaClient {
 	}
 }
 
+// dialer connects to the brokers, over TLS when configured
+func dialer(cfg *Config) *kafka.Dialer {
+	return &kafka.Dialer{
+		Timeout:   10 * time.Second,
+		DualStack: true,
+		TLS:       cfg.TLS,
+	}
+}
+
 // PublishMessage sends a message to a Kafka topic
 func (k *KafkaClient) PublishMessage(topic, receiverID, message string) error {
 	err := k.Producer.WriteMessages(context.Background(),
@@ -54,6 +73,12 @@ func (k *KafkaClient) PublishMessage(topic, receiverID, message string) error {
 
 // ConsumeMessages starts consuming messages from a specified Kafka topic at runtime
 func (k *KafkaClient) ConsumeMessages(ctx context.Context, topic string, handler func(message string)) error {
+	return k.ConsumeMessagesWithGroup(ctx, topic, k.Config.GroupID, handler)
+}
+
+// ConsumeMessagesWithGroup consumes a topic under its own consumer group, so several
+// independent readers (e.g. chat delivery and webhooks) each see every message
+func (k *KafkaClient) ConsumeMessagesWithGroup(ctx context.Context, topic, groupID string, handler func(message string)) error {
 	// Convert comma-separated brokers string to a slice
 	brokers := strings.Split(k.Config.Brokers, ",")
 
@@ -61,11 +86,12 @@ func (k *KafkaClient) ConsumeMessages(ctx context.Context, topic string, handler
 	// Initialize Consumer dynamically for the topic
 	consumer := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:     brokers,
-		GroupID:     k.Config.GroupID,
+		GroupID:     groupID,
 		Topic:       topic,
 		MinBytes:    10e3, // 10KB
 		MaxBytes:    10e6, // 10MB
 		StartOffset: kafka.FirstOffset,
+		Dialer:      dialer(k.Config),
 	})
 
 	go func() {
@@ -81,7 +107,7 @@ func (k *KafkaClient) ConsumeMessages(ctx context.Context, topic string, handler
 					log.Printf("Consumer error for topic %s: %v\n", topic, err)
 					continue
 				}
-				log.Printf("Received message from topic %s: %s\n", m.Topic, string(m.Value))
+				log.Printf("Received message from topic %s (%d bytes)\n", m.Topic, len(m.Value))
 				handler(string(m.Value))
 			}
 		}
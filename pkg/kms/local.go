@@ -0,0 +1,225 @@
+package kms
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const masterKeySize = 32 // AES-256
+
+var ErrUnknownMasterKey = errors.New("unknown master key")
+
+// keyFile is the on-disk layout: every master key ever made, and which one wraps new data keys
+type keyFile struct {
+	Active string            `json:"active"`
+	Keys   map[string][]byte `json:"keys"` // Base64 in the file
+}
+
+// LocalKMS stands in for a key management service. Master keys are kept in a JSON
+// keyfile and never leave the process; callers only ever see data keys wrapped with
+// them. Retired master keys stay in the file so what they wrapped can still be unwrapped.
+// Servers sharing the keyfile pick up a rotation made by another one on the next unwrap.
+type LocalKMS struct {
+	path string
+
+	mutex sync.RWMutex
+	file  keyFile
+}
+
+// NewLocalKMS loads the keyfile at path. With create, a missing keyfile is created with a
+// first master key; servers creating it at once all use the one written first.
+func NewLocalKMS(path string, create bool) (*LocalKMS, error) {
+	k := &LocalKMS{path: path}
+	err := k.load()
+	if errors.Is(err, os.ErrNotExist) && create {
+		err = k.create()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// ActiveKeyID names the master key new data keys are wrapped with
+func (k *LocalKMS) ActiveKeyID() string {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.file.Active
+}
+
+// Wrap encrypts a data key with the active master key, returning the id of that key
+func (k *LocalKMS) Wrap(dataKey []byte) (string, []byte, error) {
+	k.mutex.RLock()
+	keyID, masterKey := k.file.Active, k.file.Keys[k.file.Active]
+	k.mutex.RUnlock()
+
+	gcm, err := newGCM(masterKey)
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return keyID, gcm.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+// Unwrap decrypts a data key wrapped with the named master key
+func (k *LocalKMS) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	masterKey, err := k.masterKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+	nonce, ciphertext := wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, []byte(keyID))
+}
+
+// RotateMasterKey adds a new master key to the keyfile and makes it the active one.
+// Data keys wrapped with the previous one stay readable until they are rewrapped.
+func (k *LocalKMS) RotateMasterKey() (string, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	// Start from the file, so a key another server added meanwhile is not lost
+	if err := k.loadLocked(); err != nil {
+		return "", err
+	}
+	return k.addMasterKeyLocked(false)
+}
+
+// create writes a keyfile with a first master key, unless another server just did
+func (k *LocalKMS) create() error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	log.Printf("KMS keyfile %s not found, creating it", k.path)
+	k.file = keyFile{Keys: make(map[string][]byte)}
+	_, err := k.addMasterKeyLocked(true)
+	if errors.Is(err, os.ErrExist) {
+		return k.loadLocked()
+	}
+	return err
+}
+
+func (k *LocalKMS) masterKey(keyID string) ([]byte, error) {
+	k.mutex.RLock()
+	masterKey, ok := k.file.Keys[keyID]
+	k.mutex.RUnlock()
+	if ok {
+		return masterKey, nil
+	}
+
+	// Another server may have rotated since the file was read
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	if masterKey, ok = k.file.Keys[keyID]; !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownMasterKey, keyID)
+	}
+	return masterKey, nil
+}
+
+func (k *LocalKMS) load() error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return k.loadLocked()
+}
+
+func (k *LocalKMS) loadLocked() error {
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid KMS keyfile %s: %w", k.path, err)
+	}
+	if _, ok := file.Keys[file.Active]; !ok {
+		return fmt.Errorf("invalid KMS keyfile %s: active key %q is missing", k.path, file.Active)
+	}
+	for id, key := range file.Keys {
+		if len(key) != masterKeySize {
+			return fmt.Errorf("invalid KMS keyfile %s: key %s is not %d bytes", k.path, id, masterKeySize)
+		}
+	}
+	k.file = file
+	return nil
+}
+
+// addMasterKeyLocked makes a new master key active. With exclusive the keyfile must not
+// exist yet, otherwise the error is os.ErrExist.
+func (k *LocalKMS) addMasterKeyLocked(exclusive bool) (string, error) {
+	id := make([]byte, 8)
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	file := keyFile{Active: hex.EncodeToString(id), Keys: make(map[string][]byte, len(k.file.Keys)+1)}
+	for existingID, existingKey := range k.file.Keys {
+		file.Keys[existingID] = existingKey
+	}
+	file.Keys[file.Active] = key
+	if err := writeKeyFile(k.path, file, exclusive); err != nil {
+		return "", err
+	}
+	k.file = file
+	log.Printf("KMS master key %s is now active", file.Active)
+	return file.Active, nil
+}
+
+// writeKeyFile replaces the keyfile in one rename, readable by the owner only. With
+// exclusive it is linked into place instead, which fails when the keyfile exists.
+func writeKeyFile(path string, file keyFile, exclusive bool) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if err := temp.Chmod(0600); err != nil {
+		temp.Close()
+		return err
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if exclusive {
+		return os.Link(temp.Name(), path)
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"log"
+)
+
+// Mail is a single outgoing email with a plain text and an optional HTML body
+type Mail struct {
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string // Sent as multipart/alternative when set
+}
+
+// Mailer sends emails. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// LogMailer writes emails to the log instead of sending them, for development
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	log.Println("🚀 Initialized Mailer : Log")
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(mail Mail) error {
+	log.Printf("Mail to %s: %s\n%s", mail.To, mail.Subject, mail.TextBody)
+	return nil
+}
@@ -0,0 +1,103 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+type HTTPConfig struct {
+	Endpoint string        // Push gateway URL notifications are POSTed to
+	APIKey   string        // Sent as a bearer token
+	Timeout  time.Duration // Timeout of a single request
+}
+
+// HTTPNotifier posts notifications to an FCM/APNs-style HTTP push gateway. The body is
+// an FCM message, and the collapse key is also sent in the apns-collapse-id header.
+type HTTPNotifier struct {
+	config     *HTTPConfig
+	httpClient *http.Client
+}
+
+func NewHTTPNotifier(config *HTTPConfig) *HTTPNotifier {
+	log.Println("🚀 Initialized Push Notifier : HTTP", config.Endpoint)
+	return &HTTPNotifier{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+type fcmMessage struct {
+	Message struct {
+		Token        string            `json:"token"`
+		Notification fcmNotification   `json:"notification"`
+		Data         map[string]string `json:"data,omitempty"`
+		Android      *fcmAndroid       `json:"android,omitempty"`
+		APNS         *fcmAPNS          `json:"apns,omitempty"`
+	} `json:"message"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type fcmAndroid struct {
+	CollapseKey string `json:"collapse_key,omitempty"`
+}
+
+type fcmAPNS struct {
+	Headers map[string]string      `json:"headers,omitempty"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+}
+
+func (n *HTTPNotifier) Push(ctx context.Context, notification Notification) error {
+	var message fcmMessage
+	message.Message.Token = notification.Token
+	message.Message.Notification = fcmNotification{Title: notification.Title, Body: notification.Body}
+	message.Message.Data = notification.Data
+	if notification.Platform == PlatformIOS {
+		message.Message.APNS = &fcmAPNS{
+			Headers: map[string]string{"apns-collapse-id": notification.CollapseKey},
+			Payload: map[string]interface{}{"aps": map[string]interface{}{"badge": notification.Badge}},
+		}
+	} else {
+		message.Message.Android = &fcmAndroid{CollapseKey: notification.CollapseKey}
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if n.config.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+n.config.APIKey)
+	}
+	if notification.Platform == PlatformIOS && notification.CollapseKey != "" {
+		request.Header.Set("apns-collapse-id", notification.CollapseKey)
+	}
+
+	response, err := n.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	switch {
+	case response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone:
+		return ErrInvalidToken
+	case response.StatusCode >= 300:
+		return fmt.Errorf("push gateway responded with status %d", response.StatusCode)
+	}
+	return nil
+}
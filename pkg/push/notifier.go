@@ -0,0 +1,45 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// Device platforms
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+)
+
+// ErrInvalidToken is returned when the push provider no longer accepts a device token
+var ErrInvalidToken = errors.New("device token is no longer valid")
+
+// Notification is a single push to one device
+type Notification struct {
+	Token       string            `json:"token"`
+	Platform    string            `json:"platform"`
+	Title       string            `json:"title"`
+	Body        string            `json:"body"`
+	CollapseKey string            `json:"collapse_key,omitempty"` // Newer pushes with the same key replace older ones on the device
+	Badge       int               `json:"badge,omitempty"`
+	Data        map[string]string `json:"data,omitempty"`
+}
+
+// PushNotifier delivers push notifications. Implementations must be safe for concurrent use.
+type PushNotifier interface {
+	Push(ctx context.Context, notification Notification) error
+}
+
+// LogNotifier writes pushes to the log instead of sending them, for development
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	log.Println("🚀 Initialized Push Notifier : Log")
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Push(ctx context.Context, notification Notification) error {
+	log.Printf("Push to %s device (collapse %s): %s", notification.Platform, notification.CollapseKey, notification.Title)
+	return nil
+}
@@ -2,13 +2,15 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 
 	"github.com/redis/go-redis/v9"
 )
 
-func RedisClient(redisHost, redisPort, redisUsername, redisPassword string) (*redis.Client, error) {
+// RedisClient connects to Redis, over TLS when tlsConfig is not nil
+func RedisClient(redisHost, redisPort, redisUsername, redisPassword string, tlsConfig *tls.Config) (*redis.Client, error) {
 	redisURL := fmt.Sprintf("%s:%s", redisHost, redisPort)
 
 	// Only set Username & password if authorization enabled
@@ -16,7 +18,8 @@ func RedisClient(redisHost, redisPort, redisUsername, redisPassword string) (*re
 		Addr: redisURL,
 		// Username: redisUsername,
 		// Password: redisPassword,
-		DB: 0,
+		DB:        0,
+		TLSConfig: tlsConfig,
 	})
 
 	if err := client.Ping(context.Background()).Err(); err != nil {
@@ -15,6 +15,12 @@ type RedisRepositories struct {
 	Client *redis.Client
 }
 
+// ScoredMember is a sorted set member with its score
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
 type IRedisRepositories interface {
 	Set(key string, data []byte, expiredTime time.Duration, ctx context.Context) error
 	Hset(key string, data string, expireAt time.Time, ctx context.Context) error
@@ -22,6 +28,29 @@ type IRedisRepositories interface {
 	Del(key string, ctx context.Context) error
 	GetAllByField(ctx context.Context, modelType interface{}, filterFunc func(interface{}) bool) ([]interface{}, error)
 	TTL(key string, ctx context.Context) (time.Duration, error)
+	Keys(pattern string, ctx context.Context) ([]string, error)
+	LPush(key string, data []byte, ctx context.Context) error
+	LRange(key string, start, stop int64, ctx context.Context) ([]string, error)
+	LTrim(key string, start, stop int64, ctx context.Context) error
+	LPop(key string, ctx context.Context) (string, error)
+	LRem(key string, count int64, data string, ctx context.Context) error
+	LLen(key string, ctx context.Context) (int64, error)
+	Incr(key string, ctx context.Context) (int64, error)
+	Expire(key string, expiredTime time.Duration, ctx context.Context) error
+	ZAdd(key string, score float64, data []byte, ctx context.Context) error
+	ZRangeByScore(key string, min, max string, ctx context.Context) ([]string, error)
+	ZRangeByScoreLimit(key string, min, max string, offset, count int64, ctx context.Context) ([]string, error)
+	ZRangeByScoreWithScores(key string, min, max string, offset, count int64, ctx context.Context) ([]ScoredMember, error)
+	ZRemRangeByRank(key string, start, stop int64, ctx context.Context) error
+	ZRemRangeByScore(key string, min, max string, ctx context.Context) error
+	HGet(key string, field string, ctx context.Context) (string, error)
+	HDel(key string, field string, ctx context.Context) error
+	SAdd(key string, member string, ctx context.Context) error
+	SRem(key string, member string, ctx context.Context) error
+	SMembers(key string, ctx context.Context) ([]string, error)
+	SIsMember(key string, member string, ctx context.Context) (bool, error)
+	SetNX(key string, data []byte, expiredTime time.Duration, ctx context.Context) (bool, error)
+	Eval(script string, keys []string, args []interface{}, ctx context.Context) (interface{}, error)
 }
 
 func NewRedisRepositories(client *redis.Client) *RedisRepositories {
@@ -118,3 +147,131 @@ func (r *RedisRepositories) TTL(key string, ctx context.Context) (time.Duration,
 	}
 	return duration, nil
 }
+
+// Keys returns every key matching a glob pattern, using SCAN so Redis is never blocked
+func (r *RedisRepositories) Keys(pattern string, ctx context.Context) ([]string, error) {
+	var keys []string
+	var cursor uint64
+
+	for {
+		batch, nextCursor, err := r.Client.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+
+		if nextCursor == 0 {
+			break
+		}
+		cursor = nextCursor
+	}
+	return keys, nil
+}
+
+func (r *RedisRepositories) LPush(key string, data []byte, ctx context.Context) error {
+	return r.Client.LPush(ctx, key, data).Err()
+}
+
+func (r *RedisRepositories) LRange(key string, start, stop int64, ctx context.Context) ([]string, error) {
+	return r.Client.LRange(ctx, key, start, stop).Result()
+}
+
+func (r *RedisRepositories) LTrim(key string, start, stop int64, ctx context.Context) error {
+	return r.Client.LTrim(ctx, key, start, stop).Err()
+}
+
+// LPop removes and returns the first element of a list, redis.Nil when it is empty
+func (r *RedisRepositories) LPop(key string, ctx context.Context) (string, error) {
+	return r.Client.LPop(ctx, key).Result()
+}
+
+// LRem removes entries equal to data, the first count of them, or all when count is 0
+func (r *RedisRepositories) LRem(key string, count int64, data string, ctx context.Context) error {
+	return r.Client.LRem(ctx, key, count, data).Err()
+}
+
+func (r *RedisRepositories) LLen(key string, ctx context.Context) (int64, error) {
+	return r.Client.LLen(ctx, key).Result()
+}
+
+func (r *RedisRepositories) Incr(key string, ctx context.Context) (int64, error) {
+	return r.Client.Incr(ctx, key).Result()
+}
+
+func (r *RedisRepositories) Expire(key string, expiredTime time.Duration, ctx context.Context) error {
+	return r.Client.Expire(ctx, key, expiredTime).Err()
+}
+
+func (r *RedisRepositories) ZAdd(key string, score float64, data []byte, ctx context.Context) error {
+	return r.Client.ZAdd(ctx, key, redis.Z{Score: score, Member: data}).Err()
+}
+
+// ZRangeByScore returns members ordered by score, min and max accept Redis syntax such as "(5" or "+inf"
+func (r *RedisRepositories) ZRangeByScore(key string, min, max string, ctx context.Context) ([]string, error) {
+	return r.Client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: min, Max: max}).Result()
+}
+
+// ZRangeByScoreLimit is ZRangeByScore returning at most count members, after skipping offset
+func (r *RedisRepositories) ZRangeByScoreLimit(key string, min, max string, offset, count int64, ctx context.Context) ([]string, error) {
+	return r.Client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: count}).Result()
+}
+
+// ZRangeByScoreWithScores is ZRangeByScoreLimit returning the members with their scores
+func (r *RedisRepositories) ZRangeByScoreWithScores(key string, min, max string, offset, count int64, ctx context.Context) ([]ScoredMember, error) {
+	result, err := r.Client.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: count}).Result()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]ScoredMember, 0, len(result))
+	for _, z := range result {
+		member, _ := z.Member.(string)
+		members = append(members, ScoredMember{Member: member, Score: z.Score})
+	}
+	return members, nil
+}
+
+func (r *RedisRepositories) ZRemRangeByRank(key string, start, stop int64, ctx context.Context) error {
+	return r.Client.ZRemRangeByRank(ctx, key, start, stop).Err()
+}
+
+func (r *RedisRepositories) ZRemRangeByScore(key string, min, max string, ctx context.Context) error {
+	return r.Client.ZRemRangeByScore(ctx, key, min, max).Err()
+}
+
+func (r *RedisRepositories) HGet(key string, field string, ctx context.Context) (string, error) {
+	result, err := r.Client.HGet(ctx, key, field).Result()
+	if err == redis.Nil {
+		return "", errors.New("field does not exist")
+	}
+	return result, err
+}
+
+func (r *RedisRepositories) HDel(key string, field string, ctx context.Context) error {
+	return r.Client.HDel(ctx, key, field).Err()
+}
+
+func (r *RedisRepositories) SAdd(key string, member string, ctx context.Context) error {
+	return r.Client.SAdd(ctx, key, member).Err()
+}
+
+func (r *RedisRepositories) SRem(key string, member string, ctx context.Context) error {
+	return r.Client.SRem(ctx, key, member).Err()
+}
+
+func (r *RedisRepositories) SMembers(key string, ctx context.Context) ([]string, error) {
+	return r.Client.SMembers(ctx, key).Result()
+}
+
+func (r *RedisRepositories) SIsMember(key string, member string, ctx context.Context) (bool, error) {
+	return r.Client.SIsMember(ctx, key, member).Result()
+}
+
+// SetNX sets the key only if it does not exist yet, reporting whether it was set
+func (r *RedisRepositories) SetNX(key string, data []byte, expiredTime time.Duration, ctx context.Context) (bool, error) {
+	return r.Client.SetNX(ctx, key, data, expiredTime).Result()
+}
+
+// Eval runs a Lua script atomically, through EVALSHA once Redis has cached it
+func (r *RedisRepositories) Eval(script string, keys []string, args []interface{}, ctx context.Context) (interface{}, error) {
+	return redis.NewScript(script).Run(ctx, r.Client, keys, args...).Result()
+}
@@ -0,0 +1,105 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader keeps a certificate, and optionally a CA pool, in sync with files on disk.
+// Files are polled for a changed modification time; a change that fails to load keeps
+// the previous certificate in use.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	caPool      *x509.CertPool
+	modTimes    []time.Time
+}
+
+// NewReloader loads the files once and, with a positive interval, starts watching them
+func NewReloader(certFile, keyFile, caFile string, interval time.Duration) (*Reloader, error) {
+	reloader := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go reloader.watch(interval)
+	}
+	return reloader, nil
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.certificate, r.caPool
+}
+
+func (r *Reloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.mutex.RLock()
+		changed := !equalTimes(r.modTimes, r.currentModTimes())
+		r.mutex.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := r.load(); err != nil {
+			log.Printf("Keeping the previous TLS certificate, reloading %s failed: %v", r.certFile, err)
+			continue
+		}
+		log.Printf("Reloaded TLS certificate %s", r.certFile)
+	}
+}
+
+func (r *Reloader) load() error {
+	modTimes := r.currentModTimes()
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	var caPool *x509.CertPool
+	if r.caFile != "" {
+		if caPool, err = loadCertPool(r.caFile); err != nil {
+			return err
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.certificate = &certificate
+	r.caPool = caPool
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *Reloader) currentModTimes() []time.Time {
+	var modTimes []time.Time
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		var modTime time.Time
+		if info, err := os.Stat(file); err == nil {
+			modTime = info.ModTime()
+		}
+		modTimes = append(modTimes, modTime)
+	}
+	return modTimes
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}